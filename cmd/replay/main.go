@@ -0,0 +1,109 @@
+// Command replay re-sends captured /api/services traffic to a server and
+// diffs the responses with the captured ones.
+//
+//	replay [-dir captures] [-target http://localhost:3001] [-header "Authorization: Basic ..."] [-ignore path,...] [file ...]
+//
+// Captures are written by the server when CAPTURE_DIR is set. Values redacted
+// in a capture match any replayed value. replay exits non-zero when any
+// response differs.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"consent-service-extensions/internal/capture"
+)
+
+// headerFlags collects repeated -header flags
+type headerFlags http.Header
+
+func (h headerFlags) String() string { return "" }
+
+func (h headerFlags) Set(v string) error {
+	key, value, ok := strings.Cut(v, ":")
+	if !ok {
+		return fmt.Errorf("invalid header %q, expected \"Key: Value\"", v)
+	}
+	http.Header(h).Add(strings.TrimSpace(key), strings.TrimSpace(value))
+	return nil
+}
+
+func main() {
+	dir := flag.String("dir", envOr("CAPTURE_DIR", "captures"), "directory of capture files")
+	target := flag.String("target", "http://localhost:3001", "base URL of the server to replay against")
+	ignore := flag.String("ignore", "", "comma separated response paths to ignore, \"*\" matches any key")
+	timeout := flag.Duration("timeout", 10*time.Second, "timeout of each request")
+	headers := headerFlags{}
+	flag.Var(headers, "header", "header added to every request, repeatable")
+	flag.Parse()
+
+	exchanges, err := load(*dir, flag.Args())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "replay:", err)
+		os.Exit(2)
+	}
+
+	replayer := &capture.Replayer{
+		Target: *target,
+		Client: &http.Client{Timeout: *timeout},
+		Header: http.Header(headers),
+	}
+	for _, p := range strings.Split(*ignore, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			replayer.Ignore = append(replayer.Ignore, p)
+		}
+	}
+
+	var differing, failed int
+	for _, x := range exchanges {
+		result, err := replayer.Replay(context.Background(), x)
+		switch {
+		case err != nil:
+			failed++
+			fmt.Printf("FAIL %s %s: %v\n", x.RequestID, x.Path, err)
+		case len(result.Diffs) > 0:
+			differing++
+			fmt.Printf("DIFF %s %s\n", x.RequestID, x.Path)
+			for _, d := range result.Diffs {
+				fmt.Printf("    %s\n", d)
+			}
+		default:
+			fmt.Printf("OK   %s %s\n", x.RequestID, x.Path)
+		}
+	}
+
+	fmt.Printf("\n%d replayed, %d differ, %d failed\n", len(exchanges), differing, failed)
+	if differing > 0 || failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// load reads the given capture files, or every capture in dir when none are given
+func load(dir string, files []string) ([]*capture.Exchange, error) {
+	if len(files) == 0 {
+		return capture.LoadDir(dir)
+	}
+	var exchanges []*capture.Exchange
+	for _, f := range files {
+		x, err := capture.Load(f)
+		if err != nil {
+			return nil, err
+		}
+		exchanges = append(exchanges, x)
+	}
+	return exchanges, nil
+}
+
+// envOr returns the environment variable key, or def when unset
+func envOr(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
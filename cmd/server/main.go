@@ -1,26 +1,499 @@
 package main
 
 import (
-	"log"
-	"net/http"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
+	"consent-service-extensions/internal/apperrors"
+	"consent-service-extensions/internal/audit"
+	"consent-service-extensions/internal/auth"
+	"consent-service-extensions/internal/capture"
 	"consent-service-extensions/internal/config"
+	"consent-service-extensions/internal/counter"
+	"consent-service-extensions/internal/fapi"
+	"consent-service-extensions/internal/health"
+	"consent-service-extensions/internal/httpserver"
+	"consent-service-extensions/internal/idempotency"
+	"consent-service-extensions/internal/logging"
+	"consent-service-extensions/internal/metrics"
+	"consent-service-extensions/internal/netacl"
+	"consent-service-extensions/internal/ratelimit"
+	"consent-service-extensions/internal/redact"
+	"consent-service-extensions/internal/replay"
+	"consent-service-extensions/internal/rules"
+	"consent-service-extensions/internal/secrets"
+	"consent-service-extensions/internal/signing"
+	"consent-service-extensions/internal/tlsserver"
+	"consent-service-extensions/internal/tppjws"
+	"consent-service-extensions/internal/tracing"
 	"consent-service-extensions/pkg/api"
 )
 
 func main() {
 	// Load configuration
-	cfg := config.Load()
+	cfg, err := config.Load()
+	if err != nil {
+		fatal("Invalid configuration", "error", err)
+	}
+
+	// Configure logging
+	logger, err := logging.New(os.Stderr, cfg.LogLevel, cfg.LogFormat)
+	if err != nil {
+		fatal("Invalid logging configuration", "error", err)
+	}
+	redactors, err := newRedactors(cfg.Redaction, cfg.Secrets)
+	if err != nil {
+		fatal("Invalid redaction configuration", "error", err)
+	}
+	logger = slog.New(redact.NewHandler(logger.Handler(), redactors.For(redact.SinkLog)))
+	slog.SetDefault(logger)
 
 	// Create and configure router
-	router := api.NewRouter()
+	routerOpts := []api.Option{api.WithConfig(cfg), api.WithLogger(logger)}
+
+	// Readiness checks, registered as dependencies are created
+	readiness := health.NewRegistry(cfg.Readiness.Timeout)
+	readiness.Register("rule-catalogue", true, apperrors.Check)
+
+	if len(cfg.BasicAuthCredentials) > 0 {
+		basicAuth, err := auth.NewBasicAuthenticatorSource(context.Background(), cfg.Secrets.List(cfg.BasicAuthCredentials))
+		if err != nil {
+			fatal("Invalid basic auth configuration", "error", err)
+		}
+		routerOpts = append(routerOpts, api.WithAuthenticators(basicAuth))
+	}
+
+	if cfg.OAuth2.Enabled() {
+		bearerAuth, keySet, err := newBearerAuthenticator(cfg.OAuth2, cfg.Secrets)
+		if err != nil {
+			fatal("Invalid OAuth2 configuration", "error", err)
+		}
+		if keySet != nil {
+			defer keySet.Close()
+			readiness.Register("jwks", true, keySet.Check)
+		}
+		routerOpts = append(routerOpts, api.WithAuthenticators(bearerAuth))
+	}
+
+	if len(cfg.BasicAuthCredentials) == 0 && !cfg.OAuth2.Enabled() {
+		slog.Warn("No authentication configured, extension endpoints are unauthenticated")
+	}
+
+	if cfg.Signing.Algorithm != "" {
+		signingKeys, err := signing.NewKeySource(context.Background(), cfg.Signing.Algorithm, signingKeyMaterial(cfg.Signing, cfg.Secrets))
+		if err != nil {
+			fatal("Invalid signing configuration", "error", err)
+		}
+		routerOpts = append(routerOpts, api.WithSigningKeySource(signingKeys))
+	}
+
+	if cfg.JWS.TPPKeysDir != "" {
+		verifier, err := tppjws.NewVerifier(tppjws.Options{
+			KeysDir:              cfg.JWS.TPPKeysDir,
+			TrustAnchor:          cfg.JWS.TrustAnchor,
+			Algorithms:           cfg.JWS.Algorithms,
+			RequiredConsentTypes: cfg.JWS.RequiredConsentTypes,
+			MaxAge:               cfg.JWS.MaxAge,
+		})
+		if err != nil {
+			fatal("Invalid JWS configuration", "error", err)
+		}
+		routerOpts = append(routerOpts, api.WithTPPSignatureVerifier(verifier))
+	}
+
+	headerValidator, err := fapi.NewValidator(cfg.FAPI.Profile, cfg.FAPI.MandatoryHeaders...)
+	if err != nil {
+		fatal("Invalid FAPI configuration", "error", err)
+	}
+	routerOpts = append(routerOpts, api.WithHeaderValidator(headerValidator))
+
+	if cfg.Idempotency.Store != "none" {
+		store, err := idempotency.Open(cfg.Idempotency.Store, cfg.Idempotency.File)
+		if err != nil {
+			fatal("Invalid idempotency configuration", "error", err)
+		}
+		defer store.Close()
+		readiness.Register("idempotency-store", true, idempotencyCheck(store))
+		routerOpts = append(routerOpts, api.WithIdempotencyStore(store, cfg.Idempotency.TTL))
+	}
+
+	if cfg.MetricsEnabled {
+		routerOpts = append(routerOpts, api.WithMetrics(metrics.New()))
+	}
+
+	if cfg.Tracing.Exporter != tracing.ExporterNone {
+		exporter, err := newTraceExporter(cfg.Tracing)
+		if err != nil {
+			fatal("Invalid tracing configuration", "error", err)
+		}
+		tracer := tracing.NewTracer(exporter)
+		defer func() {
+			ctx, cancel := context.WithTimeout(context.Background(), cfg.Tracing.Timeout)
+			defer cancel()
+			if err := tracer.Shutdown(ctx); err != nil {
+				slog.Warn("Failed to flush traces", "error", err)
+			}
+		}()
+		routerOpts = append(routerOpts, api.WithTracer(tracer))
+	}
+
+	if len(cfg.Audit.Sinks) > 0 {
+		auditLog, err := newAuditLog(cfg.Audit, redactors.For(redact.SinkAudit))
+		if err != nil {
+			fatal("Invalid audit configuration", "error", err)
+		}
+		defer auditLog.Close()
+		routerOpts = append(routerOpts, api.WithAuditLog(auditLog))
+	}
+
+	if cfg.CaptureDir != "" {
+		recorder, err := capture.New(cfg.CaptureDir, redactors.For(redact.SinkCapture))
+		if err != nil {
+			fatal("Invalid capture configuration", "error", err)
+		}
+		slog.Warn("Capturing extension traffic", "dir", cfg.CaptureDir)
+		routerOpts = append(routerOpts, api.WithCapture(recorder))
+	}
+
+	if cfg.ReplayWindow > 0 {
+		routerOpts = append(routerOpts, api.WithReplayGuard(replay.NewGuard(cfg.ReplayWindow)))
+	}
+
+	if len(cfg.RateLimit.Limits) > 0 || cfg.RateLimit.DailyConsentCreations > 0 {
+		limiter, quotaStore, err := newRateLimiter(cfg.RateLimit)
+		if err != nil {
+			fatal("Invalid rate limit configuration", "error", err)
+		}
+		if quotaStore != nil {
+			defer quotaStore.Close()
+			// Quotas fail open, so an unusable store only degrades the service
+			readiness.Register("quota-store", false, counterCheck(quotaStore))
+		}
+		routerOpts = append(routerOpts, api.WithRateLimiter(limiter))
+	}
+
+	if cfg.Access.CounterStore != "none" {
+		location, err := time.LoadLocation(cfg.Access.Timezone)
+		if err != nil {
+			fatal("Invalid ACCESS_COUNTER_TIMEZONE", "error", err)
+		}
+		accessCounter, err := counter.Open(cfg.Access.CounterStore, cfg.Access.CounterFile)
+		if err != nil {
+			fatal("Invalid access counter configuration", "error", err)
+		}
+		defer accessCounter.Close()
+		readiness.Register("access-counter-store", true, counterCheck(accessCounter))
+		routerOpts = append(routerOpts, api.WithAccessCounter(accessCounter, location))
+	}
+
+	if cfg.Rules.File != "" {
+		ruleStore, err := rules.NewStore(cfg.Rules.File, cfg.Rules.WatchInterval)
+		if err != nil {
+			fatal("Invalid rule file", "error", err)
+		}
+		defer ruleStore.Close()
+		go reloadRulesOnHangup(ruleStore)
+		status := ruleStore.Status()
+		slog.Info("Rules loaded", "file", cfg.Rules.File, "version", status.Version, "hash", status.Hash)
+		routerOpts = append(routerOpts, api.WithRules(ruleStore))
+	}
+
+	aclGroups := []struct {
+		name        string
+		allow, deny []string
+	}{
+		{netacl.GroupAPI, cfg.NetworkACL.APIAllow, cfg.NetworkACL.APIDeny},
+		{netacl.GroupOps, cfg.NetworkACL.OpsAllow, cfg.NetworkACL.OpsDeny},
+	}
+	for _, group := range aclGroups {
+		if len(group.allow) == 0 && len(group.deny) == 0 {
+			continue
+		}
+		acl, err := netacl.New(netacl.Options{
+			Allow:          group.allow,
+			Deny:           group.deny,
+			TrustedProxies: cfg.NetworkACL.TrustedProxies,
+		})
+		if err != nil {
+			fatal("Invalid network ACL", "group", group.name, "error", err)
+		}
+		routerOpts = append(routerOpts, api.WithNetworkACL(group.name, acl))
+	}
+
+	for _, c := range cfg.Readiness.HTTPChecks {
+		name, url, ok := strings.Cut(c, "=")
+		if !ok {
+			fatal("Invalid READINESS_HTTP_CHECKS entry, expected name=url", "entry", c)
+		}
+		readiness.Register(name, true, health.HTTPCheck(nil, url))
+	}
+
+	healthState := &health.State{}
+	routerOpts = append(routerOpts, api.WithHealthState(healthState), api.WithReadinessChecks(readiness))
+	router := api.NewRouter(routerOpts...)
 
 	// Start server
 	addr := ":" + cfg.Port
-	log.Printf("Server starting on port %s", cfg.Port)
-	log.Printf("Log level: %s", cfg.LogLevel)
+	slog.Info("Server starting", "port", cfg.Port, "logLevel", cfg.LogLevel)
+
+	server := httpserver.New(addr, router, cfg.Server)
+	serve := server.ListenAndServe
+
+	if cfg.TLS.Enabled() {
+		reloader, err := tlsserver.NewReloader(tlsserver.Options{
+			CertFile:         cfg.TLS.CertFile,
+			KeyFile:          cfg.TLS.KeyFile,
+			ClientCAFile:     cfg.TLS.ClientCAFile,
+			ClientAuth:       cfg.TLS.ClientAuth,
+			PinnedSubjects:   cfg.TLS.PinnedSubjects,
+			PinnedSPKIHashes: cfg.TLS.PinnedSPKIHashes,
+			ReloadInterval:   cfg.TLS.ReloadInterval,
+			ReadFile:         readSecretFile(cfg.Secrets),
+		})
+		if err != nil {
+			fatal("Invalid TLS configuration", "error", err)
+		}
+		defer reloader.Close()
+
+		server.TLSConfig = reloader.TLSConfig()
+		serve = func() error { return server.ListenAndServeTLS("", "") }
+		slog.Info("Serving TLS", "clientAuth", cfg.TLS.ClientAuth)
+	}
+
+	// Drain on SIGTERM or SIGINT, returning from main so deferred stores and exporters are closed
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	defer stop()
+	if err := httpserver.Serve(ctx, server, serve, healthState, cfg.Server.ShutdownDelay, cfg.Server.ShutdownTimeout); err != nil {
+		slog.Error("Server stopped", "error", err)
+	}
+}
+
+// fatal logs an error and exits
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+// reloadRulesOnHangup reloads the rule file on every SIGHUP
+func reloadRulesOnHangup(store *rules.Store) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	for range hup {
+		if err := store.Reload(); err != nil {
+			slog.Error("Rule reload failed, keeping current rules", "error", err)
+			continue
+		}
+		status := store.Status()
+		slog.Info("Rules reloaded", "version", status.Version, "hash", status.Hash)
+	}
+}
+
+// newRedactors creates the redactor of each sink, using the built-in paths and
+// patterns where a policy names none. A hash key referencing a secret is read
+// through the secrets provider on every use.
+func newRedactors(cfg config.RedactionConfig, secretsCfg config.SecretsConfig) (redact.Sinks, error) {
+	policies := map[string]config.RedactionPolicy{
+		redact.SinkLog:     cfg.Log,
+		redact.SinkAudit:   cfg.Audit,
+		redact.SinkCapture: cfg.Capture,
+	}
+
+	sinks := make(redact.Sinks, len(policies))
+	for sink, p := range policies {
+		policy := redact.Policy{
+			Paths:    p.Paths,
+			Patterns: p.Patterns,
+			Action:   p.Action,
+			HashKey:  cfg.HashKey,
+		}
+		if _, ok := secrets.Ref(cfg.HashKey); ok {
+			policy.HashKey = ""
+			policy.HashKeySource = secretsCfg.Value(cfg.HashKey)
+		}
+		if len(policy.Paths) == 0 {
+			policy.Paths = redact.DefaultPaths
+		}
+		if len(policy.Patterns) == 0 {
+			policy.Patterns = redact.DefaultPatterns
+		}
+
+		if policy.Action == redact.ActionHash && cfg.HashKey == "" {
+			slog.Warn("REDACT_HASH_KEY is not set, hashing with a random key that changes on restart", "sink", sink)
+		}
+
+		r, err := redact.New(policy)
+		if err != nil {
+			return nil, fmt.Errorf("%s sink: %w", sink, err)
+		}
+		sinks[sink] = r
+	}
+	return sinks, nil
+}
+
+// newAuditLog creates the audit log writing to the configured sinks
+func newAuditLog(cfg config.AuditConfig, redactor *redact.Redactor) (*audit.Log, error) {
+	var sinks []audit.Sink
+	for _, name := range cfg.Sinks {
+		switch name {
+		case audit.SinkFile:
+			sink, err := audit.NewFileSink(cfg.File, cfg.MaxBytes, int(cfg.MaxBackups))
+			if err != nil {
+				return nil, err
+			}
+			sinks = append(sinks, sink)
+		case audit.SinkStdout:
+			sinks = append(sinks, audit.NewWriterSink(os.Stdout))
+		default:
+			return nil, fmt.Errorf("unknown audit sink %q", name)
+		}
+	}
+	return audit.New(redactor, sinks...)
+}
+
+// idempotencyCheck reports an idempotency store that cannot be read
+func idempotencyCheck(store idempotency.Store) health.Check {
+	return func(ctx context.Context) error {
+		_, err := store.Get(ctx, "readiness", "probe")
+		if errors.Is(err, idempotency.ErrNotFound) {
+			return nil
+		}
+		return err
+	}
+}
+
+// counterCheck reports a counter store that cannot be read
+func counterCheck(store counter.Store) health.Check {
+	return func(ctx context.Context) error {
+		_, err := store.Count(ctx, "readiness", counter.Day(time.Now(), time.UTC))
+		return err
+	}
+}
+
+// newTraceExporter creates the span exporter selected by cfg.Exporter
+func newTraceExporter(cfg config.TracingConfig) (tracing.Exporter, error) {
+	switch cfg.Exporter {
+	case tracing.ExporterOTLP:
+		headers := make(map[string]string, len(cfg.Headers))
+		for _, h := range cfg.Headers {
+			key, value, ok := strings.Cut(h, "=")
+			if !ok {
+				return nil, fmt.Errorf("invalid OTLP header %q, expected key=value", h)
+			}
+			headers[strings.TrimSpace(key)] = strings.TrimSpace(value)
+		}
+		return tracing.NewOTLPExporter(cfg.Endpoint, cfg.ServiceName, headers, cfg.Timeout), nil
+	case tracing.ExporterFile:
+		return tracing.NewFileExporter(cfg.File, cfg.ServiceName)
+	default:
+		return nil, fmt.Errorf("unknown trace exporter %q", cfg.Exporter)
+	}
+}
+
+// newBearerAuthenticator creates the OAuth2 bearer token authenticator and its JWK Set, if any
+func newBearerAuthenticator(cfg config.OAuth2Config, secretsCfg config.SecretsConfig) (*auth.BearerAuthenticator, *auth.KeySet, error) {
+	var jwtValidator *auth.JWTValidator
+	var keySet *auth.KeySet
+	if cfg.JWKSSource != "" {
+		ks, err := auth.NewKeySet(cfg.JWKSSource, cfg.JWKSRefreshInterval)
+		if err != nil {
+			return nil, nil, err
+		}
+		keySet = ks
+		jwtValidator = &auth.JWTValidator{
+			Keys:     keySet,
+			Issuer:   cfg.Issuer,
+			Audience: cfg.Audience,
+		}
+	}
+
+	var introspector *auth.Introspector
+	if cfg.IntrospectionEndpoint != "" {
+		introspector = &auth.Introspector{
+			Endpoint: cfg.IntrospectionEndpoint,
+			ClientID: cfg.IntrospectionClientID,
+			Issuer:   cfg.Issuer,
+			Audience: cfg.Audience,
+			// Read on every request so a rotated secret applies without a restart
+			ClientSecretSource: secretsCfg.Value(cfg.IntrospectionSecret),
+		}
+	}
+
+	bearerAuth, err := auth.NewBearerAuthenticator(jwtValidator, introspector, cfg.RequiredScope)
+	if err != nil {
+		if keySet != nil {
+			keySet.Close()
+		}
+		return nil, nil, err
+	}
+	return bearerAuth, keySet, nil
+}
+
+// signingKeyMaterial reads the signing keys, resolving secret references
+// through the secrets provider
+func signingKeyMaterial(cfg config.SigningConfig, secretsCfg config.SecretsConfig) func(context.Context) ([]string, string, error) {
+	verifyKeys := secretsCfg.List(cfg.VerifyKeys)
+	responseKey := secretsCfg.Value(cfg.ResponseKey)
+	return func(ctx context.Context) ([]string, string, error) {
+		verify, err := verifyKeys(ctx)
+		if err != nil {
+			return nil, "", err
+		}
+		response, err := responseKey(ctx)
+		return verify, response, err
+	}
+}
+
+// readSecretFile reads a file, or the PEM data of a secret://name reference
+// through the secrets provider
+func readSecretFile(secretsCfg config.SecretsConfig) func(name string) ([]byte, error) {
+	return func(name string) ([]byte, error) {
+		if _, ok := secrets.Ref(name); !ok {
+			return os.ReadFile(name)
+		}
+		value, err := secretsCfg.Value(name)(context.Background())
+		return []byte(value), err
+	}
+}
+
+// newRateLimiter creates the rate limiter and its quota store, if any
+func newRateLimiter(cfg config.RateLimitConfig) (*ratelimit.Limiter, counter.Store, error) {
+	limits, err := ratelimit.ParseLimits(cfg.Limits)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	location, err := time.LoadLocation(cfg.QuotaTimezone)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid QUOTA_TIMEZONE: %w", err)
+	}
+
+	var quotaStore counter.Store
+	if cfg.DailyConsentCreations > 0 {
+		quotaStore, err = counter.Open(cfg.QuotaStore, cfg.QuotaFile)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
 
-	if err := http.ListenAndServe(addr, router); err != nil {
-		log.Fatal(err)
+	limiter, err := ratelimit.New(ratelimit.Options{
+		Key:                cfg.Key,
+		Limits:             limits,
+		DailyCreationQuota: cfg.DailyConsentCreations,
+		QuotaStore:         quotaStore,
+		Location:           location,
+	})
+	if err != nil {
+		if quotaStore != nil {
+			quotaStore.Close()
+		}
+		return nil, nil, err
 	}
+	return limiter, quotaStore, nil
 }
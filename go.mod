@@ -3,3 +3,11 @@ module consent-service-extensions
 go 1.21
 
 require github.com/gorilla/mux v1.8.1
+
+require (
+	go.etcd.io/bbolt v1.3.10
+	golang.org/x/crypto v0.31.0
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require golang.org/x/sys v0.28.0 // indirect
@@ -0,0 +1,151 @@
+package apperrors
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Catalogue codes for business rule rejections
+const (
+	CodeConsentTypeMissing   = "CONSENT_TYPE_MISSING"
+	CodeConsentStatusMissing = "CONSENT_STATUS_MISSING"
+	CodeInvalidValidityTime  = "INVALID_VALIDITY_TIME"
+	CodeInvalidFrequency     = "INVALID_FREQUENCY"
+	CodeIdempotencyMismatch  = "IDEMPOTENCY_KEY_MISMATCH"
+	CodeIdempotencyConflict  = "IDEMPOTENCY_KEY_IN_PROGRESS"
+	CodeFrequencyExceeded    = "ACCESS_FREQUENCY_EXCEEDED"
+	CodePermissionNotAllowed = "PERMISSION_NOT_ALLOWED"
+
+	// OBIE detached JWS (x-jws-signature) error codes
+	CodeSignatureMissing      = "UK.OBIE.Signature.Missing"
+	CodeSignatureMalformed    = "UK.OBIE.Signature.Malformed"
+	CodeSignatureInvalid      = "UK.OBIE.Signature.Invalid"
+	CodeSignatureInvalidClaim = "UK.OBIE.Signature.InvalidClaim"
+	CodeSignatureMissingClaim = "UK.OBIE.Signature.MissingClaim"
+	// CodeSignatureIssuerMismatch has no OBIE equivalent: a valid signature whose iss is another TPP
+	CodeSignatureIssuerMismatch = "SIGNATURE_ISSUER_MISMATCH"
+
+	// OBIE request header error codes
+	CodeHeaderMissing = "UK.OBIE.Header.Missing"
+	CodeHeaderInvalid = "UK.OBIE.Header.Invalid"
+)
+
+// Business errors returned by the consent business logic
+var (
+	ErrConsentTypeMissing = New(CodeConsentTypeMissing, http.StatusBadRequest, map[string]interface{}{
+		"errorMessage":     "invalid_request",
+		"errorDescription": "Consent type is missing",
+	})
+	ErrConsentStatusMissing = New(CodeConsentStatusMissing, http.StatusBadRequest, map[string]interface{}{
+		"errorMessage":     "invalid_request",
+		"errorDescription": "Consent status is missing",
+	})
+	ErrInvalidValidityTime = New(CodeInvalidValidityTime, http.StatusBadRequest, map[string]interface{}{
+		"errorMessage":     "invalid_request",
+		"errorDescription": "Invalid validityTime {value}, must not be negative",
+	})
+	ErrInvalidFrequency = New(CodeInvalidFrequency, http.StatusBadRequest, map[string]interface{}{
+		"errorMessage":     "invalid_request",
+		"errorDescription": "Invalid frequency {value}, must not be negative",
+	})
+	ErrIdempotencyMismatch = New(CodeIdempotencyMismatch, http.StatusBadRequest, map[string]interface{}{
+		"errorMessage":     "invalid_request",
+		"errorDescription": "The x-idempotency-key {key} was already used with a different payload",
+	})
+	ErrIdempotencyConflict = New(CodeIdempotencyConflict, http.StatusConflict, map[string]interface{}{
+		"errorMessage":     "invalid_request",
+		"errorDescription": "A request with x-idempotency-key {key} is still being processed",
+	})
+	ErrFrequencyExceeded = New(CodeFrequencyExceeded, http.StatusTooManyRequests, map[string]interface{}{
+		"errorMessage":     "access_limit_exceeded",
+		"errorDescription": "Consent {consentId} has reached its limit of {limit} accesses per day",
+	})
+	ErrPermissionNotAllowed = New(CodePermissionNotAllowed, http.StatusBadRequest, map[string]interface{}{
+		"errorMessage":     "invalid_request",
+		"errorDescription": "Permission {permission} is not allowed for {consentType} consents",
+	})
+)
+
+// OBIE signature errors, returned in the OBIE error response shape so the
+// accelerator can pass them on to the TPP
+var (
+	ErrSignatureMissing        = newOBIEError(CodeSignatureMissing, signatureFailed, "The x-jws-signature header is missing")
+	ErrSignatureMalformed      = newOBIEError(CodeSignatureMalformed, signatureFailed, "The x-jws-signature header is malformed: {reason}")
+	ErrSignatureInvalid        = newOBIEError(CodeSignatureInvalid, signatureFailed, "The x-jws-signature is invalid: {reason}")
+	ErrSignatureInvalidClaim   = newOBIEError(CodeSignatureInvalidClaim, signatureFailed, "The x-jws-signature claim {claim} is invalid: {reason}")
+	ErrSignatureMissingClaim   = newOBIEError(CodeSignatureMissingClaim, signatureFailed, "The x-jws-signature claim {claim} is missing")
+	ErrSignatureIssuerMismatch = newOBIEError(CodeSignatureIssuerMismatch, signatureFailed, "The x-jws-signature issuer {iss} is not TPP {thirdPartyId}")
+)
+
+// OBIE request header errors
+var (
+	ErrHeaderMissing = newOBIEError(CodeHeaderMissing, headerFailed, "Mandatory header {header} is missing")
+	ErrHeaderInvalid = newOBIEError(CodeHeaderInvalid, headerFailed, "Header {header} is invalid: {reason}")
+)
+
+// Summary messages of OBIE error responses
+const (
+	signatureFailed = "Request signature validation failed"
+	headerFailed    = "Request header validation failed"
+)
+
+// newOBIEError creates a catalogue entry with an OBIE error response payload
+func newOBIEError(code, summary, message string) *BusinessError {
+	return New(code, http.StatusBadRequest, map[string]interface{}{
+		"Code":    "400 BadRequest",
+		"Message": summary,
+		"Errors": []interface{}{
+			map[string]interface{}{
+				"ErrorCode": code,
+				"Message":   message,
+			},
+		},
+	})
+}
+
+// catalogue indexes every business error by its code
+var catalogue = map[string]*BusinessError{}
+
+func init() {
+	for _, e := range []*BusinessError{
+		ErrConsentTypeMissing,
+		ErrConsentStatusMissing,
+		ErrInvalidValidityTime,
+		ErrInvalidFrequency,
+		ErrIdempotencyMismatch,
+		ErrIdempotencyConflict,
+		ErrFrequencyExceeded,
+		ErrPermissionNotAllowed,
+		ErrSignatureMissing,
+		ErrSignatureMalformed,
+		ErrSignatureInvalid,
+		ErrSignatureInvalidClaim,
+		ErrSignatureMissingClaim,
+		ErrSignatureIssuerMismatch,
+		ErrHeaderMissing,
+		ErrHeaderInvalid,
+	} {
+		catalogue[e.Code] = e
+	}
+}
+
+// Check verifies that the catalogue is loaded and every entry renders a payload
+func Check(context.Context) error {
+	if len(catalogue) == 0 {
+		return errors.New("error catalogue is empty")
+	}
+	for code, e := range catalogue {
+		if len(e.Payload()) == 0 {
+			return fmt.Errorf("catalogue entry %s has no payload", code)
+		}
+	}
+	return nil
+}
+
+// Lookup returns the catalogue entry for a code
+func Lookup(code string) (*BusinessError, bool) {
+	e, ok := catalogue[code]
+	return e, ok
+}
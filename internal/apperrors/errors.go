@@ -0,0 +1,150 @@
+package apperrors
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// BusinessError represents a business rule rejection that is reported to the
+// accelerator as a FailedResponse rather than as a server error
+type BusinessError struct {
+	// Code is the stable catalogue code identifying the rule that rejected the request
+	Code string
+	// ErrorCode is the HTTP-like error code returned in the FailedResponse
+	ErrorCode int
+	// Data is the payload template returned in the FailedResponse. String values
+	// may contain {name} placeholders that are filled from Params.
+	Data map[string]interface{}
+	// Params holds the values substituted into the Data template
+	Params map[string]string
+
+	cause error
+}
+
+// New creates a new business error for the catalogue
+func New(code string, errorCode int, data map[string]interface{}) *BusinessError {
+	return &BusinessError{
+		Code:      code,
+		ErrorCode: errorCode,
+		Data:      data,
+	}
+}
+
+// Error implements the error interface
+func (e *BusinessError) Error() string {
+	msg := fmt.Sprintf("%s (%d)", e.Code, e.ErrorCode)
+	if desc, ok := e.Payload()["errorDescription"].(string); ok && desc != "" {
+		msg += ": " + desc
+	}
+	if e.cause != nil {
+		msg += ": " + e.cause.Error()
+	}
+	return msg
+}
+
+// Unwrap returns the underlying cause, if any
+func (e *BusinessError) Unwrap() error {
+	return e.cause
+}
+
+// Is reports whether target is a business error with the same catalogue code,
+// so errors.Is(err, apperrors.ErrConsentTypeMissing) works on derived errors
+func (e *BusinessError) Is(target error) bool {
+	t, ok := target.(*BusinessError)
+	if !ok {
+		return false
+	}
+	return e.Code == t.Code
+}
+
+// WithParam returns a copy of the error with a template parameter set
+func (e *BusinessError) WithParam(name, value string) *BusinessError {
+	c := e.clone()
+	c.Params[name] = value
+	return c
+}
+
+// WithTemplate returns a copy of the error with its error code and payload
+// template replaced, keeping the code, parameters and cause
+func (e *BusinessError) WithTemplate(errorCode int, data map[string]interface{}) *BusinessError {
+	c := e.clone()
+	c.ErrorCode = errorCode
+	c.Data = data
+	return c
+}
+
+// Wrap returns a copy of the error that wraps the given cause
+func (e *BusinessError) Wrap(cause error) *BusinessError {
+	c := e.clone()
+	c.cause = cause
+	return c
+}
+
+// Payload renders the Data template with the error's parameters
+func (e *BusinessError) Payload() map[string]interface{} {
+	return renderMap(e.Data, e.Params)
+}
+
+// clone returns a copy of the error that can be modified without affecting the catalogue entry
+func (e *BusinessError) clone() *BusinessError {
+	params := make(map[string]string, len(e.Params)+1)
+	for k, v := range e.Params {
+		params[k] = v
+	}
+	return &BusinessError{
+		Code:      e.Code,
+		ErrorCode: e.ErrorCode,
+		Data:      e.Data,
+		Params:    params,
+		cause:     e.cause,
+	}
+}
+
+// AsBusinessError extracts a business error from an error chain
+func AsBusinessError(err error) (*BusinessError, bool) {
+	var be *BusinessError
+	if errors.As(err, &be) {
+		return be, true
+	}
+	return nil, false
+}
+
+// renderMap copies a template map, substituting placeholders in string values
+func renderMap(tmpl map[string]interface{}, params map[string]string) map[string]interface{} {
+	out := make(map[string]interface{}, len(tmpl))
+	for k, v := range tmpl {
+		out[k] = renderValue(v, params)
+	}
+	return out
+}
+
+// renderValue substitutes placeholders in a single template value
+func renderValue(v interface{}, params map[string]string) interface{} {
+	switch val := v.(type) {
+	case string:
+		return renderString(val, params)
+	case map[string]interface{}:
+		return renderMap(val, params)
+	case []interface{}:
+		out := make([]interface{}, len(val))
+		for i, item := range val {
+			out[i] = renderValue(item, params)
+		}
+		return out
+	default:
+		return v
+	}
+}
+
+// renderString replaces {name} placeholders with their parameter values
+func renderString(s string, params map[string]string) string {
+	if len(params) == 0 || !strings.Contains(s, "{") {
+		return s
+	}
+	pairs := make([]string, 0, len(params)*2)
+	for k, v := range params {
+		pairs = append(pairs, "{"+k+"}", v)
+	}
+	return strings.NewReplacer(pairs...).Replace(s)
+}
@@ -0,0 +1,86 @@
+package audit
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"consent-service-extensions/internal/redact"
+)
+
+// Log appends hash chained records to its sinks. Records are written to every
+// sink in order, so each sink holds the same chain.
+type Log struct {
+	redactor *redact.Redactor
+	sinks    []Sink
+	now      func() time.Time
+
+	mu   sync.Mutex
+	seq  uint64
+	prev string
+}
+
+// New creates an audit log writing to sinks. The chain continues from the last
+// record of the first sink that stores records, such as a FileSink. Free text
+// fields are redacted with redactor, which may be nil.
+func New(redactor *redact.Redactor, sinks ...Sink) (*Log, error) {
+	l := &Log{redactor: redactor, sinks: sinks, now: time.Now, prev: GenesisHash}
+	for _, s := range sinks {
+		r, ok := s.(resumer)
+		if !ok {
+			continue
+		}
+		last, err := r.Last()
+		if err != nil {
+			return nil, fmt.Errorf("resume audit chain: %w", err)
+		}
+		if last != nil {
+			l.seq, l.prev = last.Seq+1, last.Hash
+		}
+		break
+	}
+	return l, nil
+}
+
+// Append assigns the record its sequence number, time and chain hashes, and
+// writes it to every sink. The chain advances even if a sink fails, so the
+// failure shows up as a gap in that sink.
+func (l *Log) Append(rec Record) (Record, error) {
+	rec.Reason = l.redactor.String(rec.Reason)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	rec.Seq = l.seq
+	rec.Time = l.now().UTC().Format(time.RFC3339Nano)
+	rec.PrevHash = l.prev
+	hash, err := rec.ComputeHash()
+	if err != nil {
+		return rec, err
+	}
+	rec.Hash = hash
+
+	line, err := marshalLine(rec)
+	if err != nil {
+		return rec, err
+	}
+	l.seq, l.prev = rec.Seq+1, rec.Hash
+
+	var errs []error
+	for _, s := range l.sinks {
+		if err := s.Write(line); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return rec, errors.Join(errs...)
+}
+
+// Close closes every sink
+func (l *Log) Close() error {
+	var errs []error
+	for _, s := range l.sinks {
+		errs = append(errs, s.Close())
+	}
+	return errors.Join(errs...)
+}
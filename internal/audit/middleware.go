@@ -0,0 +1,115 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"path"
+	"sync"
+
+	"consent-service-extensions/internal/requestbody"
+)
+
+// decision collects what the handler decided about a request
+type decision struct {
+	mu       sync.Mutex
+	rules    []string
+	reason   string
+	purposes []string
+}
+
+// decisionKey is the context key of the request's decision
+type decisionKey struct{}
+
+// RuleFired records that a business rule rejected the request
+func RuleFired(ctx context.Context, rule, reason string) {
+	if d, ok := ctx.Value(decisionKey{}).(*decision); ok {
+		d.mu.Lock()
+		d.rules = append(d.rules, rule)
+		d.reason = reason
+		d.mu.Unlock()
+	}
+}
+
+// PurposesResolved records the consent purposes resolved for the request
+func PurposesResolved(ctx context.Context, purposes []string) {
+	if d, ok := ctx.Value(decisionKey{}).(*decision); ok {
+		d.mu.Lock()
+		d.purposes = append([]string(nil), purposes...)
+		d.mu.Unlock()
+	}
+}
+
+// Middleware appends a record for every request once it has been answered
+func (l *Log) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Bodies that can't be hashed whole never get past the router's body limit
+		body, _ := requestbody.Read(r)
+
+		d := &decision{}
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(sw, r.WithContext(context.WithValue(r.Context(), decisionKey{}, d)))
+
+		requestID, consentType := describeRequest(body)
+		rec := Record{
+			RequestID:   requestID,
+			Endpoint:    path.Base(r.URL.Path),
+			ConsentType: consentType,
+			Status:      sw.status,
+			PayloadHash: HashPayload(body),
+		}
+
+		d.mu.Lock()
+		rec.Rules, rec.Reason, rec.Purposes = d.rules, d.reason, d.purposes
+		d.mu.Unlock()
+
+		switch {
+		case sw.status >= http.StatusBadRequest:
+			rec.Decision = DecisionError
+		case len(rec.Rules) > 0:
+			rec.Decision = DecisionRejected
+		default:
+			rec.Decision = DecisionAccepted
+		}
+
+		if _, err := l.Append(rec); err != nil {
+			slog.Error("Failed to write audit record", "requestId", requestID, "error", err)
+		}
+	})
+}
+
+// describeRequest extracts the requestId and consent type of an extension request
+func describeRequest(body []byte) (string, string) {
+	var envelope struct {
+		RequestID string `json:"requestId"`
+		Data      struct {
+			ConsentInitiationData struct {
+				Type string `json:"type"`
+			} `json:"consentInitiationData"`
+			ConsentResource struct {
+				Type string `json:"type"`
+			} `json:"consentResource"`
+		} `json:"data"`
+	}
+	// Malformed bodies are audited with the ERROR decision the handler returns
+	_ = json.Unmarshal(body, &envelope)
+
+	consentType := envelope.Data.ConsentInitiationData.Type
+	if consentType == "" {
+		consentType = envelope.Data.ConsentResource.Type
+	}
+	return envelope.RequestID, consentType
+}
+
+// statusWriter records the status code of a response
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+// WriteHeader records the status code
+func (w *statusWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
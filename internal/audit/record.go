@@ -0,0 +1,59 @@
+package audit
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+)
+
+// Decisions recorded for an extension call
+const (
+	// DecisionAccepted is a successful response
+	DecisionAccepted = "ACCEPTED"
+	// DecisionRejected is a FailedResponse returned by a business rule
+	DecisionRejected = "REJECTED"
+	// DecisionError is a non-2xx response, e.g. a malformed request or a server error
+	DecisionError = "ERROR"
+)
+
+// GenesisHash is the previous hash of the first record of a chain
+var GenesisHash = strings.Repeat("0", sha256.Size*2)
+
+// Record is one audit log entry. Hash is the SHA-256 of PrevHash followed by
+// the record's JSON encoding without Hash, so editing, removing or reordering
+// records breaks the chain.
+type Record struct {
+	Seq         uint64   `json:"seq"`
+	Time        string   `json:"time"`
+	RequestID   string   `json:"requestId"`
+	Endpoint    string   `json:"endpoint"`
+	ConsentType string   `json:"consentType,omitempty"`
+	Decision    string   `json:"decision"`
+	Status      int      `json:"status"`
+	Rules       []string `json:"rules,omitempty"`
+	Reason      string   `json:"reason,omitempty"`
+	Purposes    []string `json:"purposes,omitempty"`
+	PayloadHash string   `json:"payloadHash"`
+	PrevHash    string   `json:"prevHash"`
+	Hash        string   `json:"hash,omitempty"`
+}
+
+// ComputeHash returns the chain hash of the record, ignoring its Hash field
+func (r Record) ComputeHash() (string, error) {
+	r.Hash = ""
+	body, err := json.Marshal(r)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.New()
+	sum.Write([]byte(r.PrevHash))
+	sum.Write(body)
+	return hex.EncodeToString(sum.Sum(nil)), nil
+}
+
+// HashPayload returns the hash recorded for a request payload
+func HashPayload(body []byte) string {
+	sum := sha256.Sum256(body)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,219 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// Sinks
+const (
+	SinkFile   = "file"
+	SinkStdout = "stdout"
+)
+
+// tailSize is how much of a log file is read to find its last record
+const tailSize = 1 << 20
+
+// Sink stores encoded audit records, one JSON line each
+type Sink interface {
+	Write(line []byte) error
+	Close() error
+}
+
+// resumer is a Sink that can return the last record it stored, so the chain
+// continues across restarts
+type resumer interface {
+	Last() (*Record, error)
+}
+
+// WriterSink writes records to a writer such as stdout
+type WriterSink struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewWriterSink creates a sink writing to w
+func NewWriterSink(w io.Writer) *WriterSink {
+	return &WriterSink{w: w}
+}
+
+// Write writes one line
+func (s *WriterSink) Write(line []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err := s.w.Write(line)
+	return err
+}
+
+// Close does nothing, the writer is owned by the caller
+func (s *WriterSink) Close() error {
+	return nil
+}
+
+// FileSink appends records to a file, rotating it to path.1, path.2, ... once
+// it would exceed maxBytes. Only maxBackups rotated files are kept.
+type FileSink struct {
+	path       string
+	maxBytes   int64
+	maxBackups int
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+// NewFileSink opens path for appending. A maxBytes of zero disables rotation.
+func NewFileSink(path string, maxBytes int64, maxBackups int) (*FileSink, error) {
+	s := &FileSink{path: path, maxBytes: maxBytes, maxBackups: maxBackups}
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Write appends one line and syncs it to disk
+func (s *FileSink) Write(line []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.maxBytes > 0 && s.size > 0 && s.size+int64(len(line)) > s.maxBytes {
+		if err := s.rotate(); err != nil {
+			return fmt.Errorf("rotate audit log: %w", err)
+		}
+	}
+
+	n, err := s.file.Write(line)
+	s.size += int64(n)
+	if err != nil {
+		return err
+	}
+	return s.file.Sync()
+}
+
+// Close closes the file
+func (s *FileSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.file.Close()
+}
+
+// Last returns the last record of the current file, or of the newest rotated
+// file when the current one is empty
+func (s *FileSink) Last() (*Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, path := range []string{s.path, backupName(s.path, 1)} {
+		rec, err := lastRecord(path)
+		if err != nil || rec != nil {
+			return rec, err
+		}
+	}
+	return nil, nil
+}
+
+func (s *FileSink) open() error {
+	f, err := os.OpenFile(s.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	s.file, s.size = f, info.Size()
+	return nil
+}
+
+// rotate shifts path.N to path.N+1, moves the current file to path.1 and
+// starts a new one
+func (s *FileSink) rotate() error {
+	if err := s.file.Close(); err != nil {
+		return err
+	}
+
+	if s.maxBackups <= 0 {
+		if err := os.Remove(s.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return s.open()
+	}
+
+	if err := os.Remove(backupName(s.path, s.maxBackups)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	for i := s.maxBackups - 1; i >= 1; i-- {
+		if err := os.Rename(backupName(s.path, i), backupName(s.path, i+1)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	if err := os.Rename(s.path, backupName(s.path, 1)); err != nil {
+		return err
+	}
+	return s.open()
+}
+
+// backupName returns the name of the n-th rotated file
+func backupName(path string, n int) string {
+	return fmt.Sprintf("%s.%d", path, n)
+}
+
+// Files returns the audit log at path and its rotated files, oldest first
+func Files(path string) []string {
+	var files []string
+	for n := 1; ; n++ {
+		if _, err := os.Stat(backupName(path, n)); err != nil {
+			break
+		}
+		files = append([]string{backupName(path, n)}, files...)
+	}
+	if _, err := os.Stat(path); err == nil {
+		files = append(files, path)
+	}
+	return files
+}
+
+// lastRecord decodes the last line of a file, returning nil for a missing or empty file
+func lastRecord(path string) (*Record, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	offset := info.Size() - tailSize
+	if offset < 0 {
+		offset = 0
+	}
+	tail := make([]byte, info.Size()-offset)
+	if _, err := f.ReadAt(tail, offset); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+
+	tail = bytes.TrimRight(tail, "\n")
+	if len(tail) == 0 {
+		return nil, nil
+	}
+	if i := bytes.LastIndexByte(tail, '\n'); i >= 0 {
+		tail = tail[i+1:]
+	}
+
+	var rec Record
+	if err := json.Unmarshal(tail, &rec); err != nil {
+		return nil, fmt.Errorf("decode last record of %s: %w", path, err)
+	}
+	return &rec, nil
+}
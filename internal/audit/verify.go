@@ -0,0 +1,92 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// maxLineSize bounds a single record when verifying
+const maxLineSize = 1 << 20
+
+// ChainError reports where a chain is broken
+type ChainError struct {
+	Source string
+	Line   int
+	Seq    uint64
+	Reason string
+}
+
+func (e *ChainError) Error() string {
+	return fmt.Sprintf("%s:%d: seq %d: %s", e.Source, e.Line, e.Seq, e.Reason)
+}
+
+// Verifier checks one chain, possibly spread over several files read in order
+type Verifier struct {
+	started bool
+	next    uint64
+	prev    string
+
+	// First is the sequence number the verified chain starts at. It is above
+	// zero when older rotated files have been removed.
+	First uint64
+	// Records is the number of records verified
+	Records int
+}
+
+// Verify checks every record read from r, named source in errors, against
+// its own hash and its predecessor
+func (v *Verifier) Verify(source string, r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxLineSize)
+
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var rec Record
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			return &ChainError{Source: source, Line: line, Seq: v.next, Reason: "malformed record: " + err.Error()}
+		}
+		fail := func(reason string) error {
+			return &ChainError{Source: source, Line: line, Seq: rec.Seq, Reason: reason}
+		}
+
+		if !v.started {
+			if rec.Seq == 0 && rec.PrevHash != GenesisHash {
+				return fail("first record does not start from the genesis hash")
+			}
+			v.started, v.First = true, rec.Seq
+		} else {
+			if rec.Seq != v.next {
+				return fail(fmt.Sprintf("expected seq %d, records are missing or reordered", v.next))
+			}
+			if rec.PrevHash != v.prev {
+				return fail("previous hash does not match the preceding record")
+			}
+		}
+
+		hash, err := rec.ComputeHash()
+		if err != nil {
+			return fail(err.Error())
+		}
+		if hash != rec.Hash {
+			return fail("hash mismatch, the record was modified")
+		}
+
+		v.next, v.prev = rec.Seq+1, rec.Hash
+		v.Records++
+	}
+	return scanner.Err()
+}
+
+// marshalLine encodes a record as one newline terminated JSON line
+func marshalLine(rec Record) ([]byte, error) {
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return nil, err
+	}
+	return append(line, '\n'), nil
+}
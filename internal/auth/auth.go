@@ -0,0 +1,84 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// ErrUnauthenticated is returned when a request carries no usable credentials
+var ErrUnauthenticated = errors.New("authentication required")
+
+// ErrInvalidCredentials is returned when the presented credentials are rejected
+var ErrInvalidCredentials = errors.New("invalid credentials")
+
+// Principal identifies an authenticated caller
+type Principal struct {
+	// Subject is the username or client ID of the caller
+	Subject string
+	// Method is the authentication scheme used, e.g. "Basic"
+	Method string
+	// Scopes holds the granted OAuth2 scopes, if any
+	Scopes []string
+}
+
+// Authenticator validates the credentials of a single HTTP authentication scheme
+type Authenticator interface {
+	// Scheme returns the authentication scheme handled, e.g. "Basic" or "Bearer"
+	Scheme() string
+	// Authenticate validates the credentials that follow the scheme in the Authorization header
+	Authenticate(r *http.Request, credentials string) (*Principal, error)
+}
+
+// FailureHandler writes the response for a request that failed authentication
+type FailureHandler func(w http.ResponseWriter, r *http.Request, err error)
+
+type principalKey struct{}
+
+// WithPrincipal returns a copy of ctx carrying the authenticated principal
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// PrincipalFromContext returns the authenticated principal stored in ctx
+func PrincipalFromContext(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(*Principal)
+	return p, ok
+}
+
+// Middleware authenticates every request with one of the given authenticators,
+// selected by the scheme of the Authorization header
+func Middleware(onFailure FailureHandler, authenticators ...Authenticator) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			principal, err := authenticate(r, authenticators)
+			if err != nil {
+				for _, a := range authenticators {
+					w.Header().Add("WWW-Authenticate", a.Scheme()+` realm="consent-service-extensions"`)
+				}
+				onFailure(w, r, err)
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(WithPrincipal(r.Context(), principal)))
+		})
+	}
+}
+
+// authenticate dispatches the Authorization header to the matching authenticator
+func authenticate(r *http.Request, authenticators []Authenticator) (*Principal, error) {
+	header := r.Header.Get("Authorization")
+	if header == "" {
+		return nil, ErrUnauthenticated
+	}
+
+	scheme, credentials, _ := strings.Cut(header, " ")
+	for _, a := range authenticators {
+		if strings.EqualFold(scheme, a.Scheme()) {
+			return a.Authenticate(r, strings.TrimSpace(credentials))
+		}
+	}
+
+	return nil, ErrUnauthenticated
+}
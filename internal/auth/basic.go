@@ -0,0 +1,215 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// verifiedCacheTTL bounds how long a successful bcrypt verification is reused
+const verifiedCacheTTL = 5 * time.Minute
+
+// verifiedCacheSize bounds the number of cached verifications
+const verifiedCacheSize = 1024
+
+// dummyHash is compared against when the username is unknown, so unknown users
+// take as long to reject as wrong passwords
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("consent-service-extensions"), bcrypt.DefaultCost)
+
+// basicCredential is a username with the bcrypt hash of its password
+type basicCredential struct {
+	username string
+	hash     []byte
+}
+
+// BasicAuthenticator validates HTTP Basic credentials against bcrypt hashes.
+// A username may appear more than once so passwords can be rotated without downtime.
+type BasicAuthenticator struct {
+	// source, when set, is read on every request so rotated entries apply
+	source  func(ctx context.Context) ([]string, error)
+	entries string
+
+	mu          sync.Mutex
+	credentials []basicCredential
+	// generation counts credential changes, so verifications of replaced
+	// credentials are not cached
+	generation int
+	verified   map[[sha256.Size]byte]time.Time
+}
+
+// NewBasicAuthenticator creates a Basic authenticator from "username:bcrypt-hash" entries
+func NewBasicAuthenticator(entries []string) (*BasicAuthenticator, error) {
+	credentials, err := parseCredentials(entries)
+	if err != nil {
+		return nil, err
+	}
+	return &BasicAuthenticator{
+		entries:     strings.Join(entries, ","),
+		credentials: credentials,
+		verified:    make(map[[sha256.Size]byte]time.Time),
+	}, nil
+}
+
+// NewBasicAuthenticatorSource creates a Basic authenticator whose entries are
+// read from source on every request, e.g. through a secrets cache, so rotated
+// credentials apply without a restart. When source fails or returns invalid
+// entries the previous credentials are kept.
+func NewBasicAuthenticatorSource(ctx context.Context, source func(ctx context.Context) ([]string, error)) (*BasicAuthenticator, error) {
+	entries, err := source(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read basic auth credentials: %w", err)
+	}
+	a, err := NewBasicAuthenticator(entries)
+	if err != nil {
+		return nil, err
+	}
+	a.source = source
+	return a, nil
+}
+
+// parseCredentials parses "username:bcrypt-hash" entries
+func parseCredentials(entries []string) ([]basicCredential, error) {
+	var credentials []basicCredential
+	for i, entry := range entries {
+		username, hash, ok := strings.Cut(strings.TrimSpace(entry), ":")
+		if !ok || username == "" {
+			return nil, fmt.Errorf("basic auth credential %d: expected username:bcrypt-hash", i+1)
+		}
+		if _, err := bcrypt.Cost([]byte(hash)); err != nil {
+			return nil, fmt.Errorf("basic auth credential %d (%s): invalid bcrypt hash: %w", i+1, username, err)
+		}
+		credentials = append(credentials, basicCredential{username: username, hash: []byte(hash)})
+	}
+
+	if len(credentials) == 0 {
+		return nil, fmt.Errorf("no basic auth credentials configured")
+	}
+	return credentials, nil
+}
+
+// refresh rereads the entries from the source, replacing the credentials and
+// forgetting cached verifications when they changed. It returns the current
+// credentials and their generation.
+func (a *BasicAuthenticator) refresh(ctx context.Context) ([]basicCredential, int) {
+	var entries []string
+	var err error
+	if a.source != nil {
+		entries, err = a.source(ctx)
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.source == nil {
+		return a.credentials, a.generation
+	}
+	if err != nil {
+		slog.Warn("Failed to read basic auth credentials, keeping current ones", "error", err)
+		return a.credentials, a.generation
+	}
+	if joined := strings.Join(entries, ","); joined != a.entries {
+		credentials, err := parseCredentials(entries)
+		if err != nil {
+			slog.Error("Invalid basic auth credentials, keeping current ones", "error", err)
+			return a.credentials, a.generation
+		}
+		a.entries = joined
+		a.credentials = credentials
+		a.generation++
+		a.verified = make(map[[sha256.Size]byte]time.Time)
+		slog.Info("Basic auth credentials rotated", "count", len(credentials))
+	}
+	return a.credentials, a.generation
+}
+
+// Scheme implements Authenticator
+func (a *BasicAuthenticator) Scheme() string {
+	return "Basic"
+}
+
+// Authenticate implements Authenticator
+func (a *BasicAuthenticator) Authenticate(r *http.Request, credentials string) (*Principal, error) {
+	decoded, err := base64.StdEncoding.DecodeString(credentials)
+	if err != nil {
+		return nil, ErrInvalidCredentials
+	}
+
+	username, password, ok := strings.Cut(string(decoded), ":")
+	if !ok {
+		return nil, ErrInvalidCredentials
+	}
+
+	current, generation := a.refresh(r.Context())
+	if !a.verify(current, generation, username, password) {
+		return nil, ErrInvalidCredentials
+	}
+
+	return &Principal{Subject: username, Method: a.Scheme()}, nil
+}
+
+// verify checks a username and password against every credential
+func (a *BasicAuthenticator) verify(credentials []basicCredential, generation int, username, password string) bool {
+	key := sha256.Sum256([]byte(username + ":" + password))
+	if a.cached(key) {
+		return true
+	}
+
+	matched := false
+	compared := false
+	for _, c := range credentials {
+		if subtle.ConstantTimeCompare([]byte(c.username), []byte(username)) != 1 {
+			continue
+		}
+		compared = true
+		if bcrypt.CompareHashAndPassword(c.hash, []byte(password)) == nil {
+			matched = true
+		}
+	}
+
+	if !compared {
+		_ = bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+	}
+
+	if matched {
+		a.remember(key, generation)
+	}
+	return matched
+}
+
+// cached reports whether the credentials were verified recently
+func (a *BasicAuthenticator) cached(key [sha256.Size]byte) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	expiry, ok := a.verified[key]
+	if !ok {
+		return false
+	}
+	if time.Now().After(expiry) {
+		delete(a.verified, key)
+		return false
+	}
+	return true
+}
+
+// remember caches a successful verification of the credentials of generation
+func (a *BasicAuthenticator) remember(key [sha256.Size]byte, generation int) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if generation != a.generation {
+		return
+	}
+	if len(a.verified) >= verifiedCacheSize {
+		a.verified = make(map[[sha256.Size]byte]time.Time)
+	}
+	a.verified[key] = time.Now().Add(verifiedCacheTTL)
+}
@@ -0,0 +1,220 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"consent-service-extensions/internal/jose"
+)
+
+// ErrInsufficientScope is returned when a valid token lacks the required scope
+var ErrInsufficientScope = errors.New("insufficient scope")
+
+// defaultLeeway is the clock skew tolerated when checking exp and nbf
+const defaultLeeway = 30 * time.Second
+
+// TokenInfo holds the validated attributes of an access token
+type TokenInfo struct {
+	Subject  string
+	ClientID string
+	Scopes   []string
+}
+
+// JWTValidator validates JWT access tokens against a JWK Set
+type JWTValidator struct {
+	Keys     *KeySet
+	Issuer   string
+	Audience string
+	// Leeway is the tolerated clock skew, defaultLeeway if zero
+	Leeway time.Duration
+}
+
+// accessTokenTypes are the accepted typ header values of a JWT access token,
+// compared case-insensitively; RFC 9068 tokens use at+jwt
+var accessTokenTypes = []string{"", "jwt", "at+jwt", "application/at+jwt"}
+
+// jwtClaims holds the registered and scope claims of an access token
+type jwtClaims struct {
+	Iss      string          `json:"iss"`
+	Sub      string          `json:"sub"`
+	Aud      json.RawMessage `json:"aud"`
+	Exp      *float64        `json:"exp"`
+	Nbf      *float64        `json:"nbf"`
+	ClientID string          `json:"client_id"`
+	Azp      string          `json:"azp"`
+	Scope    string          `json:"scope"`
+	Scp      []string        `json:"scp"`
+}
+
+// Validate verifies the token signature and its iss, aud, exp and nbf claims
+func (v *JWTValidator) Validate(ctx context.Context, token string) (*TokenInfo, error) {
+	jws, err := jose.ParseCompact(token)
+	if err != nil {
+		return nil, err
+	}
+	if jws.Detached() {
+		return nil, fmt.Errorf("token has no payload")
+	}
+	// No header extensions are understood, so any critical one is rejected (RFC 7515 4.1.11)
+	if len(jws.Header.Crit) > 0 {
+		return nil, fmt.Errorf("unsupported critical header parameters %q", jws.Header.Crit)
+	}
+	if !containsString(accessTokenTypes, strings.ToLower(jws.Header.Typ)) {
+		return nil, fmt.Errorf("unexpected token type %q", jws.Header.Typ)
+	}
+
+	key, ok := v.Keys.Key(ctx, jws.Header.Kid)
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %q", jws.Header.Kid)
+	}
+	if key.Algorithm != "" && key.Algorithm != jws.Header.Alg {
+		return nil, fmt.Errorf("algorithm %s not allowed for key %q", jws.Header.Alg, key.ID)
+	}
+	if err := jws.Verify(key.Public); err != nil {
+		return nil, err
+	}
+
+	var claims jwtClaims
+	if err := json.Unmarshal(jws.Payload, &claims); err != nil {
+		return nil, fmt.Errorf("malformed token claims: %w", err)
+	}
+
+	leeway := v.Leeway
+	if leeway == 0 {
+		leeway = defaultLeeway
+	}
+	now := time.Now()
+
+	if claims.Iss != v.Issuer {
+		return nil, fmt.Errorf("unexpected issuer %q", claims.Iss)
+	}
+	if !audienceContains(claims.Aud, v.Audience) {
+		return nil, fmt.Errorf("token not issued for audience %q", v.Audience)
+	}
+	if claims.Exp == nil {
+		return nil, fmt.Errorf("token has no exp claim")
+	}
+	if now.After(unixTime(*claims.Exp).Add(leeway)) {
+		return nil, fmt.Errorf("token expired")
+	}
+	if claims.Nbf != nil && now.Add(leeway).Before(unixTime(*claims.Nbf)) {
+		return nil, fmt.Errorf("token not yet valid")
+	}
+
+	scopes := claims.Scp
+	if claims.Scope != "" {
+		scopes = strings.Fields(claims.Scope)
+	}
+
+	clientID := claims.ClientID
+	if clientID == "" {
+		clientID = claims.Azp
+	}
+
+	return &TokenInfo{Subject: claims.Sub, ClientID: clientID, Scopes: scopes}, nil
+}
+
+// BearerAuthenticator validates OAuth2 bearer tokens. JWTs are validated locally
+// against the JWK Set, other tokens are checked with token introspection.
+type BearerAuthenticator struct {
+	jwt           *JWTValidator
+	introspector  *Introspector
+	requiredScope string
+}
+
+// NewBearerAuthenticator creates a bearer authenticator. Either validator may be nil,
+// but not both. Tokens must carry requiredScope unless it is empty.
+func NewBearerAuthenticator(jwt *JWTValidator, introspector *Introspector, requiredScope string) (*BearerAuthenticator, error) {
+	if jwt == nil && introspector == nil {
+		return nil, fmt.Errorf("bearer authentication requires a JWKS or an introspection endpoint")
+	}
+	if jwt != nil && (jwt.Issuer == "" || jwt.Audience == "") {
+		return nil, fmt.Errorf("JWT validation requires an issuer and an audience")
+	}
+
+	return &BearerAuthenticator{
+		jwt:           jwt,
+		introspector:  introspector,
+		requiredScope: requiredScope,
+	}, nil
+}
+
+// Scheme implements Authenticator
+func (a *BearerAuthenticator) Scheme() string {
+	return "Bearer"
+}
+
+// Authenticate implements Authenticator
+func (a *BearerAuthenticator) Authenticate(r *http.Request, credentials string) (*Principal, error) {
+	if credentials == "" {
+		return nil, ErrUnauthenticated
+	}
+
+	var info *TokenInfo
+	var err error
+	if a.jwt != nil && looksLikeJWT(credentials) {
+		info, err = a.jwt.Validate(r.Context(), credentials)
+	} else if a.introspector != nil {
+		info, err = a.introspector.Introspect(r.Context(), credentials)
+	} else {
+		err = fmt.Errorf("opaque tokens are not accepted")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCredentials, err)
+	}
+
+	if a.requiredScope != "" && !containsString(info.Scopes, a.requiredScope) {
+		return nil, fmt.Errorf("%w: %q required", ErrInsufficientScope, a.requiredScope)
+	}
+
+	subject := info.ClientID
+	if subject == "" {
+		subject = info.Subject
+	}
+
+	return &Principal{Subject: subject, Method: a.Scheme(), Scopes: info.Scopes}, nil
+}
+
+// looksLikeJWT reports whether a token is a compact JWS with a decodable header
+func looksLikeJWT(token string) bool {
+	if strings.Count(token, ".") != 2 {
+		return false
+	}
+	_, err := jose.ParseCompact(token)
+	return err == nil
+}
+
+// audienceContains reports whether the aud claim, a string or an array, contains audience
+func audienceContains(raw json.RawMessage, audience string) bool {
+	var single string
+	if err := json.Unmarshal(raw, &single); err == nil {
+		return single == audience
+	}
+
+	var list []string
+	if err := json.Unmarshal(raw, &list); err == nil {
+		return containsString(list, audience)
+	}
+
+	return false
+}
+
+// containsString reports whether values contains s
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// unixTime converts a NumericDate claim to a time
+func unixTime(seconds float64) time.Time {
+	return time.Unix(int64(seconds), 0)
+}
@@ -0,0 +1,103 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Introspector validates opaque access tokens with an OAuth2 token introspection
+// endpoint (RFC 7662)
+type Introspector struct {
+	Endpoint     string
+	ClientID     string
+	ClientSecret string
+	// ClientSecretSource, when set, is read for every request instead of
+	// ClientSecret, so a rotated secret applies without a restart
+	ClientSecretSource func(ctx context.Context) (string, error)
+	// Issuer and Audience are checked when set and returned by the endpoint
+	Issuer   string
+	Audience string
+	Client   *http.Client
+}
+
+// introspectionResponse is the RFC 7662 introspection response
+type introspectionResponse struct {
+	Active   bool            `json:"active"`
+	Scope    string          `json:"scope"`
+	ClientID string          `json:"client_id"`
+	Sub      string          `json:"sub"`
+	Iss      string          `json:"iss"`
+	Aud      json.RawMessage `json:"aud"`
+	Exp      *float64        `json:"exp"`
+	Nbf      *float64        `json:"nbf"`
+}
+
+// Introspect asks the introspection endpoint whether the token is active
+func (i *Introspector) Introspect(ctx context.Context, token string) (*TokenInfo, error) {
+	form := url.Values{
+		"token":           {token},
+		"token_type_hint": {"access_token"},
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, i.Endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if i.ClientID != "" {
+		secret := i.ClientSecret
+		if i.ClientSecretSource != nil {
+			if secret, err = i.ClientSecretSource(ctx); err != nil {
+				return nil, fmt.Errorf("failed to read introspection client secret: %w", err)
+			}
+		}
+		req.SetBasicAuth(url.QueryEscape(i.ClientID), url.QueryEscape(secret))
+	}
+
+	client := i.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("introspection request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("introspection endpoint returned status %d", resp.StatusCode)
+	}
+
+	var ir introspectionResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&ir); err != nil {
+		return nil, fmt.Errorf("malformed introspection response: %w", err)
+	}
+
+	if !ir.Active {
+		return nil, fmt.Errorf("token is not active")
+	}
+
+	now := time.Now()
+	if ir.Exp != nil && now.After(unixTime(*ir.Exp).Add(defaultLeeway)) {
+		return nil, fmt.Errorf("token expired")
+	}
+	if ir.Nbf != nil && now.Add(defaultLeeway).Before(unixTime(*ir.Nbf)) {
+		return nil, fmt.Errorf("token not yet valid")
+	}
+	if i.Issuer != "" && ir.Iss != "" && ir.Iss != i.Issuer {
+		return nil, fmt.Errorf("unexpected issuer %q", ir.Iss)
+	}
+	if i.Audience != "" && len(ir.Aud) > 0 && !audienceContains(ir.Aud, i.Audience) {
+		return nil, fmt.Errorf("token not issued for audience %q", i.Audience)
+	}
+
+	return &TokenInfo{Subject: ir.Sub, ClientID: ir.ClientID, Scopes: strings.Fields(ir.Scope)}, nil
+}
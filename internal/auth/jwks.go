@@ -0,0 +1,190 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"consent-service-extensions/internal/jose"
+)
+
+// minOnDemandRefresh bounds how often an unknown key ID may trigger a refresh,
+// whether or not the previous attempt succeeded
+const minOnDemandRefresh = 30 * time.Second
+
+// KeySet is a cached JWK Set loaded from a local file or an HTTP(S) URL and
+// refreshed periodically
+type KeySet struct {
+	source          string
+	client          *http.Client
+	refreshInterval time.Duration
+
+	mu          sync.RWMutex
+	keys        []jose.Key
+	lastRefresh time.Time
+	// lastAttempt is when a load was last started, successful or not
+	lastAttempt time.Time
+
+	stop chan struct{}
+	once sync.Once
+}
+
+// NewKeySet loads a JWK Set from source and refreshes it every refreshInterval.
+// A zero refreshInterval disables periodic refresh.
+func NewKeySet(source string, refreshInterval time.Duration) (*KeySet, error) {
+	ks := &KeySet{
+		source:          source,
+		client:          &http.Client{Timeout: 10 * time.Second},
+		refreshInterval: refreshInterval,
+		stop:            make(chan struct{}),
+	}
+
+	if err := ks.Refresh(context.Background()); err != nil {
+		return nil, err
+	}
+
+	if refreshInterval > 0 {
+		go ks.refreshLoop(refreshInterval)
+	}
+
+	return ks, nil
+}
+
+// Refresh reloads the key set from its source. The cached keys are kept if loading fails.
+func (ks *KeySet) Refresh(ctx context.Context) error {
+	ks.mu.Lock()
+	ks.lastAttempt = time.Now()
+	ks.mu.Unlock()
+
+	data, err := ks.fetch(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load JWKS from %s: %w", ks.source, err)
+	}
+
+	keys, err := jose.ParseKeySet(data)
+	if err != nil {
+		return fmt.Errorf("failed to parse JWKS from %s: %w", ks.source, err)
+	}
+
+	ks.mu.Lock()
+	ks.keys = keys
+	ks.lastRefresh = time.Now()
+	ks.mu.Unlock()
+
+	return nil
+}
+
+// Key returns the key with the given key ID. If the ID is unknown the set is
+// refreshed once, so keys rotated at the issuer are picked up immediately.
+// At most one such refresh is started per minOnDemandRefresh: concurrent
+// misses and misses while the source is down fail without fetching.
+func (ks *KeySet) Key(ctx context.Context, kid string) (jose.Key, bool) {
+	if key, ok := ks.lookup(kid); ok {
+		return key, true
+	}
+
+	// Claim the refresh under the lock so only one caller fetches
+	ks.mu.Lock()
+	due := time.Since(ks.lastAttempt) > minOnDemandRefresh
+	if due {
+		ks.lastAttempt = time.Now()
+	}
+	ks.mu.Unlock()
+
+	if !due {
+		return jose.Key{}, false
+	}
+	if err := ks.Refresh(ctx); err != nil {
+		slog.Warn("JWKS refresh for unknown kid failed", "kid", kid, "error", err)
+		return jose.Key{}, false
+	}
+	return ks.lookup(kid)
+}
+
+// Check reports an empty key set, or one whose periodic refresh has failed
+// for three intervals in a row
+func (ks *KeySet) Check(context.Context) error {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	if len(ks.keys) == 0 {
+		return fmt.Errorf("JWKS from %s has no keys", ks.source)
+	}
+	if ks.refreshInterval > 0 && time.Since(ks.lastRefresh) > 3*ks.refreshInterval {
+		return fmt.Errorf("JWKS from %s is stale, last refreshed %s", ks.source, ks.lastRefresh.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// Close stops the periodic refresh
+func (ks *KeySet) Close() {
+	ks.once.Do(func() { close(ks.stop) })
+}
+
+// lookup finds a cached key. An empty kid matches only when the set has a single key.
+func (ks *KeySet) lookup(kid string) (jose.Key, bool) {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	if kid == "" {
+		if len(ks.keys) == 1 {
+			return ks.keys[0], true
+		}
+		return jose.Key{}, false
+	}
+
+	for _, key := range ks.keys {
+		if key.ID == kid {
+			return key, true
+		}
+	}
+	return jose.Key{}, false
+}
+
+// fetch reads the raw JWK Set document
+func (ks *KeySet) fetch(ctx context.Context) ([]byte, error) {
+	if !strings.HasPrefix(ks.source, "http://") && !strings.HasPrefix(ks.source, "https://") {
+		return os.ReadFile(ks.source)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, ks.source, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := ks.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	return io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+}
+
+// refreshLoop refreshes the key set until Close is called
+func (ks *KeySet) refreshLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ks.stop:
+			return
+		case <-ticker.C:
+			if err := ks.Refresh(context.Background()); err != nil {
+				slog.Warn("JWKS refresh failed, keeping cached keys", "error", err)
+			}
+		}
+	}
+}
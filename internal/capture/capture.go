@@ -0,0 +1,191 @@
+package capture
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"consent-service-extensions/internal/redact"
+	"consent-service-extensions/internal/requestbody"
+)
+
+// fileExt is the extension of capture files
+const fileExt = ".json"
+
+// Exchange is a captured request/response pair
+type Exchange struct {
+	RequestID string  `json:"requestId"`
+	Time      string  `json:"time"`
+	Method    string  `json:"method"`
+	Path      string  `json:"path"`
+	Request   Message `json:"request"`
+	Response  Message `json:"response"`
+}
+
+// Message is a captured request or response. JSON bodies are kept as JSON,
+// anything else as text.
+type Message struct {
+	Status int             `json:"status,omitempty"`
+	Body   json.RawMessage `json:"body,omitempty"`
+	Text   string          `json:"text,omitempty"`
+}
+
+// Payload returns the body as sent
+func (m Message) Payload() []byte {
+	if len(m.Body) > 0 {
+		return m.Body
+	}
+	return []byte(m.Text)
+}
+
+// newMessage redacts body into a Message
+func newMessage(status int, body []byte, redactor *redact.Redactor) Message {
+	m := Message{Status: status}
+	if json.Valid(body) {
+		m.Body = redactor.JSON(body)
+	} else {
+		m.Text = redactor.String(string(body))
+	}
+	return m
+}
+
+// Recorder writes each /api/services exchange to a directory, one redacted
+// JSON file per requestId. A repeated requestId overwrites its file.
+type Recorder struct {
+	dir      string
+	redactor *redact.Redactor
+	now      func() time.Time
+}
+
+// New creates a recorder writing to dir, creating it if needed. Bodies are
+// redacted with redactor, which may be nil.
+func New(dir string, redactor *redact.Redactor) (*Recorder, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	return &Recorder{dir: dir, redactor: redactor, now: time.Now}, nil
+}
+
+// Middleware captures every request with its response
+func (c *Recorder) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := requestbody.Read(r)
+
+		rw := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rw, r)
+
+		var envelope struct {
+			RequestID string `json:"requestId"`
+		}
+		_ = json.Unmarshal(body, &envelope)
+
+		x := Exchange{
+			RequestID: envelope.RequestID,
+			Time:      c.now().UTC().Format(time.RFC3339Nano),
+			Method:    r.Method,
+			Path:      r.URL.Path,
+			Request:   newMessage(0, body, c.redactor),
+			Response:  newMessage(rw.status, rw.body.Bytes(), c.redactor),
+		}
+		if err := c.write(x); err != nil {
+			slog.Warn("Failed to capture exchange", "requestId", x.RequestID, "error", err)
+		}
+	})
+}
+
+// write stores an exchange atomically
+func (c *Recorder) write(x Exchange) error {
+	data, err := json.MarshalIndent(x, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	name := fileName(x.RequestID)
+	if x.RequestID == "" {
+		name = fileName(fmt.Sprintf("no-request-id-%d", c.now().UnixNano()))
+	}
+
+	tmp, err := os.CreateTemp(c.dir, ".capture-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filepath.Join(c.dir, name))
+}
+
+// fileName maps a requestId to a safe file name
+func fileName(requestID string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_', r == '.':
+			return r
+		default:
+			return '_'
+		}
+	}, requestID) + fileExt
+}
+
+// Load reads a capture file
+func Load(path string) (*Exchange, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var x Exchange
+	if err := json.Unmarshal(data, &x); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &x, nil
+}
+
+// LoadDir reads every capture file in dir, oldest first
+func LoadDir(dir string) ([]*Exchange, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*"+fileExt))
+	if err != nil {
+		return nil, err
+	}
+
+	exchanges := make([]*Exchange, 0, len(paths))
+	for _, path := range paths {
+		x, err := Load(path)
+		if err != nil {
+			return nil, err
+		}
+		exchanges = append(exchanges, x)
+	}
+	sort.SliceStable(exchanges, func(i, j int) bool { return exchanges[i].Time < exchanges[j].Time })
+	return exchanges, nil
+}
+
+// responseRecorder keeps a copy of the response
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+// WriteHeader records the status code
+func (w *responseRecorder) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Write copies the body
+func (w *responseRecorder) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
@@ -0,0 +1,107 @@
+package capture
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"consent-service-extensions/internal/redact"
+)
+
+// Diff compares a captured response body with a replayed one and returns one
+// line per difference. Values redacted in the capture match anything, and
+// paths in ignore (dotted, "*" for any key or index) are skipped.
+func Diff(expected, actual []byte, ignore []string) []string {
+	var want, got interface{}
+	if json.Unmarshal(expected, &want) != nil || json.Unmarshal(actual, &got) != nil {
+		if string(expected) != string(actual) {
+			return []string{fmt.Sprintf("body: expected %q, got %q", expected, actual)}
+		}
+		return nil
+	}
+
+	var patterns [][]string
+	for _, p := range ignore {
+		patterns = append(patterns, strings.Split(p, "."))
+	}
+
+	var diffs []string
+	compare(nil, want, got, patterns, &diffs)
+	return diffs
+}
+
+func compare(path []string, want, got interface{}, ignore [][]string, diffs *[]string) {
+	for _, p := range ignore {
+		if matchPath(p, path) {
+			return
+		}
+	}
+	if s, ok := want.(string); ok && redact.IsRedacted(s) {
+		return
+	}
+
+	switch w := want.(type) {
+	case map[string]interface{}:
+		g, ok := got.(map[string]interface{})
+		if !ok {
+			break
+		}
+		keys := make(map[string]bool)
+		for k := range w {
+			keys[k] = true
+		}
+		for k := range g {
+			keys[k] = true
+		}
+		sorted := make([]string, 0, len(keys))
+		for k := range keys {
+			sorted = append(sorted, k)
+		}
+		sort.Strings(sorted)
+		for _, k := range sorted {
+			compare(append(path[:len(path):len(path)], k), w[k], g[k], ignore, diffs)
+		}
+		return
+	case []interface{}:
+		g, ok := got.([]interface{})
+		if !ok || len(g) != len(w) {
+			break
+		}
+		for i := range w {
+			compare(append(path[:len(path):len(path)], strconv.Itoa(i)), w[i], g[i], ignore, diffs)
+		}
+		return
+	}
+
+	if !reflect.DeepEqual(want, got) {
+		*diffs = append(*diffs, fmt.Sprintf("%s: expected %s, got %s", displayPath(path), encode(want), encode(got)))
+	}
+}
+
+// matchPath reports whether a dotted ignore pattern matches path exactly
+func matchPath(pattern, path []string) bool {
+	if len(pattern) != len(path) {
+		return false
+	}
+	for i := range pattern {
+		if pattern[i] != "*" && pattern[i] != path[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func displayPath(path []string) string {
+	if len(path) == 0 {
+		return "body"
+	}
+	return strings.Join(path, ".")
+}
+
+func encode(v interface{}) string {
+	b, _ := json.Marshal(v)
+	return string(b)
+}
@@ -0,0 +1,63 @@
+package capture
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Replayer re-sends captured requests to a server and compares the responses
+type Replayer struct {
+	// Target is the base URL of the server, e.g. http://localhost:3001
+	Target string
+	Client *http.Client
+	// Header is added to every request, e.g. for authentication
+	Header http.Header
+	// Ignore lists response paths that are expected to differ
+	Ignore []string
+}
+
+// Result is the outcome of replaying one exchange
+type Result struct {
+	Exchange *Exchange
+	Status   int
+	Diffs    []string
+}
+
+// Replay sends the captured request and diffs the response with the captured one
+func (rp *Replayer) Replay(ctx context.Context, x *Exchange) (Result, error) {
+	req, err := http.NewRequestWithContext(ctx, x.Method, strings.TrimRight(rp.Target, "/")+x.Path, bytes.NewReader(x.Request.Payload()))
+	if err != nil {
+		return Result{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, values := range rp.Header {
+		for _, v := range values {
+			req.Header.Add(k, v)
+		}
+	}
+
+	client := rp.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return Result{}, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return Result{}, err
+	}
+
+	result := Result{Exchange: x, Status: resp.StatusCode}
+	if resp.StatusCode != x.Response.Status {
+		result.Diffs = append(result.Diffs, fmt.Sprintf("HTTP status: expected %d, got %d", x.Response.Status, resp.StatusCode))
+	}
+	result.Diffs = append(result.Diffs, Diff(x.Response.Payload(), body, rp.Ignore)...)
+	return result, nil
+}
@@ -1,75 +1,590 @@
 package config
 
 import (
-	"bufio"
-	"log"
+	"context"
+	"fmt"
+	"log/slog"
 	"os"
+	"reflect"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
+
+	"consent-service-extensions/internal/secrets"
+)
+
+// Error response formats
+const (
+	// ErrorFormatSpec emits error responses with a structured data object as defined in the specification
+	ErrorFormatSpec = "spec"
+	// ErrorFormatLegacy emits the flat errorMessage/errorDescription error responses
+	ErrorFormatLegacy = "legacy"
 )
 
-// Config holds all application configuration
+// Config holds all application configuration. A field tagged with a config
+// file key and an environment variable is set from both; tags of a struct
+// field prefix the tags of its fields. sep splits list values, "," when
+// unset, and oneOf lists the accepted values of a string. A secret://name
+// value is replaced by its secret, except in fields tagged resolve:"onUse"
+// whose consumers read it through Secrets.Provider on every use, so a rotated
+// secret applies without a restart.
 type Config struct {
-	Port     string
-	LogLevel string
+	Port                string `file:"server.port" env:"PORT"`
+	LogLevel            string `file:"observability.log.level" env:"LOG_LEVEL" oneOf:"debug,info,warn,warning,error"`
+	LogFormat           string `file:"observability.log.format" env:"LOG_FORMAT" oneOf:"text,json"`
+	ErrorResponseFormat string `file:"server.errorResponseFormat" env:"ERROR_RESPONSE_FORMAT" oneOf:"spec,legacy"`
+	Server              ServerConfig
+	Readiness           ReadinessConfig
+
+	// BasicAuthCredentials holds "username:bcrypt-hash" pairs accepted for HTTP Basic authentication
+	BasicAuthCredentials []string `file:"auth.basicCredentials" env:"BASIC_AUTH_CREDENTIALS" resolve:"onUse"`
+	OAuth2               OAuth2Config
+	TLS                  TLSConfig
+	Signing              SigningConfig
+	JWS                  JWSConfig
+	FAPI                 FAPIConfig
+	Idempotency          IdempotencyConfig
+
+	// ReplayWindow is how long requestIds are remembered to reject replays, zero disables the guard
+	ReplayWindow time.Duration `file:"rules.replayWindow" env:"REPLAY_WINDOW" allowZero:"true"`
+	RateLimit    RateLimitConfig
+	Access       AccessConfig
+	NetworkACL   NetworkACLConfig
+	Redaction    RedactionConfig `file:"observability.redaction" env:"REDACT"`
+
+	// MetricsEnabled serves Prometheus metrics on /metrics
+	MetricsEnabled bool `file:"observability.metrics.enabled" env:"METRICS_ENABLED"`
+	Tracing        TracingConfig
+	Audit          AuditConfig
+
+	// CaptureDir receives one redacted request/response file per requestId, empty disables capture
+	CaptureDir string `file:"observability.capture.dir" env:"CAPTURE_DIR"`
+	Purposes   PurposesConfig
+	Rules      RulesConfig
+	Secrets    SecretsConfig
 }
 
-// Load loads configuration from environment variables and .env file
-func Load() *Config {
-	// Load .env file if it exists
-	loadEnvFile(".env")
+// SecretsConfig holds the backend that secret://name values are resolved from
+type SecretsConfig struct {
+	// Backend is "env", "file" or "vault"
+	Backend   string `file:"secrets.backend" env:"SECRETS_BACKEND" oneOf:"env,file,vault"`
+	EnvPrefix string `file:"secrets.envPrefix" env:"SECRETS_ENV_PREFIX"`
+	// Dir holds one file per secret, e.g. a mounted Kubernetes secret
+	Dir            string        `file:"secrets.dir" env:"SECRETS_DIR"`
+	VaultAddr      string        `file:"secrets.vault.addr" env:"VAULT_ADDR"`
+	VaultToken     string        `file:"secrets.vault.token" env:"VAULT_TOKEN"`
+	VaultNamespace string        `file:"secrets.vault.namespace" env:"VAULT_NAMESPACE"`
+	VaultMount     string        `file:"secrets.vault.mount" env:"VAULT_MOUNT"`
+	Timeout        time.Duration `file:"secrets.timeout" env:"SECRETS_TIMEOUT"`
+	CacheTTL       time.Duration `file:"secrets.cacheTTL" env:"SECRETS_CACHE_TTL" allowZero:"true"`
+
+	// Provider reads secret://name values, caching each secret for CacheTTL.
+	// Load sets it when a value references a secret.
+	Provider secrets.Provider
+}
 
-	cfg := &Config{
-		Port:     getEnv("PORT", "3001"),
-		LogLevel: getEnv("LOG_LEVEL", "info"),
+// Options returns the secrets provider options
+func (c SecretsConfig) Options() secrets.Options {
+	return secrets.Options{
+		Backend:   c.Backend,
+		EnvPrefix: c.EnvPrefix,
+		Dir:       c.Dir,
+		Vault: secrets.VaultOptions{
+			Addr:      c.VaultAddr,
+			Token:     c.VaultToken,
+			Namespace: c.VaultNamespace,
+			Mount:     c.VaultMount,
+			Timeout:   c.Timeout,
+		},
+		CacheTTL: c.CacheTTL,
 	}
+}
 
-	return cfg
+// Value returns a function reading value on every call, with a secret://name
+// reference resolved through the provider
+func (c SecretsConfig) Value(value string) func(context.Context) (string, error) {
+	return func(ctx context.Context) (string, error) {
+		if c.Timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, c.Timeout)
+			defer cancel()
+		}
+		return secrets.Resolve(ctx, c.Provider, value)
+	}
 }
 
-// getEnv gets an environment variable with a default value
-func getEnv(key, defaultValue string) string {
-	value := os.Getenv(key)
-	if value == "" {
-		return defaultValue
+// List returns a function reading values on every call, with each
+// secret://name reference replaced by the comma separated items of its secret
+func (c SecretsConfig) List(values []string) func(context.Context) ([]string, error) {
+	return func(ctx context.Context) ([]string, error) {
+		var items []string
+		for _, v := range values {
+			resolved, err := c.Value(v)(ctx)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, splitList(resolved, ",")...)
+		}
+		return items, nil
 	}
-	return value
 }
 
-// loadEnvFile loads environment variables from a .env file
-func loadEnvFile(filename string) {
-	file, err := os.Open(filename)
-	if err != nil {
-		// .env file is optional, so just log and continue
-		log.Printf("No %s file found, using environment variables or defaults", filename)
-		return
+// RulesConfig holds the hot reloaded rule file
+type RulesConfig struct {
+	// File holds the permission lists, purpose mapping and error mapping, empty disables it
+	File string `file:"rules.file" env:"RULES_FILE"`
+	// WatchInterval is how often the file is checked for changes, zero reloads on SIGHUP only
+	WatchInterval time.Duration `file:"rules.watchInterval" env:"RULES_WATCH_INTERVAL" allowZero:"true"`
+}
+
+// PurposesConfig holds the resolution of consent permissions to purposes
+type PurposesConfig struct {
+	// Mapping maps a permission to the purposes it resolves to. Unmapped
+	// permissions resolve to themselves.
+	Mapping map[string][]string `file:"purposes.mapping" env:"PURPOSE_MAPPING"`
+}
+
+// AuditConfig holds the settings of the decision audit log
+type AuditConfig struct {
+	// Sinks are "file" and/or "stdout", empty disables the audit log
+	Sinks []string `file:"observability.audit.sinks" env:"AUDIT_SINKS"`
+	File  string   `file:"observability.audit.file" env:"AUDIT_FILE"`
+	// MaxBytes is the size at which the file is rotated, zero disables rotation
+	MaxBytes   int64 `file:"observability.audit.maxBytes" env:"AUDIT_MAX_BYTES"`
+	MaxBackups int64 `file:"observability.audit.maxBackups" env:"AUDIT_MAX_BACKUPS"`
+}
+
+// ServerConfig holds the HTTP server limits and shutdown behaviour
+type ServerConfig struct {
+	ReadTimeout       time.Duration `file:"server.readTimeout" env:"SERVER_READ_TIMEOUT"`
+	ReadHeaderTimeout time.Duration `file:"server.readHeaderTimeout" env:"SERVER_READ_HEADER_TIMEOUT"`
+	WriteTimeout      time.Duration `file:"server.writeTimeout" env:"SERVER_WRITE_TIMEOUT"`
+	IdleTimeout       time.Duration `file:"server.idleTimeout" env:"SERVER_IDLE_TIMEOUT"`
+	MaxHeaderBytes    int64         `file:"server.maxHeaderBytes" env:"SERVER_MAX_HEADER_BYTES"`
+	// ShutdownDelay is how long /health reports draining before the listener
+	// closes, so load balancers can stop routing first
+	ShutdownDelay time.Duration `file:"server.shutdownDelay" env:"SHUTDOWN_DELAY" allowZero:"true"`
+	// ShutdownTimeout bounds how long in-flight requests may take to finish
+	ShutdownTimeout time.Duration `file:"server.shutdownTimeout" env:"SHUTDOWN_TIMEOUT"`
+}
+
+// ReadinessConfig holds the settings of the /health/ready dependency checks
+type ReadinessConfig struct {
+	// Timeout bounds each check
+	Timeout time.Duration `file:"server.readiness.timeout" env:"READINESS_CHECK_TIMEOUT"`
+	// HTTPChecks are "name=url" downstream services that must answer 2xx
+	HTTPChecks []string `file:"server.readiness.httpChecks" env:"READINESS_HTTP_CHECKS"`
+}
+
+// TracingConfig holds the settings for exporting request traces
+type TracingConfig struct {
+	// Exporter is "otlp", "file" or "none"
+	Exporter string `file:"observability.tracing.exporter" env:"TRACING_EXPORTER" oneOf:"none,otlp,file"`
+	// Endpoint is the base URL of the OTLP/HTTP collector
+	Endpoint string `file:"observability.tracing.endpoint" env:"OTEL_EXPORTER_OTLP_ENDPOINT"`
+	// Headers are "key=value" pairs sent with every OTLP export
+	Headers     []string      `file:"observability.tracing.headers" env:"OTEL_EXPORTER_OTLP_HEADERS"`
+	File        string        `file:"observability.tracing.file" env:"TRACING_FILE"`
+	ServiceName string        `file:"observability.tracing.serviceName" env:"OTEL_SERVICE_NAME"`
+	Timeout     time.Duration `file:"observability.tracing.timeout" env:"TRACING_EXPORT_TIMEOUT"`
+}
+
+// RedactionConfig holds the PII redaction policy of each sink
+type RedactionConfig struct {
+	// HashKey keys the hashes of hashed values
+	HashKey string `file:"hashKey" env:"HASH_KEY" resolve:"onUse"`
+	// Paths and Patterns apply to every sink without its own
+	Paths    []string        `file:"paths" env:"PATHS"`
+	Patterns []string        `file:"patterns" env:"PATTERNS" sep:";"`
+	Log      RedactionPolicy `file:"log" env:"LOG"`
+	Audit    RedactionPolicy `file:"audit" env:"AUDIT"`
+	Capture  RedactionPolicy `file:"capture" env:"CAPTURE"`
+}
+
+// RedactionPolicy holds the redaction settings of one sink. Empty lists use the built-in defaults.
+type RedactionPolicy struct {
+	Paths    []string `file:"paths" env:"PATHS"`
+	Patterns []string `file:"patterns" env:"PATTERNS" sep:";"`
+	// Action is "mask" or "hash"
+	Action string `file:"action" env:"ACTION" oneOf:"mask,hash"`
+}
+
+// NetworkACLConfig holds the source address allowlists and denylists per route group
+type NetworkACLConfig struct {
+	APIAllow []string `file:"server.acl.apiAllow" env:"ACL_API_ALLOW"`
+	APIDeny  []string `file:"server.acl.apiDeny" env:"ACL_API_DENY"`
+	OpsAllow []string `file:"server.acl.opsAllow" env:"ACL_OPS_ALLOW"`
+	OpsDeny  []string `file:"server.acl.opsDeny" env:"ACL_OPS_DENY"`
+	// TrustedProxies are the proxy ranges whose X-Forwarded-For header is believed
+	TrustedProxies []string `file:"server.acl.trustedProxies" env:"TRUSTED_PROXIES"`
+}
+
+// AccessConfig holds the settings for counting consent accesses against their daily frequency
+type AccessConfig struct {
+	// CounterStore is "memory", "file", "bolt" or "none"
+	CounterStore string `file:"stores.accessCounter.store" env:"ACCESS_COUNTER_STORE" oneOf:"memory,file,bolt,none"`
+	CounterFile  string `file:"stores.accessCounter.file" env:"ACCESS_COUNTER_FILE"`
+	// Timezone is the IANA time zone of the day boundary
+	Timezone string `file:"rules.accessFrequency.timezone" env:"ACCESS_COUNTER_TIMEZONE"`
+}
+
+// RateLimitConfig holds the settings for request throttling and daily quotas
+type RateLimitConfig struct {
+	// Key is the dimension limits are keyed by: "caller", "thirdPartyId" or "clientId"
+	Key string `file:"rules.rateLimit.key" env:"RATE_LIMIT_KEY" oneOf:"caller,thirdPartyId,clientId"`
+	// Limits are "endpoint=count/unit[:burst]" entries, "*" matches every other endpoint
+	Limits []string `file:"rules.rateLimit.limits" env:"RATE_LIMITS"`
+	// DailyConsentCreations is the consent creation quota per TPP and day, zero disables it
+	DailyConsentCreations int64 `file:"rules.quota.dailyConsentCreations" env:"QUOTA_DAILY_CONSENT_CREATIONS"`
+	// QuotaStore is "file", "bolt" or "memory"
+	QuotaStore string `file:"stores.quota.store" env:"QUOTA_STORE" oneOf:"memory,file,bolt"`
+	QuotaFile  string `file:"stores.quota.file" env:"QUOTA_FILE"`
+	// QuotaTimezone is the IANA time zone of the quota day boundary
+	QuotaTimezone string `file:"rules.quota.timezone" env:"QUOTA_TIMEZONE"`
+}
+
+// IdempotencyConfig holds the settings for x-idempotency-key handling on consent creation
+type IdempotencyConfig struct {
+	// Store is "memory", "file" or "none"
+	Store string `file:"stores.idempotency.store" env:"IDEMPOTENCY_STORE" oneOf:"memory,file,none"`
+	// File is the path of the file store
+	File string        `file:"stores.idempotency.file" env:"IDEMPOTENCY_FILE"`
+	TTL  time.Duration `file:"stores.idempotency.ttl" env:"IDEMPOTENCY_TTL"`
+}
+
+// FAPIConfig holds the settings for validating forwarded FAPI request headers
+type FAPIConfig struct {
+	// Profile is the header profile: "none", "fapi" or "obie"
+	Profile string `file:"rules.fapi.profile" env:"FAPI_PROFILE" oneOf:"none,fapi,obie"`
+	// MandatoryHeaders are required in addition to the profile's headers
+	MandatoryHeaders []string `file:"rules.fapi.mandatoryHeaders" env:"FAPI_MANDATORY_HEADERS"`
+}
+
+// JWSConfig holds the settings for verifying TPP detached JWS (x-jws-signature) payload signatures
+type JWSConfig struct {
+	// TPPKeysDir holds one JWK Set per TPP named <thirdPartyId>.json, empty disables verification
+	TPPKeysDir           string   `file:"auth.jws.tppJwksDir" env:"JWS_TPP_JWKS_DIR"`
+	TrustAnchor          string   `file:"auth.jws.trustAnchor" env:"JWS_TRUST_ANCHOR"`
+	Algorithms           []string `file:"auth.jws.algorithms" env:"JWS_ALGORITHMS"`
+	RequiredConsentTypes []string `file:"auth.jws.requiredConsentTypes" env:"JWS_REQUIRED_CONSENT_TYPES"`
+	// MaxAge is how old the iat claim of a signature may be, plus the tolerated clock skew
+	MaxAge time.Duration `file:"auth.jws.maxAge" env:"JWS_MAX_AGE"`
+}
+
+// SigningConfig holds the settings for message-level request and response signatures
+type SigningConfig struct {
+	// Algorithm is "hmac-sha256" or "ed25519", empty disables signing
+	Algorithm string `file:"auth.signing.algorithm" env:"SIGNING_ALGORITHM" oneOf:"hmac-sha256,ed25519"`
+	// VerifyKeys are base64 HMAC secrets or Ed25519 public keys accepted for request signatures
+	VerifyKeys []string `file:"auth.signing.verifyKeys" env:"SIGNING_VERIFY_KEYS" resolve:"onUse"`
+	// ResponseKey is the base64 HMAC secret or Ed25519 seed used to sign responses
+	ResponseKey string `file:"auth.signing.responseKey" env:"SIGNING_RESPONSE_KEY" resolve:"onUse"`
+	// MaxAge is the maximum age of a request signature
+	MaxAge time.Duration `file:"auth.signing.maxAge" env:"SIGNING_MAX_AGE"`
+}
+
+// TLSConfig holds the settings for serving over TLS and mutual TLS
+type TLSConfig struct {
+	CertFile     string `file:"tls.certFile" env:"TLS_CERT_FILE" resolve:"onUse"`
+	KeyFile      string `file:"tls.keyFile" env:"TLS_KEY_FILE" resolve:"onUse"`
+	ClientCAFile string `file:"tls.clientCAFile" env:"TLS_CLIENT_CA_FILE" resolve:"onUse"`
+	// ClientAuth is "none", "request" or "require"
+	ClientAuth       string        `file:"tls.clientAuth" env:"TLS_CLIENT_AUTH" oneOf:"none,request,require"`
+	PinnedSubjects   []string      `file:"tls.pinnedSubjects" env:"TLS_PINNED_SUBJECTS" sep:";"`
+	PinnedSPKIHashes []string      `file:"tls.pinnedSPKIHashes" env:"TLS_PINNED_SPKI_HASHES"`
+	ReloadInterval   time.Duration `file:"tls.reloadInterval" env:"TLS_RELOAD_INTERVAL" allowZero:"true"`
+}
+
+// Enabled reports whether the server should serve TLS
+func (c TLSConfig) Enabled() bool {
+	return c.CertFile != "" || c.KeyFile != ""
+}
+
+// OAuth2Config holds the settings for validating OAuth2 bearer tokens
+type OAuth2Config struct {
+	// JWKSSource is a file path or URL of the JWK Set used to verify JWT access tokens
+	JWKSSource            string        `file:"auth.oauth2.jwksSource" env:"OAUTH2_JWKS_SOURCE"`
+	JWKSRefreshInterval   time.Duration `file:"auth.oauth2.jwksRefreshInterval" env:"OAUTH2_JWKS_REFRESH_INTERVAL" allowZero:"true"`
+	Issuer                string        `file:"auth.oauth2.issuer" env:"OAUTH2_ISSUER"`
+	Audience              string        `file:"auth.oauth2.audience" env:"OAUTH2_AUDIENCE"`
+	RequiredScope         string        `file:"auth.oauth2.requiredScope" env:"OAUTH2_REQUIRED_SCOPE"`
+	IntrospectionEndpoint string        `file:"auth.oauth2.introspection.endpoint" env:"OAUTH2_INTROSPECTION_ENDPOINT"`
+	IntrospectionClientID string        `file:"auth.oauth2.introspection.clientId" env:"OAUTH2_INTROSPECTION_CLIENT_ID"`
+	IntrospectionSecret   string        `file:"auth.oauth2.introspection.clientSecret" env:"OAUTH2_INTROSPECTION_CLIENT_SECRET" resolve:"onUse"`
+}
+
+// Enabled reports whether bearer token validation is configured
+func (c OAuth2Config) Enabled() bool {
+	return c.JWKSSource != "" || c.IntrospectionEndpoint != ""
+}
+
+// defaults returns the configuration used for every unset key
+func defaults() *Config {
+	return &Config{
+		Port:                "3001",
+		LogLevel:            "info",
+		LogFormat:           "text",
+		ErrorResponseFormat: ErrorFormatSpec,
+		Server: ServerConfig{
+			ReadTimeout:       15 * time.Second,
+			ReadHeaderTimeout: 5 * time.Second,
+			WriteTimeout:      30 * time.Second,
+			IdleTimeout:       60 * time.Second,
+			MaxHeaderBytes:    1 << 20,
+			ShutdownTimeout:   30 * time.Second,
+		},
+		Readiness: ReadinessConfig{Timeout: 2 * time.Second},
+		OAuth2: OAuth2Config{
+			JWKSRefreshInterval: 15 * time.Minute,
+			RequiredScope:       "process",
+		},
+		TLS: TLSConfig{
+			ClientAuth:     "none",
+			ReloadInterval: 30 * time.Second,
+		},
+		Signing: SigningConfig{MaxAge: 5 * time.Minute},
+		JWS: JWSConfig{
+			TrustAnchor:          "openbanking.org.uk",
+			Algorithms:           []string{"PS256"},
+			RequiredConsentTypes: []string{"payments"},
+			MaxAge:               5 * time.Minute,
+		},
+		FAPI: FAPIConfig{Profile: "none"},
+		Idempotency: IdempotencyConfig{
+			Store: "memory",
+			File:  "idempotency.json",
+			TTL:   24 * time.Hour,
+		},
+		RateLimit: RateLimitConfig{
+			Key:           "caller",
+			QuotaStore:    "file",
+			QuotaFile:     "quota.json",
+			QuotaTimezone: "UTC",
+		},
+		Access: AccessConfig{
+			CounterStore: "memory",
+			CounterFile:  "access-counters.db",
+			Timezone:     "UTC",
+		},
+		MetricsEnabled: true,
+		Audit: AuditConfig{
+			File:       "audit.log",
+			MaxBytes:   100 << 20,
+			MaxBackups: 10,
+		},
+		Secrets: SecretsConfig{
+			Backend:    secrets.BackendEnv,
+			Dir:        "/etc/secrets",
+			VaultMount: "secret",
+			Timeout:    10 * time.Second,
+			CacheTTL:   5 * time.Minute,
+		},
+		Rules: RulesConfig{WatchInterval: 10 * time.Second},
+		Tracing: TracingConfig{
+			Exporter:    "none",
+			Endpoint:    "http://localhost:4318",
+			File:        "traces.jsonl",
+			ServiceName: "consent-service-extensions",
+			Timeout:     10 * time.Second,
+		},
+		Redaction: RedactionConfig{
+			Log:     RedactionPolicy{Action: "mask"},
+			Audit:   RedactionPolicy{Action: "hash"},
+			Capture: RedactionPolicy{Action: "mask"},
+		},
+	}
+}
+
+// Load loads configuration from environment variables, the env file and the
+// config file named by CONFIG_FILE, in that order of precedence. The config
+// file and the environment are validated together; loading fails with a
+// FileError listing every invalid key and variable.
+func Load() (*Config, error) {
+	// Load the env file named by ENV_FILE, or .env if it exists
+	if err := loadEnvFiles(); err != nil {
+		return nil, fmt.Errorf("failed to load env file: %w", err)
 	}
-	defer file.Close()
 
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
+	cfg := defaults()
+	fields := settings(cfg)
 
-		// Skip empty lines and comments
-		if line == "" || strings.HasPrefix(line, "#") {
-			continue
+	var problems []string
+	path := os.Getenv("CONFIG_FILE")
+	if path != "" {
+		fileProblems, err := loadConfigFile(path, fields)
+		if err != nil {
+			return nil, err
 		}
+		problems = fileProblems
+	}
+	problems = append(problems, applyEnv(fields)...)
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return nil, &FileError{File: path, Problems: problems}
+	}
+	if path != "" {
+		slog.Info("Loaded config file", "file", path)
+	}
+
+	for _, p := range []*RedactionPolicy{&cfg.Redaction.Log, &cfg.Redaction.Audit, &cfg.Redaction.Capture} {
+		if len(p.Paths) == 0 {
+			p.Paths = cfg.Redaction.Paths
+		}
+		if len(p.Patterns) == 0 {
+			p.Patterns = cfg.Redaction.Patterns
+		}
+	}
+
+	if err := resolveSecrets(&cfg.Secrets, fields); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// setting is a Config field set by a config file key and an environment variable
+type setting struct {
+	key   string
+	env   string
+	sep   string
+	oneOf []string
+	// allowZero accepts a zero duration, which turns the feature off. Other
+	// durations must be positive.
+	allowZero bool
+	// onUse keeps secret://name references for the consumer to resolve
+	onUse bool
+	field reflect.Value
+}
+
+// settings returns the tagged fields of cfg by config file key
+func settings(cfg *Config) map[string]setting {
+	fields := make(map[string]setting)
+	collectSettings(reflect.ValueOf(cfg).Elem(), "", "", fields)
+	return fields
+}
 
-		// Parse KEY=VALUE
-		parts := strings.SplitN(line, "=", 2)
-		if len(parts) != 2 {
+// collectSettings adds the tagged fields of the struct v, with their tags
+// prefixed by the tags of the enclosing field
+func collectSettings(v reflect.Value, keyPrefix, envPrefix string, fields map[string]setting) {
+	for i := 0; i < v.NumField(); i++ {
+		f := v.Type().Field(i)
+		key, env := f.Tag.Get("file"), f.Tag.Get("env")
+		if keyPrefix != "" && key != "" {
+			key = keyPrefix + "." + key
+			env = envPrefix + "_" + env
+		}
+		if f.Type.Kind() == reflect.Struct {
+			collectSettings(v.Field(i), key, env, fields)
+			continue
+		}
+		if key == "" {
 			continue
 		}
+		s := setting{
+			key:       key,
+			env:       env,
+			sep:       f.Tag.Get("sep"),
+			allowZero: f.Tag.Get("allowZero") == "true",
+			onUse:     f.Tag.Get("resolve") == "onUse",
+			field:     v.Field(i),
+		}
+		if oneOf := f.Tag.Get("oneOf"); oneOf != "" {
+			s.oneOf = strings.Split(oneOf, ",")
+		}
+		fields[key] = s
+	}
+}
 
-		key := strings.TrimSpace(parts[0])
-		value := strings.TrimSpace(parts[1])
+// applyEnv sets every field whose environment variable is set, returning the invalid ones
+func applyEnv(fields map[string]setting) []string {
+	var problems []string
+	for _, s := range fields {
+		value := os.Getenv(s.env)
+		if value == "" {
+			continue
+		}
+		if err := s.set(value, literal); err != nil {
+			problems = append(problems, s.env+": "+err.Error())
+		}
+	}
+	return problems
+}
 
-		// Only set if not already set in environment
-		if os.Getenv(key) == "" {
-			os.Setenv(key, value)
+// set validates a config file or environment variable value and stores it in
+// the field. scalar converts scalar values to strings. Empty values keep the
+// default.
+func (s setting) set(v interface{}, scalar func(interface{}) (string, error)) error {
+	var value interface{}
+	switch s.field.Interface().(type) {
+	case []string:
+		list, err := listValue(v, s.separator(), scalar)
+		if err != nil || len(list) == 0 {
+			return err
+		}
+		value = list
+	case map[string][]string:
+		mapping, err := mappingValue(v, scalar)
+		if err != nil || len(mapping) == 0 {
+			return err
+		}
+		value = mapping
+	default:
+		str, err := scalar(v)
+		if err != nil || str == "" {
+			return err
+		}
+		switch s.field.Interface().(type) {
+		case time.Duration:
+			d, err := time.ParseDuration(str)
+			if err != nil {
+				return fmt.Errorf("invalid duration %q", str)
+			}
+			if d < 0 && s.allowZero {
+				return fmt.Errorf("invalid duration %q, must not be negative", str)
+			}
+			if d <= 0 && !s.allowZero {
+				return fmt.Errorf("invalid duration %q, must be positive", str)
+			}
+			value = d
+		case int64:
+			if value, err = strconv.ParseInt(str, 10, 64); err != nil {
+				return fmt.Errorf("invalid integer %q", str)
+			}
+		case bool:
+			if value, err = strconv.ParseBool(str); err != nil {
+				return fmt.Errorf("invalid boolean %q", str)
+			}
+		default:
+			if len(s.oneOf) > 0 && !contains(s.oneOf, str) {
+				return fmt.Errorf("invalid value %q, must be one of %s", str, strings.Join(s.oneOf, ", "))
+			}
+			value = str
 		}
 	}
+	s.field.Set(reflect.ValueOf(value))
+	return nil
+}
 
-	if err := scanner.Err(); err != nil {
-		log.Printf("Error reading %s: %v", filename, err)
+// separator returns the separator of list values
+func (s setting) separator() string {
+	if s.sep == "" {
+		return ","
+	}
+	return s.sep
+}
+
+// literal returns an environment variable value as is
+func literal(v interface{}) (string, error) {
+	return v.(string), nil
+}
+
+// splitList splits s on sep, trimming items and skipping empty ones
+func splitList(s, sep string) []string {
+	var values []string
+	for _, v := range strings.Split(s, sep) {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
 	}
+	return values
 }
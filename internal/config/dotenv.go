@@ -0,0 +1,232 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// envKey matches the variable names accepted in env files
+var envKey = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.]*$`)
+
+// envEntry is a variable parsed from an env file
+type envEntry struct {
+	key   string
+	value string
+	line  int
+}
+
+// envWarning reports a line of an env file that was ignored
+type envWarning struct {
+	line   int
+	reason string
+}
+
+// loadEnvFile loads the variables of a dotenv file that are not already set
+// in the environment. Bad lines are logged with their line number and skipped.
+func loadEnvFile(filename string) error {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+
+	entries, warnings := parseEnvFile(string(data), os.Getenv)
+	for _, w := range warnings {
+		slog.Warn("Ignoring invalid env file line", "file", filename, "line", w.line, "reason", w.reason)
+	}
+	for _, e := range entries {
+		// Only set if not already set in environment
+		if os.Getenv(e.key) == "" {
+			os.Setenv(e.key, e.value)
+		}
+	}
+	return nil
+}
+
+// loadEnvFiles loads the env file named by ENV_FILE, which must exist, or
+// the optional .env file in the working directory
+func loadEnvFiles() error {
+	if filename := os.Getenv("ENV_FILE"); filename != "" {
+		return loadEnvFile(filename)
+	}
+	err := loadEnvFile(".env")
+	if errors.Is(err, fs.ErrNotExist) {
+		// .env file is optional, so just log and continue
+		slog.Info("No env file found, using environment variables or defaults", "file", ".env")
+		return nil
+	}
+	return err
+}
+
+// parseEnvFile parses dotenv syntax:
+//
+//   - KEY=value, optionally prefixed with "export"
+//   - blank lines and lines starting with # are skipped
+//   - unquoted values end at a " #" inline comment and are trimmed
+//   - 'single quoted' values are literal
+//   - "double quoted" values interpret \n, \r, \t, \", \\ and \$ escapes
+//   - quoted values may span lines, e.g. PEM blocks
+//   - $NAME, ${NAME} and ${NAME:-default} expand in unquoted and double
+//     quoted values, from lookup or the variables defined earlier in the file
+func parseEnvFile(data string, lookup func(string) string) ([]envEntry, []envWarning) {
+	var entries []envEntry
+	var warnings []envWarning
+	defined := make(map[string]string)
+	resolve := func(name string) string {
+		if v := lookup(name); v != "" {
+			return v
+		}
+		return defined[name]
+	}
+
+	lines := strings.Split(strings.ReplaceAll(data, "\r\n", "\n"), "\n")
+	for i := 0; i < len(lines); i++ {
+		lineNo := i + 1
+		line := strings.TrimSpace(lines[i])
+
+		// Skip empty lines and comments
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if rest, ok := strings.CutPrefix(line, "export"); ok && rest != "" && (rest[0] == ' ' || rest[0] == '\t') {
+			line = strings.TrimSpace(rest)
+		}
+
+		key, rest, ok := strings.Cut(line, "=")
+		if !ok {
+			warnings = append(warnings, envWarning{lineNo, "expected KEY=VALUE"})
+			continue
+		}
+		key = strings.TrimSpace(key)
+		if !envKey.MatchString(key) {
+			warnings = append(warnings, envWarning{lineNo, fmt.Sprintf("invalid variable name %q", key)})
+			continue
+		}
+		rest = strings.TrimLeft(rest, " \t")
+
+		var value string
+		if rest != "" && (rest[0] == '"' || rest[0] == '\'') {
+			q := rest[0]
+			body := rest[1:]
+			end := closingQuote(body, q)
+			// Quoted values continue on the following lines until the closing quote
+			for end < 0 && i+1 < len(lines) {
+				i++
+				body += "\n" + lines[i]
+				end = closingQuote(body, q)
+			}
+			if end < 0 {
+				// Resume on the line after the opening quote so later entries are kept
+				i = lineNo - 1
+				warnings = append(warnings, envWarning{lineNo, "unterminated quoted value"})
+				continue
+			}
+			if trailing := strings.TrimSpace(body[end+1:]); trailing != "" && !strings.HasPrefix(trailing, "#") {
+				warnings = append(warnings, envWarning{lineNo, "unexpected characters after quoted value"})
+				continue
+			}
+			value = body[:end]
+			if q == '"' {
+				value = expandEnvValue(value, true, resolve)
+			}
+		} else {
+			value = strings.TrimSpace(stripInlineComment(rest))
+			value = expandEnvValue(value, false, resolve)
+		}
+
+		entries = append(entries, envEntry{key: key, value: value, line: lineNo})
+		defined[key] = value
+	}
+	return entries, warnings
+}
+
+// closingQuote returns the index of the quote ending a value, skipping
+// backslash escaped quotes in double quoted values, or -1
+func closingQuote(s string, q byte) int {
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && q == '"':
+			i++
+		case s[i] == q:
+			return i
+		}
+	}
+	return -1
+}
+
+// stripInlineComment removes a # comment preceded by whitespace
+func stripInlineComment(s string) string {
+	for i := 1; i < len(s); i++ {
+		if s[i] == '#' && (s[i-1] == ' ' || s[i-1] == '\t') {
+			return s[:i]
+		}
+	}
+	return s
+}
+
+// expandEnvValue expands variable references and, for double quoted values,
+// backslash escapes
+func expandEnvValue(s string, escapes bool, resolve func(string) string) string {
+	if !strings.ContainsAny(s, `$\`) {
+		return s
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '\\' && escapes && i+1 < len(s):
+			i++
+			switch s[i] {
+			case 'n':
+				b.WriteByte('\n')
+			case 'r':
+				b.WriteByte('\r')
+			case 't':
+				b.WriteByte('\t')
+			case '"', '\\', '$':
+				b.WriteByte(s[i])
+			default:
+				b.WriteByte('\\')
+				b.WriteByte(s[i])
+			}
+		case c == '$' && i+1 < len(s) && s[i+1] == '{':
+			end := strings.IndexByte(s[i:], '}')
+			if end < 0 {
+				b.WriteString(s[i:])
+				return b.String()
+			}
+			name, fallback, hasDefault := strings.Cut(s[i+2:i+end], ":-")
+			value := resolve(name)
+			if value == "" && hasDefault {
+				value = fallback
+			}
+			b.WriteString(value)
+			i += end
+		case c == '$' && i+1 < len(s) && isNameStart(s[i+1]):
+			j := i + 1
+			for j < len(s) && isNameChar(s[j]) {
+				j++
+			}
+			b.WriteString(resolve(s[i+1 : j]))
+			i = j - 1
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// isNameStart reports whether c may start a variable name
+func isNameStart(c byte) bool {
+	return c == '_' || (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z')
+}
+
+// isNameChar reports whether c may continue a variable name
+func isNameChar(c byte) bool {
+	return isNameStart(c) || (c >= '0' && c <= '9')
+}
@@ -0,0 +1,205 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// interpolation matches ${NAME} references to environment variables
+var interpolation = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// FileError lists every invalid key of the config file and every invalid
+// environment variable. File is empty without a config file.
+type FileError struct {
+	File     string
+	Problems []string
+}
+
+func (e *FileError) Error() string {
+	if e.File == "" {
+		return "invalid configuration: " + strings.Join(e.Problems, "; ")
+	}
+	return fmt.Sprintf("invalid configuration from %s and the environment: %s", e.File, strings.Join(e.Problems, "; "))
+}
+
+// loadConfigFile decodes a YAML or JSON config file, selected by its
+// extension, into the fields of its keys. ${NAME} references in string values
+// are replaced by the environment variable NAME, so secrets can stay out of
+// the file. Every key is validated and the invalid ones are returned together.
+func loadConfigFile(path string, fields map[string]setting) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	var doc map[string]interface{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.UseNumber()
+		err = dec.Decode(&doc)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &doc)
+	default:
+		return nil, fmt.Errorf("unsupported config file extension %q, use .yaml, .yml or .json", filepath.Ext(path))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	var problems []string
+	walkFile("", doc, fields, func(key string, v interface{}) {
+		s, ok := fields[key]
+		if !ok {
+			problems = append(problems, key+": unknown key")
+			return
+		}
+		if err := s.set(v, scalarValue); err != nil {
+			problems = append(problems, key+": "+err.Error())
+		}
+	})
+	return problems, nil
+}
+
+// walkFile calls leaf for every known key or unknown leaf, and descends into
+// the sections in between
+func walkFile(prefix string, node map[string]interface{}, fields map[string]setting, leaf func(key string, v interface{})) {
+	for name, v := range node {
+		key := name
+		if prefix != "" {
+			key = prefix + "." + name
+		}
+		child, isMap := v.(map[string]interface{})
+		if _, known := fields[key]; known || !isMap || !isSection(key, fields) {
+			leaf(key, v)
+			continue
+		}
+		walkFile(key, child, fields, leaf)
+	}
+}
+
+// isSection reports whether key is the section of a known key
+func isSection(key string, fields map[string]setting) bool {
+	for k := range fields {
+		if strings.HasPrefix(k, key+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// scalarValue returns a string, number or boolean as a string, with ${NAME}
+// references interpolated
+func scalarValue(v interface{}) (string, error) {
+	switch val := v.(type) {
+	case string:
+		return interpolate(val)
+	case bool, int, int64, uint64, float64, json.Number:
+		return fmt.Sprint(val), nil
+	case nil:
+		return "", nil
+	default:
+		return "", fmt.Errorf("expected a scalar value, got %s", describe(v))
+	}
+}
+
+// listValue returns a list, or a string split on sep, skipping empty items
+func listValue(v interface{}, sep string, scalar func(interface{}) (string, error)) ([]string, error) {
+	items, ok := v.([]interface{})
+	if !ok {
+		s, err := scalar(v)
+		if err != nil {
+			return nil, err
+		}
+		return splitList(s, sep), nil
+	}
+	var values []string
+	for i, item := range items {
+		s, err := scalar(item)
+		if err != nil {
+			return nil, fmt.Errorf("item %d: %w", i, err)
+		}
+		if s = strings.TrimSpace(s); s != "" {
+			values = append(values, s)
+		}
+	}
+	return values, nil
+}
+
+// mappingValue returns a mapping of lists, or parses a "key=a,b;key=c" string
+func mappingValue(v interface{}, scalar func(interface{}) (string, error)) (map[string][]string, error) {
+	mapping := make(map[string][]string)
+	if m, ok := v.(map[string]interface{}); ok {
+		for k, item := range m {
+			list, err := listValue(item, ",", scalar)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", k, err)
+			}
+			mapping[k] = list
+		}
+		return mapping, nil
+	}
+	if _, ok := v.(string); !ok {
+		return nil, fmt.Errorf("expected a mapping, got %s", describe(v))
+	}
+
+	s, err := scalar(v)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range splitList(s, ";") {
+		k, list, ok := strings.Cut(entry, "=")
+		if k = strings.TrimSpace(k); !ok || k == "" {
+			return nil, fmt.Errorf("invalid mapping entry %q", entry)
+		}
+		mapping[k] = append(mapping[k], splitList(list, ",")...)
+	}
+	return mapping, nil
+}
+
+// interpolate replaces ${NAME} references with environment variables, failing
+// on unset ones so a missing secret is not silently configured as empty
+func interpolate(s string) (string, error) {
+	var missing []string
+	out := interpolation.ReplaceAllStringFunc(s, func(ref string) string {
+		name := interpolation.FindStringSubmatch(ref)[1]
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			missing = append(missing, name)
+		}
+		return value
+	})
+	if len(missing) > 0 {
+		return "", fmt.Errorf("environment variable %s is not set", strings.Join(missing, ", "))
+	}
+	return out, nil
+}
+
+// describe names the type of a decoded value for error messages
+func describe(v interface{}) string {
+	switch v.(type) {
+	case map[string]interface{}:
+		return "a mapping"
+	case []interface{}:
+		return "a list"
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
+
+// contains reports whether values contains s
+func contains(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,69 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"consent-service-extensions/internal/secrets"
+)
+
+// resolveSecrets reads every string and list item that is a secret://name
+// reference from the configured backend, keeping the cached provider in cfg.
+// References are replaced by their secret, a list item by the items of its
+// secret, except in fields resolved on use, which keep the reference once it
+// is known to resolve. All references are attempted before the unresolved
+// ones are reported.
+func resolveSecrets(cfg *SecretsConfig, fields map[string]setting) error {
+	var providerErr error
+	var problems []string
+	resolve := func(s setting, ref string) (string, bool) {
+		if _, ok := secrets.Ref(ref); !ok {
+			return ref, false
+		}
+		if cfg.Provider == nil && providerErr == nil {
+			cfg.Provider, providerErr = secrets.New(cfg.Options())
+		}
+		if providerErr != nil {
+			return "", true
+		}
+
+		value, err := cfg.Value(ref)(context.Background())
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", s.env, err))
+		}
+		return value, true
+	}
+
+	for _, s := range fields {
+		switch value := s.field.Interface().(type) {
+		case string:
+			if resolved, ok := resolve(s, value); ok && !s.onUse {
+				s.field.SetString(resolved)
+			}
+		case []string:
+			var items []string
+			for _, item := range value {
+				resolved, ok := resolve(s, item)
+				if !ok || s.onUse {
+					items = append(items, item)
+					continue
+				}
+				items = append(items, splitList(resolved, s.separator())...)
+			}
+			s.field.Set(reflect.ValueOf(items))
+		}
+	}
+
+	if providerErr != nil {
+		cfg.Provider = nil
+		return fmt.Errorf("invalid secrets configuration: %w", providerErr)
+	}
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return fmt.Errorf("failed to resolve secrets: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
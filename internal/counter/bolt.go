@@ -0,0 +1,113 @@
+package counter
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// boltBucket is the BoltDB bucket holding the counters
+var boltBucket = []byte("counters")
+
+// BoltStore keeps counters in an embedded BoltDB file
+type BoltStore struct {
+	db *bolt.DB
+}
+
+// NewBoltStore opens or creates the BoltDB file at path
+func NewBoltStore(path string) (*BoltStore, error) {
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open counter store %s: %w", path, err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(boltBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialise counter store %s: %w", path, err)
+	}
+
+	return &BoltStore{db: db}, nil
+}
+
+// Increment implements Store
+func (s *BoltStore) Increment(_ context.Context, key, day string, limit int64) (int64, error) {
+	t, err := time.Parse(dayLayout, day)
+	if err != nil {
+		return 0, fmt.Errorf("invalid counter day %q", day)
+	}
+
+	var count int64
+	err = s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(boltBucket)
+
+		// Keys start with the day, so stale counters sort first
+		cutoff := t.AddDate(0, 0, -1).Format(dayLayout)
+		var stale [][]byte
+		c := b.Cursor()
+		for k, _ := c.First(); k != nil && string(k) < cutoff; k, _ = c.Next() {
+			stale = append(stale, append([]byte(nil), k...))
+		}
+		for _, k := range stale {
+			if err := b.Delete(k); err != nil {
+				return err
+			}
+		}
+
+		id := []byte(counterID(key, day))
+		count = decodeCount(b.Get(id))
+		if limit > 0 && count >= limit {
+			return ErrLimitExceeded
+		}
+		count++
+		return b.Put(id, encodeCount(count))
+	})
+	return count, err
+}
+
+// Decrement implements Store
+func (s *BoltStore) Decrement(_ context.Context, key, day string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(boltBucket)
+		id := []byte(counterID(key, day))
+		count := decodeCount(b.Get(id))
+		if count <= 1 {
+			return b.Delete(id)
+		}
+		return b.Put(id, encodeCount(count-1))
+	})
+}
+
+// Count implements Store
+func (s *BoltStore) Count(_ context.Context, key, day string) (int64, error) {
+	var count int64
+	err := s.db.View(func(tx *bolt.Tx) error {
+		count = decodeCount(tx.Bucket(boltBucket).Get([]byte(counterID(key, day))))
+		return nil
+	})
+	return count, err
+}
+
+// Close implements Store
+func (s *BoltStore) Close() error {
+	return s.db.Close()
+}
+
+// encodeCount encodes a counter value for BoltDB
+func encodeCount(count int64) []byte {
+	return binary.BigEndian.AppendUint64(nil, uint64(count))
+}
+
+// decodeCount decodes a counter value, a missing value is zero
+func decodeCount(v []byte) int64 {
+	if len(v) != 8 {
+		return 0
+	}
+	return int64(binary.BigEndian.Uint64(v))
+}
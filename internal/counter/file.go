@@ -0,0 +1,137 @@
+package counter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// fileCounter is the persisted form of a counter
+type fileCounter struct {
+	Key   string `json:"key"`
+	Day   string `json:"day"`
+	Count int64  `json:"count"`
+}
+
+// FileStore keeps counters in memory and persists them to a JSON file, so
+// they survive restarts without an external database
+type FileStore struct {
+	path string
+
+	mu     sync.Mutex
+	counts map[string]int64
+}
+
+// NewFileStore opens the store file at path, creating it on the first write
+func NewFileStore(path string) (*FileStore, error) {
+	s := &FileStore{path: path, counts: make(map[string]int64)}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read counter store %s: %w", path, err)
+	}
+
+	var counters []fileCounter
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &counters); err != nil {
+			return nil, fmt.Errorf("failed to parse counter store %s: %w", path, err)
+		}
+	}
+	for _, c := range counters {
+		if _, err := time.Parse(dayLayout, c.Day); err != nil {
+			continue
+		}
+		s.counts[counterID(c.Key, c.Day)] = c.Count
+	}
+
+	return s, nil
+}
+
+// Increment implements Store
+func (s *FileStore) Increment(_ context.Context, key, day string, limit int64) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	count, err := increment(s.counts, key, day, limit)
+	if err != nil {
+		return count, err
+	}
+	if err := s.flush(); err != nil {
+		// Keep memory consistent with the file
+		s.counts[counterID(key, day)]--
+		return count - 1, err
+	}
+	return count, nil
+}
+
+// Decrement implements Store
+func (s *FileStore) Decrement(_ context.Context, key, day string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !decrement(s.counts, key, day) {
+		return nil
+	}
+	if err := s.flush(); err != nil {
+		// Keep memory consistent with the file
+		s.counts[counterID(key, day)]++
+		return err
+	}
+	return nil
+}
+
+// Count implements Store
+func (s *FileStore) Count(_ context.Context, key, day string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.counts[counterID(key, day)], nil
+}
+
+// Close implements Store
+func (s *FileStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.flush()
+}
+
+// flush atomically rewrites the store file
+func (s *FileStore) flush() error {
+	counters := make([]fileCounter, 0, len(s.counts))
+	for id, count := range s.counts {
+		day, key := id[:len(dayLayout)], id[len(dayLayout)+1:]
+		counters = append(counters, fileCounter{Key: key, Day: day, Count: count})
+	}
+
+	data, err := json.Marshal(counters)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to write counter store: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write counter store: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write counter store: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write counter store: %w", err)
+	}
+
+	return os.Rename(tmp.Name(), s.path)
+}
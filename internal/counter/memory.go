@@ -0,0 +1,44 @@
+package counter
+
+import (
+	"context"
+	"sync"
+)
+
+// MemoryStore keeps counters in memory
+type MemoryStore struct {
+	mu     sync.Mutex
+	counts map[string]int64
+}
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{counts: make(map[string]int64)}
+}
+
+// Increment implements Store
+func (s *MemoryStore) Increment(_ context.Context, key, day string, limit int64) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return increment(s.counts, key, day, limit)
+}
+
+// Decrement implements Store
+func (s *MemoryStore) Decrement(_ context.Context, key, day string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	decrement(s.counts, key, day)
+	return nil
+}
+
+// Count implements Store
+func (s *MemoryStore) Count(_ context.Context, key, day string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.counts[counterID(key, day)], nil
+}
+
+// Close implements Store
+func (s *MemoryStore) Close() error {
+	return nil
+}
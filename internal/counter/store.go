@@ -0,0 +1,102 @@
+package counter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrLimitExceeded is returned when a counter has already reached its limit
+var ErrLimitExceeded = errors.New("limit exceeded")
+
+// dayLayout is the calendar day format used as counter day keys
+const dayLayout = "2006-01-02"
+
+// Store counts events per key and calendar day
+type Store interface {
+	// Increment adds one to the counter for key on day and returns the new
+	// count. When limit is positive and the counter has already reached it, the
+	// counter is left unchanged and ErrLimitExceeded is returned.
+	Increment(ctx context.Context, key, day string, limit int64) (int64, error)
+	// Decrement takes back one Increment for key on day whose event did not
+	// happen. A counter at zero is left unchanged.
+	Decrement(ctx context.Context, key, day string) error
+	// Count returns the counter for key on day
+	Count(ctx context.Context, key, day string) (int64, error)
+	// Close releases the store's resources
+	Close() error
+}
+
+// Day returns the calendar day of t in loc, used as the day key of a counter
+func Day(t time.Time, loc *time.Location) string {
+	return t.In(loc).Format(dayLayout)
+}
+
+// NextDay returns the start of the calendar day following t in loc
+func NextDay(t time.Time, loc *time.Location) time.Time {
+	y, m, d := t.In(loc).Date()
+	return time.Date(y, m, d+1, 0, 0, 0, 0, loc)
+}
+
+// Open creates a store of the given kind: "memory", "file" (a JSON file at
+// path) or "bolt" (an embedded BoltDB file at path)
+func Open(kind, path string) (Store, error) {
+	switch kind {
+	case "memory":
+		return NewMemoryStore(), nil
+	case "file":
+		return NewFileStore(path)
+	case "bolt":
+		return NewBoltStore(path)
+	default:
+		return nil, fmt.Errorf("unknown counter store %q", kind)
+	}
+}
+
+// counterID is the key under which a counter is stored
+func counterID(key, day string) string {
+	return day + "\x00" + key
+}
+
+// increment applies Increment to a map of counters, pruning counters older
+// than the day before day so the map stays bounded
+func increment(counts map[string]int64, key, day string, limit int64) (int64, error) {
+	t, err := time.Parse(dayLayout, day)
+	if err != nil {
+		return 0, fmt.Errorf("invalid counter day %q", day)
+	}
+	pruneBefore(counts, t)
+
+	id := counterID(key, day)
+	if limit > 0 && counts[id] >= limit {
+		return counts[id], ErrLimitExceeded
+	}
+	counts[id]++
+	return counts[id], nil
+}
+
+// decrement applies Decrement to a map of counters, reporting whether the
+// counter changed
+func decrement(counts map[string]int64, key, day string) bool {
+	id := counterID(key, day)
+	if counts[id] <= 0 {
+		return false
+	}
+	if counts[id]--; counts[id] == 0 {
+		delete(counts, id)
+	}
+	return true
+}
+
+// pruneBefore removes counters for days before the day preceding day. The
+// previous day is kept because keys may use different time zones.
+func pruneBefore(counts map[string]int64, day time.Time) {
+	cutoff := day.AddDate(0, 0, -1).Format(dayLayout)
+
+	for id := range counts {
+		if id[:len(dayLayout)] < cutoff {
+			delete(counts, id)
+		}
+	}
+}
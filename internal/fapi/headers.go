@@ -0,0 +1,155 @@
+package fapi
+
+import (
+	"crypto/rand"
+	"fmt"
+	"net"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"consent-service-extensions/internal/apperrors"
+)
+
+// FAPI and OBIE request headers
+const (
+	HeaderInteractionID     = "x-fapi-interaction-id"
+	HeaderAuthDate          = "x-fapi-auth-date"
+	HeaderCustomerIPAddress = "x-fapi-customer-ip-address"
+	HeaderIdempotencyKey    = "x-idempotency-key"
+)
+
+// uuidPattern matches an RFC 4122 UUID
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// maxIdempotencyKeyLength is the OBIE limit for x-idempotency-key
+const maxIdempotencyKeyLength = 40
+
+// Profile lists the headers that must be present for a regulatory profile
+type Profile struct {
+	Name string
+	// Mandatory headers are required on every request
+	Mandatory []string
+	// MandatoryByConsentType lists additional headers required per consent type
+	MandatoryByConsentType map[string][]string
+}
+
+// Profiles are the built-in header profiles
+var Profiles = map[string]Profile{
+	"none": {Name: "none"},
+	"fapi": {
+		Name:      "fapi",
+		Mandatory: []string{HeaderInteractionID},
+	},
+	"obie": {
+		Name:      "obie",
+		Mandatory: []string{HeaderInteractionID},
+		MandatoryByConsentType: map[string][]string{
+			"payments": {HeaderIdempotencyKey},
+		},
+	},
+}
+
+// Validator checks the formats of FAPI headers and enforces the mandatory
+// headers of the active profile
+type Validator struct {
+	profile Profile
+}
+
+// NewValidator creates a validator for a built-in profile, with optional extra mandatory headers
+func NewValidator(profileName string, extraMandatory ...string) (*Validator, error) {
+	profile, ok := Profiles[strings.ToLower(profileName)]
+	if !ok {
+		return nil, fmt.Errorf("unknown FAPI header profile %q", profileName)
+	}
+
+	mandatory := append([]string{}, profile.Mandatory...)
+	for _, h := range extraMandatory {
+		mandatory = append(mandatory, strings.ToLower(h))
+	}
+	profile.Mandatory = mandatory
+
+	return &Validator{profile: profile}, nil
+}
+
+// Profile returns the name of the active profile
+func (v *Validator) Profile() string {
+	return v.profile.Name
+}
+
+// Validate checks the forwarded request headers of a consent request and
+// returns a catalogue business error for a missing or malformed header
+func (v *Validator) Validate(headers map[string]interface{}, consentType string) error {
+	mandatory := make([]string, 0, len(v.profile.Mandatory))
+	mandatory = append(mandatory, v.profile.Mandatory...)
+	mandatory = append(mandatory, v.profile.MandatoryByConsentType[strings.ToLower(consentType)]...)
+	for _, name := range mandatory {
+		if Get(headers, name) == "" {
+			return apperrors.ErrHeaderMissing.WithParam("header", name)
+		}
+	}
+
+	if id := Get(headers, HeaderInteractionID); id != "" && !uuidPattern.MatchString(id) {
+		return apperrors.ErrHeaderInvalid.WithParam("header", HeaderInteractionID).WithParam("reason", "must be a UUID")
+	}
+
+	if date := Get(headers, HeaderAuthDate); date != "" {
+		if _, err := http.ParseTime(date); err != nil {
+			return apperrors.ErrHeaderInvalid.WithParam("header", HeaderAuthDate).WithParam("reason", "must be an RFC 7231 HTTP date")
+		}
+	}
+
+	if ip := Get(headers, HeaderCustomerIPAddress); ip != "" && net.ParseIP(ip) == nil {
+		return apperrors.ErrHeaderInvalid.WithParam("header", HeaderCustomerIPAddress).WithParam("reason", "must be an IP address")
+	}
+
+	if key := Get(headers, HeaderIdempotencyKey); len(key) > maxIdempotencyKeyLength || strings.TrimSpace(key) != key {
+		return apperrors.ErrHeaderInvalid.WithParam("header", HeaderIdempotencyKey).WithParam("reason", fmt.Sprintf("must be at most %d characters without surrounding whitespace", maxIdempotencyKeyLength))
+	}
+
+	return nil
+}
+
+// Normalize returns a copy of the forwarded request headers with lower-case names
+func Normalize(headers map[string]interface{}) map[string]interface{} {
+	if headers == nil {
+		return nil
+	}
+	normalized := make(map[string]interface{}, len(headers))
+	for k, v := range headers {
+		normalized[strings.ToLower(k)] = v
+	}
+	return normalized
+}
+
+// Get returns a forwarded request header, matching the name case-insensitively.
+// Multi-valued headers return their first value.
+func Get(headers map[string]interface{}, name string) string {
+	for k, v := range headers {
+		if !strings.EqualFold(k, name) {
+			continue
+		}
+		switch val := v.(type) {
+		case string:
+			return val
+		case []interface{}:
+			if len(val) > 0 {
+				if s, ok := val[0].(string); ok {
+					return s
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// NewInteractionID generates a random (version 4) UUID for x-fapi-interaction-id
+func NewInteractionID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(err)
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
@@ -1,21 +1,118 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
-	"log"
+	"io"
 	"net/http"
+	"time"
 
+	"consent-service-extensions/internal/apperrors"
+	"consent-service-extensions/internal/audit"
+	"consent-service-extensions/internal/config"
+	"consent-service-extensions/internal/counter"
+	"consent-service-extensions/internal/fapi"
+	"consent-service-extensions/internal/idempotency"
+	"consent-service-extensions/internal/logging"
 	"consent-service-extensions/internal/models"
+	"consent-service-extensions/internal/ratelimit"
+	"consent-service-extensions/internal/response"
+	"consent-service-extensions/internal/rules"
+	"consent-service-extensions/internal/tppjws"
+	"consent-service-extensions/internal/tracing"
 )
 
 // ConsentHandler handles consent-related operations
 type ConsentHandler struct {
-	// Add dependencies here (e.g., database, services)
+	errorWriter       response.ErrorWriter
+	signatureVerifier *tppjws.Verifier
+	headerValidator   *fapi.Validator
+	idempotencyStore  idempotency.Store
+	idempotencyTTL    time.Duration
+	accessCounter     counter.Store
+	accessLocation    *time.Location
+	purposeMapping    map[string][]string
+	rules             *rules.Store
+	observer          Observer
+}
+
+// Option configures a ConsentHandler
+type Option func(*ConsentHandler)
+
+// WithErrorResponseFormat sets the error response format (config.ErrorFormatSpec or config.ErrorFormatLegacy)
+func WithErrorResponseFormat(format string) Option {
+	return func(h *ConsentHandler) {
+		h.errorWriter.Format = format
+	}
+}
+
+// WithSignatureVerifier verifies the TPP's x-jws-signature on consent and file payloads
+func WithSignatureVerifier(v *tppjws.Verifier) Option {
+	return func(h *ConsentHandler) {
+		h.signatureVerifier = v
+	}
+}
+
+// WithHeaderValidator sets the FAPI header validator, which defaults to format checks only
+func WithHeaderValidator(v *fapi.Validator) Option {
+	return func(h *ConsentHandler) {
+		h.headerValidator = v
+	}
+}
+
+// WithIdempotencyStore makes consent creation idempotent per x-idempotency-key,
+// remembering responses in store for ttl
+func WithIdempotencyStore(store idempotency.Store, ttl time.Duration) Option {
+	return func(h *ConsentHandler) {
+		h.idempotencyStore = store
+		h.idempotencyTTL = ttl
+	}
+}
+
+// WithAccessCounter enforces the daily access frequency of consents on retrieval,
+// counting accesses in store per calendar day in loc
+func WithAccessCounter(store counter.Store, loc *time.Location) Option {
+	return func(h *ConsentHandler) {
+		h.accessCounter = store
+		h.accessLocation = loc
+	}
+}
+
+// WithPurposeMapping resolves each permission to the purposes mapped to it
+// instead of to the permission itself
+func WithPurposeMapping(mapping map[string][]string) Option {
+	return func(h *ConsentHandler) {
+		h.purposeMapping = mapping
+	}
+}
+
+// WithRules applies the permission lists, purpose mapping and error mapping of
+// the store's active rule set, which may be swapped while serving
+func WithRules(store *rules.Store) Option {
+	return func(h *ConsentHandler) {
+		h.rules = store
+	}
+}
+
+// WithObserver reports business rejections, resolved purposes and decode failures to o
+func WithObserver(o Observer) Option {
+	return func(h *ConsentHandler) {
+		h.observer = o
+	}
 }
 
 // NewConsentHandler creates a new consent handler
-func NewConsentHandler() *ConsentHandler {
-	return &ConsentHandler{}
+func NewConsentHandler(opts ...Option) *ConsentHandler {
+	defaultValidator, _ := fapi.NewValidator("none")
+	h := &ConsentHandler{
+		errorWriter:     response.ErrorWriter{Format: config.ErrorFormatSpec},
+		headerValidator: defaultValidator,
+		observer:        nopObserver{},
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
 }
 
 // PreProcessConsentCreation handles pre validations & obtains custom consent data to be stored
@@ -23,22 +120,40 @@ func (h *ConsentHandler) PreProcessConsentCreation(w http.ResponseWriter, r *htt
 	var req models.PreProcessConsentCreationRequest
 
 	// Decode request body
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		log.Printf("Error decoding request: %v", err)
-		h.sendErrorResponse(w, http.StatusBadRequest, "invalid_request", "Invalid request body", req.RequestID)
+	body, err := h.decodeRequest(r, &req)
+	if err != nil {
+		logging.FromContext(r.Context()).Warn("Error decoding request", "error", err)
+		h.observer.DecodeFailure(r.Context(), r.URL.Path)
+		h.sendErrorResponse(w, r, req.Data.RequestHeaders, http.StatusBadRequest, "invalid_request", "Invalid request body", err.Error(), req.RequestID)
 		return
 	}
 
 	// Log the request
-	log.Printf("Received pre-process-consent-creation request with ID: %s", req.RequestID)
+	logging.FromContext(r.Context()).Info("Received pre-process-consent-creation request")
+	traceRequest(r.Context(), req.RequestID, req.Data.RequestHeaders)
 
-	// TODO: Implement your business logic here
-	// - Validate consent data
-	// - Apply business rules
-	// - Add custom attributes if needed
+	// Validate the forwarded FAPI headers, the TPP's signature and business rules
+	req.Data.RequestHeaders = fapi.Normalize(req.Data.RequestHeaders)
+	if err := h.validateConsentRequest(r.Context(), body, req.Data.ConsentInitiationData, req.Data.RequestHeaders); err != nil {
+		h.handleError(w, r, req.Data.RequestHeaders, err, req.RequestID)
+		return
+	}
+
+	// Replay the original response for a repeated x-idempotency-key
+	idempotent, replay, err := h.checkIdempotency(r.Context(), &req)
+	if err != nil {
+		h.handleError(w, r, req.Data.RequestHeaders, err, req.RequestID)
+		return
+	}
+	if replay != nil {
+		h.sendJSONResponse(w, http.StatusOK, replay)
+		return
+	}
+
+	// TODO: Add custom attributes if needed
 
 	// Extract resolved consent purposes from requestPayload.Data.Permissions
-	resolvedPurposes := h.extractConsentPurposes(req.Data.ConsentInitiationData.RequestPayload)
+	resolvedPurposes := h.resolvePurposes(r.Context(), req.Data.ConsentInitiationData)
 
 	// For now, returning a success response with the received data
 	response := models.SuccessResponsePreProcessConsentCreation{
@@ -49,6 +164,8 @@ func (h *ConsentHandler) PreProcessConsentCreation(w http.ResponseWriter, r *htt
 			ResolvedConsentPurposes: resolvedPurposes,
 		},
 	}
+	h.rememberIdempotent(r.Context(), idempotent, response)
+	ratelimit.MarkCreated(r.Context())
 
 	// Send response
 	h.sendJSONResponse(w, http.StatusOK, response)
@@ -59,22 +176,29 @@ func (h *ConsentHandler) PreProcessConsentUpdate(w http.ResponseWriter, r *http.
 	var req models.PreProcessConsentUpdateRequest
 
 	// Decode request body
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		log.Printf("Error decoding request: %v", err)
-		h.sendErrorResponse(w, http.StatusBadRequest, "invalid_request", "Invalid request body", req.RequestID)
+	body, err := h.decodeRequest(r, &req)
+	if err != nil {
+		logging.FromContext(r.Context()).Warn("Error decoding request", "error", err)
+		h.observer.DecodeFailure(r.Context(), r.URL.Path)
+		h.sendErrorResponse(w, r, req.Data.RequestHeaders, http.StatusBadRequest, "invalid_request", "Invalid request body", err.Error(), req.RequestID)
 		return
 	}
 
 	// Log the request
-	log.Printf("Received pre-process-consent-update request with ID: %s", req.RequestID)
+	logging.FromContext(r.Context()).Info("Received pre-process-consent-update request")
+	traceRequest(r.Context(), req.RequestID, req.Data.RequestHeaders)
 
-	// TODO: Implement your business logic here
-	// - Validate consent update data
-	// - Apply business rules for updates
-	// - Add custom attributes if needed
+	// Validate the forwarded FAPI headers, the TPP's signature and business rules
+	req.Data.RequestHeaders = fapi.Normalize(req.Data.RequestHeaders)
+	if err := h.validateConsentRequest(r.Context(), body, req.Data.ConsentInitiationData, req.Data.RequestHeaders); err != nil {
+		h.handleError(w, r, req.Data.RequestHeaders, err, req.RequestID)
+		return
+	}
+
+	// TODO: Add custom attributes if needed
 
 	// Extract resolved consent purposes from requestPayload.Data.Permissions
-	resolvedPurposes := h.extractConsentPurposes(req.Data.ConsentInitiationData.RequestPayload)
+	resolvedPurposes := h.resolvePurposes(r.Context(), req.Data.ConsentInitiationData)
 
 	// Return a success response with the received data
 	response := models.SuccessResponsePreProcessConsentCreation{
@@ -90,43 +214,235 @@ func (h *ConsentHandler) PreProcessConsentUpdate(w http.ResponseWriter, r *http.
 	h.sendJSONResponse(w, http.StatusOK, response)
 }
 
-// extractConsentPurposes extracts the permissions from requestPayload.Data.Permissions
+// PreProcessConsentRetrieval handles pre validations for consent retrievals
+func (h *ConsentHandler) PreProcessConsentRetrieval(w http.ResponseWriter, r *http.Request) {
+	var req models.PreProcessConsentRetrievalRequest
+
+	// Decode request body
+	if _, err := h.decodeRequest(r, &req); err != nil {
+		logging.FromContext(r.Context()).Warn("Error decoding request", "error", err)
+		h.observer.DecodeFailure(r.Context(), r.URL.Path)
+		h.sendErrorResponse(w, r, req.Data.RequestHeaders, http.StatusBadRequest, "invalid_request", "Invalid request body", err.Error(), req.RequestID)
+		return
+	}
+
+	// Log the request
+	logging.FromContext(r.Context()).Info("Received pre-process-consent-retrieval request")
+	traceRequest(r.Context(), req.RequestID, req.Data.RequestHeaders)
+
+	// Validate the forwarded FAPI headers and enforce the consent's daily access frequency
+	req.Data.RequestHeaders = fapi.Normalize(req.Data.RequestHeaders)
+	if err := h.validateRetrievalRequest(r.Context(), req.Data.ConsentResource, req.Data.RequestHeaders); err != nil {
+		h.handleError(w, r, req.Data.RequestHeaders, err, req.RequestID)
+		return
+	}
+
+	h.sendJSONResponse(w, http.StatusOK, h.enrichResponse(r.Context(), req.RequestID, req.Data.RequestHeaders))
+}
+
+// EnrichConsentCreationResponse handles post consent creation response generation
+func (h *ConsentHandler) EnrichConsentCreationResponse(w http.ResponseWriter, r *http.Request) {
+	var req models.EnrichConsentCreationRequest
+
+	// Decode request body
+	if _, err := h.decodeRequest(r, &req); err != nil {
+		logging.FromContext(r.Context()).Warn("Error decoding request", "error", err)
+		h.observer.DecodeFailure(r.Context(), r.URL.Path)
+		h.sendErrorResponse(w, r, req.Data.RequestHeaders, http.StatusBadRequest, "invalid_request", "Invalid request body", err.Error(), req.RequestID)
+		return
+	}
+
+	// Log the request
+	logging.FromContext(r.Context()).Info("Received enrich-consent-creation-response request")
+	traceRequest(r.Context(), req.RequestID, req.Data.RequestHeaders)
+
+	h.sendJSONResponse(w, http.StatusOK, h.enrichResponse(r.Context(), req.RequestID, req.Data.RequestHeaders))
+}
+
+// EnrichConsentUpdateResponse handles post consent update response generation
+func (h *ConsentHandler) EnrichConsentUpdateResponse(w http.ResponseWriter, r *http.Request) {
+	var req models.EnrichConsentUpdateRequest
+
+	// Decode request body
+	if _, err := h.decodeRequest(r, &req); err != nil {
+		logging.FromContext(r.Context()).Warn("Error decoding request", "error", err)
+		h.observer.DecodeFailure(r.Context(), r.URL.Path)
+		h.sendErrorResponse(w, r, req.Data.RequestHeaders, http.StatusBadRequest, "invalid_request", "Invalid request body", err.Error(), req.RequestID)
+		return
+	}
+
+	// Log the request
+	logging.FromContext(r.Context()).Info("Received enrich-consent-update-response request")
+	traceRequest(r.Context(), req.RequestID, req.Data.RequestHeaders)
+
+	h.sendJSONResponse(w, http.StatusOK, h.enrichResponse(r.Context(), req.RequestID, req.Data.RequestHeaders))
+}
+
+// enrichResponse builds the response alteration for an enrich request. The TPP's
+// x-fapi-interaction-id is echoed back, or a new one is generated if it sent none.
+func (h *ConsentHandler) enrichResponse(ctx context.Context, requestID string, requestHeaders map[string]interface{}) models.SuccessResponseForResponseAlternation {
+	_, span := tracing.Start(ctx, "enrichment")
+	defer span.End()
+
+	interactionID := fapi.Get(requestHeaders, fapi.HeaderInteractionID)
+	if interactionID == "" {
+		interactionID = fapi.NewInteractionID()
+	}
+
+	// TODO: Return a modified response body if needed
+	return models.SuccessResponseForResponseAlternation{
+		ResponseID: requestID,
+		Status:     "SUCCESS",
+		Data: models.SuccessResponseForResponseAlternationData{
+			ResponseHeaders: map[string]string{
+				fapi.HeaderInteractionID: interactionID,
+			},
+		},
+	}
+}
+
+// PreProcessConsentFileUpload handles pre validations for consent file uploads
+func (h *ConsentHandler) PreProcessConsentFileUpload(w http.ResponseWriter, r *http.Request) {
+	var req models.PreProcessFileUploadRequest
+
+	// Decode request body
+	if _, err := h.decodeRequest(r, &req); err != nil {
+		logging.FromContext(r.Context()).Warn("Error decoding request", "error", err)
+		h.observer.DecodeFailure(r.Context(), r.URL.Path)
+		h.sendErrorResponse(w, r, req.Data.RequestHeaders, http.StatusBadRequest, "invalid_request", "Invalid request body", err.Error(), req.RequestID)
+		return
+	}
+
+	// Log the request
+	logging.FromContext(r.Context()).Info("Received pre-process-consent-file-upload request")
+	traceRequest(r.Context(), req.RequestID, req.Data.RequestHeaders)
+
+	// Validate the forwarded FAPI headers and the TPP's signature over the file
+	req.Data.RequestHeaders = fapi.Normalize(req.Data.RequestHeaders)
+	if err := h.validateFileUploadRequest(r.Context(), req.Data); err != nil {
+		h.handleError(w, r, req.Data.RequestHeaders, err, req.RequestID)
+		return
+	}
+
+	// TODO: Validate the file content
+
+	// The consent awaits authorisation once its file has been uploaded
+	response := models.SuccessResponsePreProcessFileUpload{
+		ResponseID: req.RequestID,
+		Status:     "SUCCESS",
+		Data: models.SuccessResponsePreProcessFileUploadData{
+			ConsentStatus: "AwaitingAuthorisation",
+		},
+	}
+
+	// Send response
+	h.sendJSONResponse(w, http.StatusOK, response)
+}
+
+// resolvePurposes resolves the purposes of a consent and reports them to the observer
+func (h *ConsentHandler) resolvePurposes(ctx context.Context, consent models.DetailedConsentResourceData) []string {
+	ctx, span := tracing.Start(ctx, "purpose-resolution")
+	defer span.End()
+
+	purposes := h.extractConsentPurposes(consent.RequestPayload)
+	span.SetAttribute("purposes", len(purposes))
+	h.observer.PurposesResolved(ctx, consent.Type, len(purposes))
+	audit.PurposesResolved(ctx, purposes)
+	return purposes
+}
+
+// extractConsentPurposes extracts the permissions from requestPayload.Data.Permissions,
+// resolved through the purpose mapping of the active rule set or, without one, of the configuration
 func (h *ConsentHandler) extractConsentPurposes(requestPayload map[string]interface{}) []string {
+	mapping := h.purposeMapping
+	if rules := h.rules.Current().PurposeMapping(); len(rules) > 0 {
+		mapping = rules
+	}
+
 	var purposes []string
+	seen := make(map[string]bool)
+	for _, permission := range consentPermissions(requestPayload) {
+		mapped, ok := mapping[permission]
+		if !ok {
+			mapped = []string{permission}
+		}
+		for _, purpose := range mapped {
+			if !seen[purpose] {
+				seen[purpose] = true
+				purposes = append(purposes, purpose)
+			}
+		}
+	}
+
+	return purposes
+}
+
+// consentPermissions returns the string permissions in requestPayload.Data.Permissions
+func consentPermissions(requestPayload map[string]interface{}) []string {
+	var permissions []string
 
 	// Check if requestPayload has a "Data" field
 	if data, ok := requestPayload["Data"].(map[string]interface{}); ok {
 		// Check if Data has a "Permissions" field
-		if permissions, ok := data["Permissions"].([]interface{}); ok {
+		if perms, ok := data["Permissions"].([]interface{}); ok {
 			// Convert each permission to string
-			for _, perm := range permissions {
+			for _, perm := range perms {
 				if permStr, ok := perm.(string); ok {
-					purposes = append(purposes, permStr)
+					permissions = append(permissions, permStr)
 				}
 			}
 		}
 	}
 
-	return purposes
+	return permissions
+}
+
+// decodeRequest reads the request body and decodes it into v, returning the raw body
+func (h *ConsentHandler) decodeRequest(r *http.Request, v interface{}) ([]byte, error) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return nil, err
+	}
+	return body, nil
 }
 
 // sendJSONResponse sends a JSON response
 func (h *ConsentHandler) sendJSONResponse(w http.ResponseWriter, statusCode int, data interface{}) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(statusCode)
-	if err := json.NewEncoder(w).Encode(data); err != nil {
-		log.Printf("Error encoding response: %v", err)
+	response.WriteJSON(w, statusCode, data)
+}
+
+// handleError converts an error returned by the business logic into a response.
+// Business errors become a FailedResponse, anything else is reported as a server error.
+func (h *ConsentHandler) handleError(w http.ResponseWriter, r *http.Request, requestHeaders map[string]interface{}, err error, responseID string) {
+	if be, ok := apperrors.AsBusinessError(err); ok {
+		be = h.rules.Current().MapError(be)
+		logging.FromContext(r.Context()).Info("Request rejected", "code", be.Code, "error", be)
+		h.observer.BusinessRejection(r.Context(), be.Code)
+		audit.RuleFired(r.Context(), be.Code, be.Error())
+		h.sendFailedResponse(w, be, responseID)
+		return
 	}
+
+	logging.FromContext(r.Context()).Error("Error processing request", "error", err)
+	h.sendErrorResponse(w, r, requestHeaders, http.StatusInternalServerError, "server_error", "Failed to process the request", "", responseID)
 }
 
-// sendErrorResponse sends an error response
-func (h *ConsentHandler) sendErrorResponse(w http.ResponseWriter, statusCode int, errorMessage, errorDescription, responseID string) {
-	errorResp := models.ErrorResponse{
-		ResponseID:       responseID,
-		Status:           "ERROR",
-		ErrorMessage:     errorMessage,
-		ErrorDescription: errorDescription,
+// sendFailedResponse sends a failed response for a business rule rejection
+func (h *ConsentHandler) sendFailedResponse(w http.ResponseWriter, be *apperrors.BusinessError, responseID string) {
+	failedResp := models.FailedResponse{
+		ResponseID: responseID,
+		Status:     "ERROR",
+		ErrorCode:  be.ErrorCode,
+		Data:       be.Payload(),
 	}
 
-	h.sendJSONResponse(w, statusCode, errorResp)
+	h.sendJSONResponse(w, http.StatusOK, failedResp)
+}
+
+// sendErrorResponse sends an error response in the configured format
+func (h *ConsentHandler) sendErrorResponse(w http.ResponseWriter, r *http.Request, requestHeaders map[string]interface{}, statusCode int, code, message, details, responseID string) {
+	h.errorWriter.Write(w, r, requestHeaders, statusCode, code, message, details, responseID)
 }
@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"consent-service-extensions/internal/apperrors"
+	"consent-service-extensions/internal/counter"
+	"consent-service-extensions/internal/logging"
+	"consent-service-extensions/internal/models"
+)
+
+// countConsentAccess counts an access to a consent for the current day,
+// rejecting it when the consent's daily limit has been reached. Consents
+// without a limit are not counted.
+func (h *ConsentHandler) countConsentAccess(ctx context.Context, consent models.StoredDetailedConsentResourceData) error {
+	if h.accessCounter == nil {
+		return nil
+	}
+
+	limit := accessLimit(ctx, consent)
+	if limit <= 0 || consent.ID == "" {
+		return nil
+	}
+
+	loc := h.accessLocation
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	count, err := h.accessCounter.Increment(ctx, consent.ID, counter.Day(time.Now(), loc), limit)
+	if errors.Is(err, counter.ErrLimitExceeded) {
+		return apperrors.ErrFrequencyExceeded.
+			WithParam("consentId", consent.ID).
+			WithParam("limit", strconv.FormatInt(limit, 10))
+	}
+	if err != nil {
+		return err
+	}
+
+	logging.FromContext(ctx).Debug("Counted consent access", "consentId", consent.ID, "count", count, "limit", limit)
+	return nil
+}
+
+// accessLimit returns the daily access limit of a consent: the
+// maxFrequencyPerDay attribute if set, otherwise its frequency. Zero means
+// no limit.
+func accessLimit(ctx context.Context, consent models.StoredDetailedConsentResourceData) int64 {
+	switch v := consent.Attributes["maxFrequencyPerDay"].(type) {
+	case float64:
+		return int64(v)
+	case string:
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+			return n
+		}
+		logging.FromContext(ctx).Warn("Ignoring invalid maxFrequencyPerDay", "value", v, "consentId", consent.ID)
+	}
+	return int64(consent.Frequency)
+}
@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"consent-service-extensions/internal/apperrors"
+	"consent-service-extensions/internal/fapi"
+	"consent-service-extensions/internal/idempotency"
+	"consent-service-extensions/internal/logging"
+	"consent-service-extensions/internal/models"
+)
+
+// pendingTTL bounds how long a reservation blocks its key when the request
+// that made it never stores a response
+const pendingTTL = time.Minute
+
+// idempotentRequest identifies a consent creation request by its idempotency key
+type idempotentRequest struct {
+	clientID    string
+	key         string
+	payloadHash string
+}
+
+// checkIdempotency reserves the x-idempotency-key of a consent creation request.
+// It returns the original response when the request is a replay of the same
+// payload, and a business error when the key was used with a different payload
+// or a request with the key is still being processed. The returned
+// idempotentRequest is nil when idempotency does not apply.
+func (h *ConsentHandler) checkIdempotency(ctx context.Context, req *models.PreProcessConsentCreationRequest) (*idempotentRequest, json.RawMessage, error) {
+	if h.idempotencyStore == nil {
+		return nil, nil, nil
+	}
+
+	key := fapi.Get(req.Data.RequestHeaders, fapi.HeaderIdempotencyKey)
+	if key == "" {
+		return nil, nil, nil
+	}
+
+	// Keys are scoped to a client, so without one they would be shared by every TPP
+	attributes := req.Data.ConsentInitiationData.Attributes
+	clientID := attributeString(attributes, "clientId")
+	if clientID == "" {
+		clientID = attributeString(attributes, "thirdPartyId")
+	}
+	if clientID == "" {
+		logging.FromContext(ctx).Warn("Ignoring x-idempotency-key of a consent without clientId or thirdPartyId", "key", key)
+		return nil, nil, nil
+	}
+
+	hash, err := idempotency.HashPayload(req.Data.ConsentInitiationData.RequestPayload)
+	if err != nil {
+		return nil, nil, err
+	}
+	ir := &idempotentRequest{clientID: clientID, key: key, payloadHash: hash}
+
+	now := time.Now()
+	rec, err := h.idempotencyStore.Reserve(ctx, idempotency.Record{
+		ClientID:    clientID,
+		Key:         key,
+		PayloadHash: hash,
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(min(pendingTTL, h.idempotencyTTL)),
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	if rec == nil {
+		return ir, nil, nil
+	}
+
+	if rec.PayloadHash != hash {
+		return nil, nil, apperrors.ErrIdempotencyMismatch.WithParam("key", key)
+	}
+	if rec.Pending() {
+		return nil, nil, apperrors.ErrIdempotencyConflict.WithParam("key", key)
+	}
+
+	logging.FromContext(ctx).Info("Replaying response for x-idempotency-key", "key", key, "clientId", clientID)
+	return ir, rec.Response, nil
+}
+
+// rememberIdempotent stores the response of an idempotent request for replays
+func (h *ConsentHandler) rememberIdempotent(ctx context.Context, ir *idempotentRequest, response interface{}) {
+	if ir == nil {
+		return
+	}
+
+	body, err := json.Marshal(response)
+	if err != nil {
+		logging.FromContext(ctx).Error("Error encoding idempotent response", "error", err)
+		return
+	}
+
+	now := time.Now()
+	rec := idempotency.Record{
+		ClientID:    ir.clientID,
+		Key:         ir.key,
+		PayloadHash: ir.payloadHash,
+		Response:    body,
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(h.idempotencyTTL),
+	}
+	if err := h.idempotencyStore.Put(ctx, rec); err != nil {
+		logging.FromContext(ctx).Error("Error storing idempotency record", "key", ir.key, "error", err)
+	}
+}
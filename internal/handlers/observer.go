@@ -0,0 +1,21 @@
+package handlers
+
+import "context"
+
+// Observer receives events that only the handler can see, such as business
+// rejections, for instrumentation
+type Observer interface {
+	// BusinessRejection is called when a business rule rejects the request with code
+	BusinessRejection(ctx context.Context, code string)
+	// PurposesResolved is called with the number of purposes resolved for a consent
+	PurposesResolved(ctx context.Context, consentType string, count int)
+	// DecodeFailure is called when the request body of route cannot be decoded
+	DecodeFailure(ctx context.Context, route string)
+}
+
+// nopObserver discards every event
+type nopObserver struct{}
+
+func (nopObserver) BusinessRejection(context.Context, string)     {}
+func (nopObserver) PurposesResolved(context.Context, string, int) {}
+func (nopObserver) DecodeFailure(context.Context, string)         {}
@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"encoding/json"
+
+	"consent-service-extensions/internal/models"
+)
+
+// rawConsentPayload captures the requestPayload exactly as it was forwarded, so
+// the TPP's detached signature can be verified over the original bytes
+type rawConsentPayload struct {
+	Data struct {
+		ConsentInitiationData struct {
+			RequestPayload json.RawMessage `json:"requestPayload"`
+		} `json:"consentInitiationData"`
+	} `json:"data"`
+}
+
+// verifyConsentPayloadSignature verifies the x-jws-signature of a consent initiation payload
+func (h *ConsentHandler) verifyConsentPayloadSignature(body []byte, data models.DetailedConsentResourceData, requestHeaders map[string]interface{}) error {
+	if h.signatureVerifier == nil {
+		return nil
+	}
+
+	var raw rawConsentPayload
+	if err := json.Unmarshal(body, &raw); err != nil {
+		return err
+	}
+
+	return h.signatureVerifier.Verify(requestHeaders, data.Type, attributeString(data.Attributes, "thirdPartyId"), raw.Data.ConsentInitiationData.RequestPayload)
+}
+
+// verifyFileSignature verifies the x-jws-signature of an uploaded file
+func (h *ConsentHandler) verifyFileSignature(data models.RequestForPreProcessFileUpload) error {
+	if h.signatureVerifier == nil {
+		return nil
+	}
+
+	consent := data.ConsentResource
+	return h.signatureVerifier.Verify(data.RequestHeaders, consent.Type, attributeString(consent.Attributes, "thirdPartyId"), []byte(data.FileContent))
+}
+
+// attributeString returns a string consent attribute, or "" if it is missing
+func attributeString(attributes map[string]interface{}, name string) string {
+	if s, ok := attributes[name].(string); ok {
+		return s
+	}
+	return ""
+}
@@ -0,0 +1,16 @@
+package handlers
+
+import (
+	"context"
+
+	"consent-service-extensions/internal/fapi"
+	"consent-service-extensions/internal/logging"
+	"consent-service-extensions/internal/tracing"
+)
+
+// traceRequest records the requestId and x-fapi-interaction-id on the handler's span
+func traceRequest(ctx context.Context, requestID string, requestHeaders map[string]interface{}) {
+	span := tracing.SpanFromContext(ctx)
+	span.SetAttribute(logging.KeyRequestID, requestID)
+	span.SetAttribute(fapi.HeaderInteractionID, fapi.Get(requestHeaders, fapi.HeaderInteractionID))
+}
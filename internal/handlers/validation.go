@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"context"
+	"strconv"
+
+	"consent-service-extensions/internal/apperrors"
+	"consent-service-extensions/internal/models"
+	"consent-service-extensions/internal/tracing"
+)
+
+// validateConsentRequest validates the forwarded FAPI headers, the TPP's
+// signature over the consent payload and the business rules of a consent
+// creation or update
+func (h *ConsentHandler) validateConsentRequest(ctx context.Context, body []byte, data models.DetailedConsentResourceData, requestHeaders map[string]interface{}) (err error) {
+	_, span := tracing.Start(ctx, "validation")
+	defer func() {
+		span.RecordError(err)
+		span.End()
+	}()
+
+	if err := h.headerValidator.Validate(requestHeaders, data.Type); err != nil {
+		return err
+	}
+	if err := h.verifyConsentPayloadSignature(body, data, requestHeaders); err != nil {
+		return err
+	}
+	if err := validateConsentInitiationData(data); err != nil {
+		return err
+	}
+	return h.validatePermissions(data)
+}
+
+// validateRetrievalRequest validates the forwarded FAPI headers of a consent
+// retrieval and counts the access against the consent's daily limit
+func (h *ConsentHandler) validateRetrievalRequest(ctx context.Context, consent models.StoredDetailedConsentResourceData, requestHeaders map[string]interface{}) (err error) {
+	ctx, span := tracing.Start(ctx, "validation")
+	defer func() {
+		span.RecordError(err)
+		span.End()
+	}()
+
+	if err := h.headerValidator.Validate(requestHeaders, consent.Type); err != nil {
+		return err
+	}
+	return h.countConsentAccess(ctx, consent)
+}
+
+// validateFileUploadRequest validates the forwarded FAPI headers and the TPP's
+// signature over an uploaded file
+func (h *ConsentHandler) validateFileUploadRequest(ctx context.Context, data models.RequestForPreProcessFileUpload) (err error) {
+	_, span := tracing.Start(ctx, "validation")
+	defer func() {
+		span.RecordError(err)
+		span.End()
+	}()
+
+	if err := h.headerValidator.Validate(data.RequestHeaders, data.ConsentResource.Type); err != nil {
+		return err
+	}
+	return h.verifyFileSignature(data)
+}
+
+// validatePermissions checks the requested permissions against the permission
+// list of the consent type in the active rule set
+func (h *ConsentHandler) validatePermissions(data models.DetailedConsentResourceData) error {
+	set := h.rules.Current()
+	for _, permission := range consentPermissions(data.RequestPayload) {
+		if !set.PermissionAllowed(data.Type, permission) {
+			return apperrors.ErrPermissionNotAllowed.
+				WithParam("permission", permission).
+				WithParam("consentType", data.Type)
+		}
+	}
+	return nil
+}
+
+// validateConsentInitiationData applies the business rules shared by consent creation and update
+func validateConsentInitiationData(data models.DetailedConsentResourceData) error {
+	if data.Type == "" {
+		return apperrors.ErrConsentTypeMissing
+	}
+
+	if data.Status == "" {
+		return apperrors.ErrConsentStatusMissing
+	}
+
+	if data.ValidityTime < 0 {
+		return apperrors.ErrInvalidValidityTime.WithParam("value", strconv.FormatInt(data.ValidityTime, 10))
+	}
+
+	if data.Frequency < 0 {
+		return apperrors.ErrInvalidFrequency.WithParam("value", strconv.FormatInt(int64(data.Frequency), 10))
+	}
+
+	return nil
+}
@@ -0,0 +1,22 @@
+package health
+
+import "sync/atomic"
+
+// State tracks whether the server is draining for shutdown. A draining
+// server still answers in-flight and new requests but reports not-ready, so
+// load balancers stop routing to it.
+type State struct {
+	draining atomic.Bool
+}
+
+// SetDraining marks the server as draining
+func (s *State) SetDraining() {
+	if s != nil {
+		s.draining.Store(true)
+	}
+}
+
+// Draining reports whether the server is draining. A nil State never drains.
+func (s *State) Draining() bool {
+	return s != nil && s.draining.Load()
+}
@@ -0,0 +1,63 @@
+package httpserver
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"consent-service-extensions/internal/config"
+	"consent-service-extensions/internal/health"
+)
+
+// New creates an http.Server for handler with the configured timeouts and header limit
+func New(addr string, handler http.Handler, cfg config.ServerConfig) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadTimeout:       cfg.ReadTimeout,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+		MaxHeaderBytes:    int(cfg.MaxHeaderBytes),
+		ErrorLog:          slog.NewLogLogger(slog.Default().Handler(), slog.LevelWarn),
+	}
+}
+
+// Serve runs serve, typically srv.ListenAndServe, until it fails or ctx is
+// done. It then marks state as draining, waits delay so load balancers see
+// the service as not ready, and shuts srv down, giving in-flight requests
+// until timeout to finish before their connections are closed.
+func Serve(ctx context.Context, srv *http.Server, serve func() error, state *health.State, delay, timeout time.Duration) error {
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- serve()
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+	}
+
+	state.SetDraining()
+	slog.Info("Draining connections", "delay", delay, "timeout", timeout)
+	if delay > 0 {
+		time.Sleep(delay)
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		slog.Warn("Drain deadline exceeded, closing remaining connections", "error", err)
+		srv.Close()
+		return err
+	}
+
+	if err := <-errCh; err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	slog.Info("Server stopped")
+	return nil
+}
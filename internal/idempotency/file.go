@@ -0,0 +1,128 @@
+package idempotency
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// FileStore keeps idempotency records in memory and persists them to a JSON
+// file, so they survive restarts without an external database
+type FileStore struct {
+	path string
+
+	mu      sync.Mutex
+	records map[string]Record
+}
+
+// NewFileStore opens the store file at path, creating it on the first write
+func NewFileStore(path string) (*FileStore, error) {
+	s := &FileStore{path: path, records: make(map[string]Record)}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read idempotency store %s: %w", path, err)
+	}
+
+	var records []Record
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &records); err != nil {
+			return nil, fmt.Errorf("failed to parse idempotency store %s: %w", path, err)
+		}
+	}
+
+	// Reservations of requests interrupted by a restart are dropped
+	now := time.Now()
+	for _, rec := range records {
+		if !rec.Expired(now) && !rec.Pending() {
+			s.records[recordID(rec.ClientID, rec.Key)] = rec
+		}
+	}
+
+	return s, nil
+}
+
+// Get implements Store
+func (s *FileStore) Get(_ context.Context, clientID, key string) (*Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rec, ok := s.records[recordID(clientID, key)]
+	if !ok || rec.Expired(time.Now()) {
+		return nil, ErrNotFound
+	}
+	return &rec, nil
+}
+
+// Reserve implements Store. Reservations are kept in memory only and written
+// with the next flush.
+func (s *FileStore) Reserve(_ context.Context, rec Record) (*Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if existing, ok := s.records[recordID(rec.ClientID, rec.Key)]; ok && !existing.Expired(now) {
+		return &existing, nil
+	}
+	pruneExpired(s.records, now)
+	s.records[recordID(rec.ClientID, rec.Key)] = rec
+	return nil, nil
+}
+
+// Put implements Store
+func (s *FileStore) Put(_ context.Context, rec Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pruneExpired(s.records, time.Now())
+	s.records[recordID(rec.ClientID, rec.Key)] = rec
+	return s.flush()
+}
+
+// Close implements Store
+func (s *FileStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.flush()
+}
+
+// flush atomically rewrites the store file
+func (s *FileStore) flush() error {
+	records := make([]Record, 0, len(s.records))
+	for _, rec := range s.records {
+		records = append(records, rec)
+	}
+
+	data, err := json.Marshal(records)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to write idempotency store: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write idempotency store: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write idempotency store: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write idempotency store: %w", err)
+	}
+
+	return os.Rename(tmp.Name(), s.path)
+}
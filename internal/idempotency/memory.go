@@ -0,0 +1,68 @@
+package idempotency
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps idempotency records in memory
+type MemoryStore struct {
+	mu      sync.Mutex
+	records map[string]Record
+}
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{records: make(map[string]Record)}
+}
+
+// Get implements Store
+func (s *MemoryStore) Get(_ context.Context, clientID, key string) (*Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rec, ok := s.records[recordID(clientID, key)]
+	if !ok || rec.Expired(time.Now()) {
+		return nil, ErrNotFound
+	}
+	return &rec, nil
+}
+
+// Reserve implements Store
+func (s *MemoryStore) Reserve(_ context.Context, rec Record) (*Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if existing, ok := s.records[recordID(rec.ClientID, rec.Key)]; ok && !existing.Expired(now) {
+		return &existing, nil
+	}
+	pruneExpired(s.records, now)
+	s.records[recordID(rec.ClientID, rec.Key)] = rec
+	return nil, nil
+}
+
+// Put implements Store
+func (s *MemoryStore) Put(_ context.Context, rec Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pruneExpired(s.records, time.Now())
+	s.records[recordID(rec.ClientID, rec.Key)] = rec
+	return nil
+}
+
+// Close implements Store
+func (s *MemoryStore) Close() error {
+	return nil
+}
+
+// pruneExpired removes expired records
+func pruneExpired(records map[string]Record, now time.Time) {
+	for id, rec := range records {
+		if rec.Expired(now) {
+			delete(records, id)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package idempotency
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrNotFound is returned when no live record exists for a key
+var ErrNotFound = errors.New("idempotency record not found")
+
+// Record is a processed request remembered under its idempotency key
+type Record struct {
+	ClientID    string          `json:"clientId"`
+	Key         string          `json:"key"`
+	PayloadHash string          `json:"payloadHash"`
+	Response    json.RawMessage `json:"response"`
+	CreatedAt   time.Time       `json:"createdAt"`
+	ExpiresAt   time.Time       `json:"expiresAt"`
+}
+
+// Expired reports whether the record has passed its expiry time
+func (r *Record) Expired(now time.Time) bool {
+	return !now.Before(r.ExpiresAt)
+}
+
+// Pending reports whether the record reserves its key for a request that is
+// still being processed and has no response yet
+func (r *Record) Pending() bool {
+	return r.Response == nil
+}
+
+// Store persists idempotency records
+type Store interface {
+	// Get returns the live record for a client and key, or ErrNotFound
+	Get(ctx context.Context, clientID, key string) (*Record, error)
+	// Reserve atomically stores rec unless a live record exists for its client
+	// and key, in which case that record is returned instead. Of concurrent
+	// requests with one key exactly one reservation succeeds.
+	Reserve(ctx context.Context, rec Record) (*Record, error)
+	// Put stores a record, replacing any existing record for the same client and key
+	Put(ctx context.Context, rec Record) error
+	// Close releases the store's resources
+	Close() error
+}
+
+// HashPayload returns a stable hash of a JSON payload. Maps are encoded with
+// sorted keys, so payloads that differ only in key order hash equally.
+func HashPayload(payload interface{}) (string, error) {
+	canonical, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(canonical)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// recordID is the key under which a record is stored
+func recordID(clientID, key string) string {
+	return clientID + "\x00" + key
+}
+
+// Open creates a store of the given kind: "memory" or "file" (persisted at path)
+func Open(kind, path string) (Store, error) {
+	switch kind {
+	case "memory":
+		return NewMemoryStore(), nil
+	case "file":
+		return NewFileStore(path)
+	default:
+		return nil, fmt.Errorf("unknown idempotency store %q", kind)
+	}
+}
@@ -0,0 +1,173 @@
+package jose
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	_ "crypto/sha256" // register SHA-256 for crypto.Hash
+	_ "crypto/sha512" // register SHA-384 and SHA-512 for crypto.Hash
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// ErrInvalidSignature is returned when a signature does not verify
+var ErrInvalidSignature = errors.New("invalid signature")
+
+// Header holds the registered JOSE header parameters used by this service
+type Header struct {
+	Alg  string   `json:"alg"`
+	Kid  string   `json:"kid"`
+	Typ  string   `json:"typ"`
+	Cty  string   `json:"cty"`
+	Crit []string `json:"crit"`
+	B64  *bool    `json:"b64"`
+}
+
+// JWS is a parsed JWS in compact serialization
+type JWS struct {
+	Header Header
+	// Params holds every protected header parameter, including private ones such as
+	// the OBIE "http://openbanking.org.uk/iat" claim
+	Params map[string]interface{}
+	// Payload is the decoded payload, empty for a detached JWS
+	Payload []byte
+	// Signature is the decoded signature
+	Signature []byte
+
+	protected string
+}
+
+// ParseCompact parses a JWS in compact serialization. The payload segment may be
+// empty, in which case the JWS is detached and must be verified with VerifyDetached.
+func ParseCompact(token string) (*JWS, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("malformed JWS: expected 3 segments, got %d", len(parts))
+	}
+
+	headerJSON, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, fmt.Errorf("malformed JWS header: %w", err)
+	}
+
+	j := &JWS{protected: parts[0]}
+	if err := json.Unmarshal(headerJSON, &j.Header); err != nil {
+		return nil, fmt.Errorf("malformed JWS header: %w", err)
+	}
+	if err := json.Unmarshal(headerJSON, &j.Params); err != nil {
+		return nil, fmt.Errorf("malformed JWS header: %w", err)
+	}
+
+	if parts[1] != "" {
+		if j.Header.B64 != nil && !*j.Header.B64 {
+			j.Payload = []byte(parts[1])
+		} else if j.Payload, err = base64.RawURLEncoding.DecodeString(parts[1]); err != nil {
+			return nil, fmt.Errorf("malformed JWS payload: %w", err)
+		}
+	}
+
+	if j.Signature, err = base64.RawURLEncoding.DecodeString(parts[2]); err != nil {
+		return nil, fmt.Errorf("malformed JWS signature: %w", err)
+	}
+
+	return j, nil
+}
+
+// Detached reports whether the JWS was serialized without its payload
+func (j *JWS) Detached() bool {
+	return j.Payload == nil
+}
+
+// Verify verifies the signature over the embedded payload
+func (j *JWS) Verify(key crypto.PublicKey) error {
+	return j.VerifyDetached(key, j.Payload)
+}
+
+// VerifyDetached verifies the signature over the given payload, honouring the
+// unencoded payload option (RFC 7797) when the header sets b64 to false
+func (j *JWS) VerifyDetached(key crypto.PublicKey, payload []byte) error {
+	var signingInput string
+	if j.Header.B64 != nil && !*j.Header.B64 {
+		signingInput = j.protected + "." + string(payload)
+	} else {
+		signingInput = j.protected + "." + base64.RawURLEncoding.EncodeToString(payload)
+	}
+	return VerifySignature(j.Header.Alg, key, []byte(signingInput), j.Signature)
+}
+
+// VerifySignature verifies a JWS signature for the given algorithm. Symmetric
+// algorithms and "none" are not accepted.
+func VerifySignature(alg string, key crypto.PublicKey, signingInput, signature []byte) error {
+	switch alg {
+	case "RS256", "RS384", "RS512", "PS256", "PS384", "PS512":
+		pub, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("algorithm %s requires an RSA key", alg)
+		}
+		hash := hashFor(alg)
+		digest := digest(hash, signingInput)
+		var err error
+		if strings.HasPrefix(alg, "PS") {
+			err = rsa.VerifyPSS(pub, hash, digest, signature, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+		} else {
+			err = rsa.VerifyPKCS1v15(pub, hash, digest, signature)
+		}
+		if err != nil {
+			return ErrInvalidSignature
+		}
+		return nil
+
+	case "ES256", "ES384", "ES512":
+		pub, ok := key.(*ecdsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("algorithm %s requires an EC key", alg)
+		}
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		if len(signature) != 2*size {
+			return ErrInvalidSignature
+		}
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		if !ecdsa.Verify(pub, digest(hashFor(alg), signingInput), r, s) {
+			return ErrInvalidSignature
+		}
+		return nil
+
+	case "EdDSA":
+		pub, ok := key.(ed25519.PublicKey)
+		if !ok {
+			return fmt.Errorf("algorithm %s requires an Ed25519 key", alg)
+		}
+		if !ed25519.Verify(pub, signingInput, signature) {
+			return ErrInvalidSignature
+		}
+		return nil
+
+	default:
+		return fmt.Errorf("unsupported algorithm %q", alg)
+	}
+}
+
+// hashFor returns the hash function of a JWS algorithm
+func hashFor(alg string) crypto.Hash {
+	switch alg[len(alg)-3:] {
+	case "384":
+		return crypto.SHA384
+	case "512":
+		return crypto.SHA512
+	default:
+		return crypto.SHA256
+	}
+}
+
+// digest hashes data with the given hash function
+func digest(hash crypto.Hash, data []byte) []byte {
+	h := hash.New()
+	h.Write(data)
+	return h.Sum(nil)
+}
@@ -0,0 +1,154 @@
+package logging
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"consent-service-extensions/internal/fapi"
+	"consent-service-extensions/internal/requestbody"
+)
+
+// Output formats
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// Attribute keys carried by every request scoped log line
+const (
+	KeyRequestID     = "requestId"
+	KeyInteractionID = fapi.HeaderInteractionID
+	KeyConsentType   = "consentType"
+	KeyEndpoint      = "endpoint"
+)
+
+// ParseLevel parses a LOG_LEVEL value: debug, info, warn or error
+func ParseLevel(s string) (slog.Level, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "", "info":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	default:
+		return slog.LevelInfo, fmt.Errorf("unknown log level %q", s)
+	}
+}
+
+// New creates a logger writing to w at the given level in the text or JSON format
+func New(w io.Writer, level, format string) (*slog.Logger, error) {
+	lvl, err := ParseLevel(level)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := &slog.HandlerOptions{Level: lvl}
+	switch format {
+	case "", FormatText:
+		return slog.New(slog.NewTextHandler(w, opts)), nil
+	case FormatJSON:
+		return slog.New(slog.NewJSONHandler(w, opts)), nil
+	default:
+		return nil, fmt.Errorf("unknown log format %q", format)
+	}
+}
+
+// loggerKey is the context key of the request scoped logger
+type loggerKey struct{}
+
+// WithLogger returns a context carrying logger
+func WithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// FromContext returns the request scoped logger, or the default logger
+func FromContext(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
+
+// Middleware attaches a logger carrying the requestId, x-fapi-interaction-id,
+// consent type and endpoint of each request to its context, and logs the
+// outcome of the request. Request payloads are never logged.
+func Middleware(logger *slog.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			reqLogger := logger.With(readRequest(r)...)
+			r = r.WithContext(WithLogger(r.Context(), reqLogger))
+
+			sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(sw, r)
+
+			level := slog.LevelInfo
+			if sw.status >= http.StatusInternalServerError {
+				level = slog.LevelError
+			}
+			reqLogger.Log(r.Context(), level, "Request completed",
+				"method", r.Method,
+				"status", sw.status,
+				"durationMs", float64(time.Since(start).Microseconds())/1000,
+			)
+		})
+	}
+}
+
+// readRequest extracts the log attributes of an extension request, leaving
+// the body readable for the next handler
+func readRequest(r *http.Request) []any {
+	attrs := []any{KeyEndpoint, path.Base(r.URL.Path)}
+	body, err := requestbody.Read(r)
+	if err != nil || body == nil {
+		return attrs
+	}
+
+	var envelope struct {
+		RequestID string `json:"requestId"`
+		Data      struct {
+			ConsentInitiationData struct {
+				Type string `json:"type"`
+			} `json:"consentInitiationData"`
+			ConsentResource struct {
+				Type string `json:"type"`
+			} `json:"consentResource"`
+			RequestHeaders map[string]interface{} `json:"requestHeaders"`
+		} `json:"data"`
+	}
+	// Malformed bodies are rejected and logged by the handler
+	_ = json.Unmarshal(body, &envelope)
+
+	consentType := envelope.Data.ConsentInitiationData.Type
+	if consentType == "" {
+		consentType = envelope.Data.ConsentResource.Type
+	}
+
+	return append(attrs,
+		KeyRequestID, envelope.RequestID,
+		KeyInteractionID, fapi.Get(envelope.Data.RequestHeaders, fapi.HeaderInteractionID),
+		KeyConsentType, consentType,
+	)
+}
+
+// statusWriter records the status code of a response
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+// WriteHeader records the status code
+func (w *statusWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
@@ -0,0 +1,143 @@
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// Request outcomes
+const (
+	OutcomeSuccess = "SUCCESS"
+	OutcomeFailed  = "FAILED"
+	OutcomeError   = "ERROR"
+)
+
+// namespace prefixes every metric name
+const namespace = "consent_extensions_"
+
+// OtherConsentType labels consent types outside ConsentTypes
+const OtherConsentType = "other"
+
+// ConsentTypes are the consent type label values. The type comes from the
+// request body, so any other value is counted as OtherConsentType to keep the
+// number of series bounded.
+var ConsentTypes = []string{"accounts", "payments", "fundsconfirmations", "vrp"}
+
+// Metrics instruments the extension endpoints. It is a router middleware and
+// implements handlers.Observer for the events only ConsentHandler knows about.
+type Metrics struct {
+	registry *Registry
+
+	requests         *CounterVec
+	duration         *HistogramVec
+	rejections       *CounterVec
+	purposesResolved *CounterVec
+	decodeFailures   *CounterVec
+}
+
+// New creates the extension metrics in a new registry
+func New() *Metrics {
+	r := NewRegistry()
+	return &Metrics{
+		registry: r,
+		requests: r.NewCounterVec(namespace+"requests_total",
+			"Extension requests by route, HTTP status and outcome.", "route", "status", "outcome"),
+		duration: r.NewHistogramVec(namespace+"request_duration_seconds",
+			"Extension request latency in seconds.", DefaultBuckets, "route"),
+		rejections: r.NewCounterVec(namespace+"business_rejections_total",
+			"Requests rejected by a business rule, by error code.", "code"),
+		purposesResolved: r.NewCounterVec(namespace+"purposes_resolved_total",
+			"Consent purposes resolved, by consent type.", "consent_type"),
+		decodeFailures: r.NewCounterVec(namespace+"decode_failures_total",
+			"Request bodies that could not be decoded, by route.", "route"),
+	}
+}
+
+// Registry returns the registry holding the metrics, for registering more
+func (m *Metrics) Registry() *Registry {
+	return m.registry
+}
+
+// Handler serves the metrics in the Prometheus text format
+func (m *Metrics) Handler(w http.ResponseWriter, r *http.Request) {
+	m.registry.ServeHTTP(w, r)
+}
+
+// outcomeKey is the context key of the request's outcome
+type outcomeKey struct{}
+
+// Middleware counts requests and observes their latency per route template
+func (m *Metrics) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		outcome := OutcomeSuccess
+		ctx := context.WithValue(r.Context(), outcomeKey{}, &outcome)
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+
+		next.ServeHTTP(sw, r.WithContext(ctx))
+
+		if sw.status >= http.StatusBadRequest {
+			outcome = OutcomeError
+		}
+		route := routeLabel(r)
+		m.requests.Inc(route, strconv.Itoa(sw.status), outcome)
+		m.duration.Observe(time.Since(start).Seconds(), route)
+	})
+}
+
+// BusinessRejection counts a business rule rejection and marks the request FAILED
+func (m *Metrics) BusinessRejection(ctx context.Context, code string) {
+	m.rejections.Inc(code)
+	if outcome, ok := ctx.Value(outcomeKey{}).(*string); ok {
+		*outcome = OutcomeFailed
+	}
+}
+
+// PurposesResolved counts the purposes resolved for a consent type
+func (m *Metrics) PurposesResolved(_ context.Context, consentType string, count int) {
+	m.purposesResolved.Add(float64(count), consentTypeLabel(consentType))
+}
+
+// DecodeFailure counts a request body that could not be decoded
+func (m *Metrics) DecodeFailure(_ context.Context, route string) {
+	m.decodeFailures.Inc(route)
+}
+
+// consentTypeLabel returns the label value of a consent type
+func consentTypeLabel(consentType string) string {
+	for _, known := range ConsentTypes {
+		if consentType == known {
+			return consentType
+		}
+	}
+	return OtherConsentType
+}
+
+// routeLabel returns the matched route template, keeping label cardinality bounded
+func routeLabel(r *http.Request) string {
+	if route := mux.CurrentRoute(r); route != nil {
+		if tpl, err := route.GetPathTemplate(); err == nil {
+			return tpl
+		}
+	}
+	return "unmatched"
+}
+
+// statusWriter records the response status
+type statusWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.status = status
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
@@ -12,6 +12,30 @@ type PreProcessConsentUpdateRequest struct {
 	Data      UpdateRequest `json:"data"`
 }
 
+// PreProcessFileUploadRequest represents the request body for pre-process-consent-file-upload
+type PreProcessFileUploadRequest struct {
+	RequestID string                         `json:"requestId"`
+	Data      RequestForPreProcessFileUpload `json:"data"`
+}
+
+// EnrichConsentCreationRequest represents the request body for enrich-consent-creation-response
+type EnrichConsentCreationRequest struct {
+	RequestID string                   `json:"requestId"`
+	Data      RequestForEnrichResponse `json:"data"`
+}
+
+// EnrichConsentUpdateRequest represents the request body for enrich-consent-update-response
+type EnrichConsentUpdateRequest struct {
+	RequestID string                   `json:"requestId"`
+	Data      RequestForEnrichResponse `json:"data"`
+}
+
+// PreProcessConsentRetrievalRequest represents the request body for pre-process-consent-retrieval
+type PreProcessConsentRetrievalRequest struct {
+	RequestID string                         `json:"requestId"`
+	Data      PreProcessConsentRetrievalData `json:"data"`
+}
+
 // Request represents the data section of the request
 type Request struct {
 	ConsentInitiationData DetailedConsentResourceData `json:"consentInitiationData"`
@@ -24,6 +48,25 @@ type UpdateRequest struct {
 	RequestHeaders        map[string]interface{}      `json:"requestHeaders"`
 }
 
+// RequestForEnrichResponse represents the data section of the enrich response requests
+type RequestForEnrichResponse struct {
+	ConsentResource StoredDetailedConsentResourceData `json:"consentResource"`
+	RequestHeaders  map[string]interface{}            `json:"requestHeaders"`
+}
+
+// PreProcessConsentRetrievalData represents the data section of the retrieval request
+type PreProcessConsentRetrievalData struct {
+	ConsentResource StoredDetailedConsentResourceData `json:"consentResource"`
+	RequestHeaders  map[string]interface{}            `json:"requestHeaders"`
+}
+
+// RequestForPreProcessFileUpload represents the data section of the file upload request
+type RequestForPreProcessFileUpload struct {
+	ConsentResource StoredDetailedConsentResourceData `json:"consentResource"`
+	FileContent     string                            `json:"fileContent"`
+	RequestHeaders  map[string]interface{}            `json:"requestHeaders"`
+}
+
 // DetailedConsentResourceData represents the consent resource data
 type DetailedConsentResourceData struct {
 	Type                       string                              `json:"type"`
@@ -45,6 +88,33 @@ type ConsentAuthorizationCreatePayload struct {
 	Resource map[string]interface{} `json:"resource,omitempty"`
 }
 
+// StoredDetailedConsentResourceData represents a consent resource as stored by the accelerator
+type StoredDetailedConsentResourceData struct {
+	ID                         string                               `json:"id"`
+	RequestPayload             map[string]interface{}               `json:"requestPayload"`
+	CreatedTime                int64                                `json:"createdTime"`
+	UpdatedTime                int64                                `json:"updatedTime"`
+	ClientID                   string                               `json:"clientId"`
+	Type                       string                               `json:"type"`
+	Status                     string                               `json:"status"`
+	Frequency                  int32                                `json:"frequency"`
+	ValidityTime               int64                                `json:"validityTime"`
+	RecurringIndicator         bool                                 `json:"recurringIndicator"`
+	DataAccessValidityDuration int64                                `json:"dataAccessValidityDuration,omitempty"`
+	Attributes                 map[string]interface{}               `json:"attributes,omitempty"`
+	Authorizations             []ConsentAuthorizationCreateResponse `json:"authorizations,omitempty"`
+}
+
+// ConsentAuthorizationCreateResponse represents a stored authorization object
+type ConsentAuthorizationCreateResponse struct {
+	ID          string                 `json:"id"`
+	UserID      string                 `json:"userId"`
+	Type        string                 `json:"type"`
+	Status      string                 `json:"status"`
+	UpdatedTime int64                  `json:"updatedTime"`
+	Resource    map[string]interface{} `json:"resource,omitempty"`
+}
+
 // SuccessResponsePreProcessConsentCreation represents the success response
 type SuccessResponsePreProcessConsentCreation struct {
 	ResponseID string                                 `json:"responseId"`
@@ -58,7 +128,33 @@ type SuccessResponseWithDetailedConsentData struct {
 	ResolvedConsentPurposes []string                    `json:"resolvedConsentPurposes"`
 }
 
-// FailedResponse represents a failed response
+// SuccessResponsePreProcessFileUpload represents the success response for a file upload
+type SuccessResponsePreProcessFileUpload struct {
+	ResponseID string                                  `json:"responseId"`
+	Status     string                                  `json:"status"`
+	Data       SuccessResponsePreProcessFileUploadData `json:"data"`
+}
+
+// SuccessResponsePreProcessFileUploadData represents the data section of the file upload success response
+type SuccessResponsePreProcessFileUploadData struct {
+	ConsentStatus string `json:"consentStatus"`
+	UserID        string `json:"userId,omitempty"`
+}
+
+// SuccessResponseForResponseAlternation represents the success response of the enrich response endpoints
+type SuccessResponseForResponseAlternation struct {
+	ResponseID string                                    `json:"responseId"`
+	Status     string                                    `json:"status"`
+	Data       SuccessResponseForResponseAlternationData `json:"data"`
+}
+
+// SuccessResponseForResponseAlternationData represents the headers and body to return to the TPP
+type SuccessResponseForResponseAlternationData struct {
+	ResponseHeaders  map[string]string      `json:"responseHeaders,omitempty"`
+	ModifiedResponse map[string]interface{} `json:"modifiedResponse,omitempty"`
+}
+
+// FailedResponse represents a business rule rejection returned with HTTP 200
 type FailedResponse struct {
 	ResponseID string                 `json:"responseId"`
 	Status     string                 `json:"status"`
@@ -66,8 +162,25 @@ type FailedResponse struct {
 	Data       map[string]interface{} `json:"data"`
 }
 
-// ErrorResponse represents an error response
+// ErrorResponse represents a server or transport failure
 type ErrorResponse struct {
+	ResponseID string    `json:"responseId,omitempty"`
+	Status     string    `json:"status"`
+	Data       ErrorData `json:"data"`
+}
+
+// ErrorData represents the data section of an error response
+type ErrorData struct {
+	Code          string `json:"code"`
+	Message       string `json:"message"`
+	Details       string `json:"details,omitempty"`
+	Path          string `json:"path,omitempty"`
+	InteractionID string `json:"interactionId,omitempty"`
+}
+
+// LegacyErrorResponse represents the flat error response emitted before ErrorResponse
+// was aligned with the specification
+type LegacyErrorResponse struct {
 	ResponseID       string `json:"responseId,omitempty"`
 	Status           string `json:"status"`
 	ErrorMessage     string `json:"errorMessage"`
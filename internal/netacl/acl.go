@@ -0,0 +1,155 @@
+package netacl
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"consent-service-extensions/internal/logging"
+)
+
+// Route groups an ACL can be attached to
+const (
+	// GroupAPI is the /api/services extension endpoints
+	GroupAPI = "api"
+	// GroupOps is the operational endpoints such as /health
+	GroupOps = "ops"
+)
+
+// HeaderForwardedFor is the header carrying the client address chain set by proxies
+const HeaderForwardedFor = "X-Forwarded-For"
+
+// DeniedHandler writes the response for a request from a denied address
+type DeniedHandler func(w http.ResponseWriter, r *http.Request, source net.IP)
+
+// Options configures an ACL
+type Options struct {
+	// Allow lists the CIDRs or addresses allowed to call, empty allows any address not denied
+	Allow []string
+	// Deny lists the CIDRs or addresses that are always rejected
+	Deny []string
+	// TrustedProxies lists the proxies whose X-Forwarded-For header is believed
+	TrustedProxies []string
+}
+
+// ACL allows or denies requests by their source address
+type ACL struct {
+	allow   []*net.IPNet
+	deny    []*net.IPNet
+	proxies []*net.IPNet
+}
+
+// New creates an ACL
+func New(opts Options) (*ACL, error) {
+	allow, err := ParseNetworks(opts.Allow)
+	if err != nil {
+		return nil, fmt.Errorf("allowlist: %w", err)
+	}
+	deny, err := ParseNetworks(opts.Deny)
+	if err != nil {
+		return nil, fmt.Errorf("denylist: %w", err)
+	}
+	proxies, err := ParseNetworks(opts.TrustedProxies)
+	if err != nil {
+		return nil, fmt.Errorf("trusted proxies: %w", err)
+	}
+	return &ACL{allow: allow, deny: deny, proxies: proxies}, nil
+}
+
+// ParseNetworks parses CIDRs such as "10.0.0.0/8". Bare addresses are
+// treated as single host networks.
+func ParseNetworks(entries []string) ([]*net.IPNet, error) {
+	var networks []*net.IPNet
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid address %q", entry)
+			}
+			bits := 128
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 32
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR %q", entry)
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+// Allowed reports whether an address may call. The denylist takes
+// precedence over the allowlist.
+func (a *ACL) Allowed(ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	if contains(a.deny, ip) {
+		return false
+	}
+	return len(a.allow) == 0 || contains(a.allow, ip)
+}
+
+// SourceIP returns the address a request originates from. X-Forwarded-For is
+// only followed when the connection comes from a trusted proxy; the chain is
+// then walked from the right, skipping trusted proxies, so a client cannot
+// spoof its address by sending the header itself.
+func (a *ACL) SourceIP(r *http.Request) net.IP {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	source := net.ParseIP(host)
+	if source == nil || !contains(a.proxies, source) {
+		return source
+	}
+
+	var hops []string
+	for _, header := range r.Header.Values(HeaderForwardedFor) {
+		hops = append(hops, strings.Split(header, ",")...)
+	}
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := net.ParseIP(strings.TrimSpace(hops[i]))
+		if hop == nil {
+			// A malformed hop ends the trusted part of the chain
+			return source
+		}
+		source = hop
+		if !contains(a.proxies, hop) {
+			break
+		}
+	}
+	return source
+}
+
+// Middleware rejects requests whose source address is not allowed
+func (a *ACL) Middleware(group string, onDenied DeniedHandler) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			source := a.SourceIP(r)
+			if !a.Allowed(source) {
+				logging.FromContext(r.Context()).Warn("Denied by network ACL", "group", group, "source", source.String(), "peer", r.RemoteAddr)
+				onDenied(w, r, source)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// contains reports whether any network contains ip
+func contains(networks []*net.IPNet, ip net.IP) bool {
+	for _, network := range networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,115 @@
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Limit is a token bucket refilled at Rate tokens per second up to Burst tokens
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// ParseLimit parses a limit such as "10/s", "600/m:50" or "1000/h". The
+// optional burst after the colon defaults to the count.
+func ParseLimit(s string) (Limit, error) {
+	spec, burstSpec, hasBurst := strings.Cut(strings.TrimSpace(s), ":")
+
+	countSpec, unit, ok := strings.Cut(spec, "/")
+	if !ok {
+		return Limit{}, fmt.Errorf("invalid rate limit %q, expected <count>/<s|m|h>", s)
+	}
+	count, err := strconv.Atoi(countSpec)
+	if err != nil || count <= 0 {
+		return Limit{}, fmt.Errorf("invalid rate limit %q: count must be a positive integer", s)
+	}
+
+	var per time.Duration
+	switch unit {
+	case "s":
+		per = time.Second
+	case "m":
+		per = time.Minute
+	case "h":
+		per = time.Hour
+	default:
+		return Limit{}, fmt.Errorf("invalid rate limit %q: unknown unit %q", s, unit)
+	}
+
+	burst := count
+	if hasBurst {
+		burst, err = strconv.Atoi(burstSpec)
+		if err != nil || burst <= 0 {
+			return Limit{}, fmt.Errorf("invalid rate limit %q: burst must be a positive integer", s)
+		}
+	}
+
+	return Limit{Rate: float64(count) / per.Seconds(), Burst: burst}, nil
+}
+
+// bucket is the token bucket of one key
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// Buckets holds one token bucket per key, all sharing the same limit
+type Buckets struct {
+	limit Limit
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	swept   time.Time
+}
+
+// NewBuckets creates token buckets with the given limit
+func NewBuckets(limit Limit) *Buckets {
+	return &Buckets{limit: limit, buckets: make(map[string]*bucket)}
+}
+
+// Allow takes a token from the key's bucket. When the bucket is empty it
+// returns false and the time until a token is available.
+func (b *Buckets) Allow(key string, now time.Time) (bool, time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.sweep(now)
+
+	bk, ok := b.buckets[key]
+	if !ok {
+		bk = &bucket{tokens: float64(b.limit.Burst), updated: now}
+		b.buckets[key] = bk
+	}
+
+	bk.tokens = math.Min(float64(b.limit.Burst), bk.tokens+now.Sub(bk.updated).Seconds()*b.limit.Rate)
+	bk.updated = now
+
+	if bk.tokens >= 1 {
+		bk.tokens--
+		return true, 0
+	}
+
+	wait := time.Duration((1 - bk.tokens) / b.limit.Rate * float64(time.Second))
+	return false, wait
+}
+
+// sweep drops buckets that have refilled completely, as they are
+// indistinguishable from new ones. It runs at most once per refill period.
+func (b *Buckets) sweep(now time.Time) {
+	full := time.Duration(float64(b.limit.Burst) / b.limit.Rate * float64(time.Second))
+	if now.Sub(b.swept) < full {
+		return
+	}
+	b.swept = now
+
+	for key, bk := range b.buckets {
+		if now.Sub(bk.updated) >= full {
+			delete(b.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,302 @@
+package ratelimit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"consent-service-extensions/internal/auth"
+	"consent-service-extensions/internal/counter"
+	"consent-service-extensions/internal/logging"
+	"consent-service-extensions/internal/requestbody"
+)
+
+// Dimensions a rate limit can be keyed by
+const (
+	// KeyCaller keys limits by the authenticated caller, or the client address when unauthenticated
+	KeyCaller = "caller"
+	// KeyThirdPartyID keys limits by the thirdPartyId consent attribute
+	KeyThirdPartyID = "thirdPartyId"
+	// KeyClientID keys limits by the clientId of the stored consent
+	KeyClientID = "clientId"
+)
+
+// DefaultEndpoint is the limits entry applied to endpoints without their own limit
+const DefaultEndpoint = "*"
+
+// quotaEndpoint is the endpoint counted against the daily consent creation quota
+const quotaEndpoint = "pre-process-consent-creation"
+
+// ErrRateLimited is returned when a key has exhausted its token bucket
+var ErrRateLimited = errors.New("rate limit exceeded")
+
+// ErrQuotaExceeded is returned when a TPP has reached its daily consent creation quota
+var ErrQuotaExceeded = errors.New("daily quota exceeded")
+
+// creationKey is the context key of a counted consent creation
+type creationKey struct{}
+
+// creation records whether a counted consent creation succeeded
+type creation struct {
+	created bool
+}
+
+// MarkCreated reports that the consent creation of the request in ctx
+// succeeded, so it keeps its slot of the daily quota. Rejected requests and
+// replayed responses are not marked and release their slot.
+func MarkCreated(ctx context.Context) {
+	if c, ok := ctx.Value(creationKey{}).(*creation); ok {
+		c.created = true
+	}
+}
+
+// FailureHandler writes the response for a throttled request. The
+// Retry-After header is already set. It is also called with the error of
+// requestbody.Read when the body can't be read.
+type FailureHandler func(w http.ResponseWriter, r *http.Request, err error)
+
+// Options configures a Limiter
+type Options struct {
+	// Key is the dimension limits are keyed by, KeyCaller by default
+	Key string
+	// Limits maps endpoint names, e.g. "pre-process-consent-creation", to their
+	// limit. The DefaultEndpoint entry applies to all other endpoints.
+	Limits map[string]Limit
+	// DailyCreationQuota is the number of consent creations allowed per TPP
+	// and day, zero disables the quota
+	DailyCreationQuota int64
+	// QuotaStore counts consent creations, required with DailyCreationQuota
+	QuotaStore counter.Store
+	// Location sets the quota day boundary, UTC by default
+	Location *time.Location
+}
+
+// Limiter throttles requests with per-endpoint token buckets and enforces the
+// daily consent creation quota
+type Limiter struct {
+	key        string
+	buckets    map[string]*Buckets
+	quota      int64
+	quotaStore counter.Store
+	location   *time.Location
+	now        func() time.Time
+}
+
+// New creates a Limiter
+func New(opts Options) (*Limiter, error) {
+	l := &Limiter{
+		key:        opts.Key,
+		buckets:    make(map[string]*Buckets),
+		quota:      opts.DailyCreationQuota,
+		quotaStore: opts.QuotaStore,
+		location:   opts.Location,
+		now:        time.Now,
+	}
+
+	switch l.key {
+	case "":
+		l.key = KeyCaller
+	case KeyCaller, KeyThirdPartyID, KeyClientID:
+	default:
+		return nil, fmt.Errorf("unknown rate limit key %q", opts.Key)
+	}
+
+	if l.quota > 0 && l.quotaStore == nil {
+		return nil, fmt.Errorf("daily quota requires a quota store")
+	}
+	if l.location == nil {
+		l.location = time.UTC
+	}
+
+	for endpoint, limit := range opts.Limits {
+		if limit.Rate <= 0 || limit.Burst <= 0 {
+			return nil, fmt.Errorf("invalid rate limit for %s", endpoint)
+		}
+		l.buckets[endpoint] = NewBuckets(limit)
+	}
+
+	return l, nil
+}
+
+// ParseLimits parses "endpoint=limit" entries, e.g.
+// "pre-process-consent-creation=10/s:20" or "*=100/s"
+func ParseLimits(entries []string) (map[string]Limit, error) {
+	limits := make(map[string]Limit, len(entries))
+	for _, entry := range entries {
+		endpoint, spec, ok := strings.Cut(entry, "=")
+		endpoint = strings.TrimSpace(endpoint)
+		if !ok || endpoint == "" {
+			return nil, fmt.Errorf("invalid rate limit entry %q, expected <endpoint>=<limit>", entry)
+		}
+		limit, err := ParseLimit(spec)
+		if err != nil {
+			return nil, err
+		}
+		limits[endpoint] = limit
+	}
+	return limits, nil
+}
+
+// Middleware throttles requests, calling onFailure for rejected requests
+func (l *Limiter) Middleware(onFailure FailureHandler) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			endpoint := path.Base(r.URL.Path)
+			buckets, limited := l.buckets[endpoint]
+			if !limited {
+				buckets, limited = l.buckets[DefaultEndpoint]
+			}
+			counted := l.quota > 0 && endpoint == quotaEndpoint
+			if !limited && !counted {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			attrs, err := readAttributes(r)
+			if err != nil {
+				onFailure(w, r, err)
+				return
+			}
+			now := l.now()
+
+			if limited {
+				key := l.limitKey(r, attrs)
+				if ok, wait := buckets.Allow(key, now); !ok {
+					logging.FromContext(r.Context()).Warn("Rate limited", "key", key)
+					setRetryAfter(w, wait)
+					onFailure(w, r, ErrRateLimited)
+					return
+				}
+			}
+
+			if !counted {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			tpp := attrs.thirdPartyID
+			if tpp == "" {
+				tpp = l.limitKey(r, attrs)
+			}
+			// Reserve a slot before the handler runs, so concurrent creations
+			// can't all pass the check and overshoot the quota
+			day := counter.Day(now, l.location)
+			_, err = l.quotaStore.Increment(r.Context(), tpp, day, l.quota)
+			if errors.Is(err, counter.ErrLimitExceeded) {
+				logging.FromContext(r.Context()).Warn("Daily consent creation quota reached", "thirdPartyId", tpp, "quota", l.quota)
+				setRetryAfter(w, counter.NextDay(now, l.location).Sub(now))
+				onFailure(w, r, ErrQuotaExceeded)
+				return
+			}
+			reserved := err == nil
+			if err != nil {
+				// Counting is best effort, a broken store must not block consent creation
+				logging.FromContext(r.Context()).Error("Error reserving consent creation quota", "thirdPartyId", tpp, "error", err)
+			}
+
+			// Only creations the handler marks as successful keep their slot
+			c := &creation{}
+			defer func() {
+				if !reserved || c.created {
+					return
+				}
+				if err := l.quotaStore.Decrement(r.Context(), tpp, day); err != nil {
+					logging.FromContext(r.Context()).Error("Error releasing consent creation quota", "thirdPartyId", tpp, "error", err)
+				}
+			}()
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), creationKey{}, c)))
+		})
+	}
+}
+
+// limitKey returns the value of the configured key dimension for a request,
+// falling back to the caller when the request does not carry it
+func (l *Limiter) limitKey(r *http.Request, attrs requestAttributes) string {
+	switch l.key {
+	case KeyThirdPartyID:
+		if attrs.thirdPartyID != "" {
+			return attrs.thirdPartyID
+		}
+	case KeyClientID:
+		if attrs.clientID != "" {
+			return attrs.clientID
+		}
+	}
+
+	if principal, ok := auth.PrincipalFromContext(r.Context()); ok {
+		return principal.Subject
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// requestAttributes are the TPP identifiers carried in an extension request
+type requestAttributes struct {
+	thirdPartyID string
+	clientID     string
+}
+
+// readAttributes extracts the TPP identifiers from the request body, leaving
+// the body readable for the next handler
+func readAttributes(r *http.Request) (requestAttributes, error) {
+	body, err := requestbody.Read(r)
+	if err != nil {
+		return requestAttributes{}, err
+	}
+
+	var envelope struct {
+		Data struct {
+			ConsentInitiationData struct {
+				Attributes map[string]interface{} `json:"attributes"`
+			} `json:"consentInitiationData"`
+			ConsentResource struct {
+				ClientID   string                 `json:"clientId"`
+				Attributes map[string]interface{} `json:"attributes"`
+			} `json:"consentResource"`
+		} `json:"data"`
+	}
+	// Malformed bodies are rejected by the handler
+	_ = json.Unmarshal(body, &envelope)
+
+	initiation := envelope.Data.ConsentInitiationData.Attributes
+	stored := envelope.Data.ConsentResource
+
+	attrs := requestAttributes{
+		thirdPartyID: firstString(initiation["thirdPartyId"], stored.Attributes["thirdPartyId"]),
+		clientID:     stored.ClientID,
+	}
+	if attrs.clientID == "" {
+		attrs.clientID = firstString(initiation["clientId"], stored.Attributes["clientId"])
+	}
+	return attrs, nil
+}
+
+// firstString returns the first non-empty string value
+func firstString(values ...interface{}) string {
+	for _, v := range values {
+		if s, ok := v.(string); ok && s != "" {
+			return s
+		}
+	}
+	return ""
+}
+
+// setRetryAfter sets the Retry-After header in whole seconds, rounding up
+func setRetryAfter(w http.ResponseWriter, wait time.Duration) {
+	seconds := int(math.Ceil(wait.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+}
@@ -0,0 +1,332 @@
+package redact
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Actions applied to sensitive values
+const (
+	// ActionMask replaces a value with Masked
+	ActionMask = "mask"
+	// ActionHash replaces a value with a keyed hash, so equal values stay correlatable
+	ActionHash = "hash"
+)
+
+// Masked is the replacement for masked values
+const Masked = "[REDACTED]"
+
+// hashPrefix marks hashed values
+const hashPrefix = "sha256:"
+
+// builtinPatterns are the named patterns that can be enabled by name
+var builtinPatterns = map[string]string{
+	"email": `[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`,
+	"phone": `\+[1-9][0-9 ().-]{6,18}[0-9]`,
+	"iban":  `\b[A-Z]{2}[0-9]{2}(?: ?[A-Z0-9]{4}){2,7}(?: ?[A-Z0-9]{1,4})?\b`,
+	"pan":   `\b(?:[0-9][ -]?){12,18}[0-9]\b`,
+}
+
+// DefaultPatterns are the builtin patterns enabled when a policy names none
+var DefaultPatterns = []string{"email", "phone", "iban", "pan"}
+
+// DefaultPaths are the JSON paths redacted when a policy names none: the
+// personal consent attributes and the forwarded headers carrying the same data
+var DefaultPaths = []string{
+	"**.ipAddress", "**.mobileNumber", "**.userAgent", "**.deviceId", "**.userId",
+	"**.x-fapi-customer-ip-address", "**.x-customer-user-agent", "**.authorization",
+}
+
+// Policy configures a Redactor
+type Policy struct {
+	// Paths are dotted JSON paths whose values are redacted. Keys match case
+	// insensitively, "*" matches one key or array index, "**" matches any
+	// number of them.
+	Paths []string
+	// Patterns are builtin pattern names (email, phone, iban, pan) or
+	// "name=regex" custom patterns, applied to every string value
+	Patterns []string
+	// Action is ActionMask or ActionHash
+	Action string
+	// HashKey keys the hash so redacted values cannot be brute forced. When
+	// empty a random key is generated per process, so hashes only correlate
+	// values within one run of the service.
+	HashKey string
+	// HashKeySource, when set, is read for every hash instead of HashKey, so
+	// a rotated key applies without a restart. The last key read is used
+	// while it fails.
+	HashKeySource func(ctx context.Context) (string, error)
+}
+
+// processKey is the hash key used when a policy configures none
+var processKey = sync.OnceValue(func() []byte {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		panic(fmt.Sprintf("redact: failed to generate hash key: %v", err))
+	}
+	return key
+})
+
+// pattern is a compiled pattern
+type pattern struct {
+	name string
+	re   *regexp.Regexp
+}
+
+// Redactor removes sensitive values from log attributes and JSON payloads
+type Redactor struct {
+	paths    [][]string
+	patterns []pattern
+	action   string
+
+	hashKeySource func(ctx context.Context) (string, error)
+	mu            sync.Mutex
+	hashKey       []byte
+}
+
+// New creates a Redactor from a policy
+func New(p Policy) (*Redactor, error) {
+	r := &Redactor{action: p.Action, hashKey: []byte(p.HashKey), hashKeySource: p.HashKeySource}
+	switch r.action {
+	case "":
+		r.action = ActionMask
+	case ActionMask, ActionHash:
+	default:
+		return nil, fmt.Errorf("unknown redaction action %q", p.Action)
+	}
+	if r.hashKeySource != nil {
+		key, err := r.hashKeySource(context.Background())
+		if err != nil {
+			return nil, fmt.Errorf("failed to read hash key: %w", err)
+		}
+		r.hashKey = []byte(key)
+	}
+	if r.action == ActionHash && len(r.hashKey) == 0 {
+		r.hashKey = processKey()
+	}
+
+	for _, path := range p.Paths {
+		path = strings.TrimSpace(path)
+		if path == "" {
+			continue
+		}
+		r.paths = append(r.paths, strings.Split(path, "."))
+	}
+
+	for _, spec := range p.Patterns {
+		spec = strings.TrimSpace(spec)
+		name, expr, custom := strings.Cut(spec, "=")
+		if !custom {
+			builtin, ok := builtinPatterns[spec]
+			if !ok {
+				return nil, fmt.Errorf("unknown redaction pattern %q", spec)
+			}
+			expr = builtin
+		}
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redaction pattern %s: %w", name, err)
+		}
+		r.patterns = append(r.patterns, pattern{name: name, re: re})
+	}
+
+	return r, nil
+}
+
+// String redacts the pattern matches in a string
+func (r *Redactor) String(s string) string {
+	if r == nil {
+		return s
+	}
+	for _, p := range r.patterns {
+		s = p.re.ReplaceAllStringFunc(s, func(match string) string {
+			if p.name == "pan" && !luhnValid(match) {
+				return match
+			}
+			return r.replace(match)
+		})
+	}
+	return s
+}
+
+// Value returns a redacted copy of a decoded JSON value. Values at a
+// configured path are replaced; pattern matches are redacted in all other
+// strings.
+func (r *Redactor) Value(v interface{}) interface{} {
+	if r == nil {
+		return v
+	}
+	return r.walk(nil, v)
+}
+
+// Field redacts a single named value, e.g. a log attribute, treating its name
+// as the path
+func (r *Redactor) Field(name string, v interface{}) interface{} {
+	if r == nil {
+		return v
+	}
+	return r.walk([]string{name}, v)
+}
+
+// JSON redacts a JSON document. Bodies that are not JSON are redacted as text.
+func (r *Redactor) JSON(body []byte) []byte {
+	if r == nil || len(body) == 0 {
+		return body
+	}
+	var v interface{}
+	if err := json.Unmarshal(body, &v); err != nil {
+		return []byte(r.String(string(body)))
+	}
+	redacted, err := json.Marshal(r.Value(v))
+	if err != nil {
+		return []byte(Masked)
+	}
+	return redacted
+}
+
+// walk redacts v found at path
+func (r *Redactor) walk(path []string, v interface{}) interface{} {
+	if len(path) > 0 && r.matchesPath(path) {
+		return r.replaceValue(v)
+	}
+
+	switch val := v.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(val))
+		for k, child := range val {
+			out[k] = r.walk(appendPath(path, k), child)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(val))
+		for i, child := range val {
+			out[i] = r.walk(appendPath(path, strconv.Itoa(i)), child)
+		}
+		return out
+	case string:
+		return r.String(val)
+	default:
+		return v
+	}
+}
+
+// matchesPath reports whether a value path matches a configured path
+func (r *Redactor) matchesPath(path []string) bool {
+	for _, p := range r.paths {
+		if matchSegments(p, path) {
+			return true
+		}
+	}
+	return false
+}
+
+// replaceValue replaces a whole value according to the action
+func (r *Redactor) replaceValue(v interface{}) interface{} {
+	if s, ok := v.(string); ok {
+		return r.replace(s)
+	}
+	encoded, err := json.Marshal(v)
+	if err != nil {
+		return Masked
+	}
+	return r.replace(string(encoded))
+}
+
+// replace masks or hashes a sensitive string
+func (r *Redactor) replace(s string) string {
+	if r.action != ActionHash {
+		return Masked
+	}
+	mac := hmac.New(sha256.New, r.key())
+	mac.Write([]byte(s))
+	return hashPrefix + hex.EncodeToString(mac.Sum(nil))[:16]
+}
+
+// key returns the hash key, reread from the source when there is one. Errors
+// keep the last key without logging, as the log handler redacts with it.
+func (r *Redactor) key() []byte {
+	if r.hashKeySource == nil {
+		return r.hashKey
+	}
+	key, err := r.hashKeySource(context.Background())
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err == nil && key != "" {
+		r.hashKey = []byte(key)
+	}
+	return r.hashKey
+}
+
+// IsRedacted reports whether s is a whole masked or hashed value
+func IsRedacted(s string) bool {
+	if s == Masked {
+		return true
+	}
+	digest, ok := strings.CutPrefix(s, hashPrefix)
+	if !ok || len(digest) != 16 {
+		return false
+	}
+	_, err := hex.DecodeString(digest)
+	return err == nil
+}
+
+// appendPath returns path with key appended, without sharing the backing array
+func appendPath(path []string, key string) []string {
+	out := make([]string, len(path), len(path)+1)
+	copy(out, path)
+	return append(out, key)
+}
+
+// matchSegments matches a path against a pattern in which "*" matches one
+// segment and "**" any number of segments
+func matchSegments(pattern, path []string) bool {
+	if len(pattern) == 0 {
+		return len(path) == 0
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(path); i++ {
+			if matchSegments(pattern[1:], path[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(path) == 0 {
+		return false
+	}
+	if pattern[0] != "*" && !strings.EqualFold(pattern[0], path[0]) {
+		return false
+	}
+	return matchSegments(pattern[1:], path[1:])
+}
+
+// luhnValid reports whether the digits of s pass the Luhn checksum used by card numbers
+func luhnValid(s string) bool {
+	sum, n := 0, 0
+	for i := len(s) - 1; i >= 0; i-- {
+		c := s[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		d := int(c - '0')
+		if n%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		n++
+	}
+	return n >= 13 && sum%10 == 0
+}
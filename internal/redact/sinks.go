@@ -0,0 +1,96 @@
+package redact
+
+import (
+	"context"
+	"log/slog"
+)
+
+// Sinks that redaction policies are configured for
+const (
+	SinkLog     = "log"
+	SinkAudit   = "audit"
+	SinkCapture = "capture"
+)
+
+// Sinks holds the redactor of each sink
+type Sinks map[string]*Redactor
+
+// For returns the redactor of a sink. A sink without a policy gets a nil
+// Redactor, which leaves values unchanged.
+func (s Sinks) For(sink string) *Redactor {
+	return s[sink]
+}
+
+// Handler is a slog.Handler that redacts attribute values before passing
+// records to the next handler
+type Handler struct {
+	next     slog.Handler
+	redactor *Redactor
+	groups   []string
+}
+
+// NewHandler wraps next so every attribute is redacted with r
+func NewHandler(next slog.Handler, r *Redactor) *Handler {
+	return &Handler{next: next, redactor: r}
+}
+
+// Enabled implements slog.Handler
+func (h *Handler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+// Handle implements slog.Handler
+func (h *Handler) Handle(ctx context.Context, record slog.Record) error {
+	redacted := slog.NewRecord(record.Time, record.Level, h.redactor.String(record.Message), record.PC)
+	record.Attrs(func(a slog.Attr) bool {
+		redacted.AddAttrs(h.attr(h.groups, a))
+		return true
+	})
+	return h.next.Handle(ctx, redacted)
+}
+
+// WithAttrs implements slog.Handler
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	redacted := make([]slog.Attr, len(attrs))
+	for i, a := range attrs {
+		redacted[i] = h.attr(h.groups, a)
+	}
+	return &Handler{next: h.next.WithAttrs(redacted), redactor: h.redactor, groups: h.groups}
+}
+
+// WithGroup implements slog.Handler
+func (h *Handler) WithGroup(name string) slog.Handler {
+	return &Handler{next: h.next.WithGroup(name), redactor: h.redactor, groups: appendPath(h.groups, name)}
+}
+
+// attr redacts an attribute found under the given groups
+func (h *Handler) attr(groups []string, a slog.Attr) slog.Attr {
+	if h.redactor == nil {
+		return a
+	}
+
+	a.Value = a.Value.Resolve()
+	path := appendPath(groups, a.Key)
+
+	switch a.Value.Kind() {
+	case slog.KindGroup:
+		children := a.Value.Group()
+		redacted := make([]any, len(children))
+		for i, child := range children {
+			redacted[i] = h.attr(path, child)
+		}
+		return slog.Group(a.Key, redacted...)
+	case slog.KindString:
+		return slog.Any(a.Key, h.redactor.walk(path, a.Value.String()))
+	case slog.KindAny:
+		if err, ok := a.Value.Any().(error); ok {
+			return slog.Any(a.Key, h.redactor.walk(path, err.Error()))
+		}
+		return slog.Any(a.Key, h.redactor.walk(path, a.Value.Any()))
+	default:
+		if h.redactor.matchesPath(path) {
+			return slog.Any(a.Key, h.redactor.replaceValue(a.Value.Any()))
+		}
+		return a
+	}
+}
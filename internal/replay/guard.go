@@ -0,0 +1,127 @@
+package replay
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"consent-service-extensions/internal/logging"
+	"consent-service-extensions/internal/requestbody"
+)
+
+// ErrReplayedRequest is returned when a requestId was already seen within the window
+var ErrReplayedRequest = errors.New("replayed requestId")
+
+// FailureHandler writes the response for a request whose requestId was
+// replayed, or whose body requestbody.Read rejected
+type FailureHandler func(w http.ResponseWriter, r *http.Request, requestID string, err error)
+
+// seenRequest is a requestId remembered by the guard
+type seenRequest struct {
+	id     string
+	seenAt time.Time
+}
+
+// Guard remembers requestIds for a sliding window and rejects duplicates.
+// RequestIds are tracked per endpoint, so the pre-process and enrich calls of
+// one accelerator flow may share a requestId.
+type Guard struct {
+	window time.Duration
+	now    func() time.Time
+
+	mu    sync.Mutex
+	seen  map[string]time.Time
+	order []seenRequest
+
+	rejected atomic.Uint64
+}
+
+// NewGuard creates a replay guard remembering requestIds for window
+func NewGuard(window time.Duration) *Guard {
+	return &Guard{
+		window: window,
+		now:    time.Now,
+		seen:   make(map[string]time.Time),
+	}
+}
+
+// Check records a requestId for an endpoint, returning ErrReplayedRequest if it
+// was already seen within the window
+func (g *Guard) Check(endpoint, requestID string) error {
+	id := endpoint + " " + requestID
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	now := g.now()
+	g.evict(now)
+
+	if _, ok := g.seen[id]; ok {
+		g.rejected.Add(1)
+		return ErrReplayedRequest
+	}
+
+	g.seen[id] = now
+	g.order = append(g.order, seenRequest{id: id, seenAt: now})
+	return nil
+}
+
+// evict forgets requestIds that fell out of the window. The order slice is
+// kept in arrival order so eviction stops at the first live entry.
+func (g *Guard) evict(now time.Time) {
+	cutoff := now.Add(-g.window)
+	n := 0
+	for n < len(g.order) && !g.order[n].seenAt.After(cutoff) {
+		delete(g.seen, g.order[n].id)
+		n++
+	}
+	if n > 0 {
+		g.order = append(g.order[:0], g.order[n:]...)
+	}
+}
+
+// Rejected returns the number of replays rejected so far
+func (g *Guard) Rejected() uint64 {
+	return g.rejected.Load()
+}
+
+// Tracked returns the number of requestIds currently remembered
+func (g *Guard) Tracked() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.evict(g.now())
+	return len(g.seen)
+}
+
+// Middleware rejects requests whose JSON body repeats a requestId. Requests
+// without a requestId are passed through for the handler to validate.
+func (g *Guard) Middleware(onFailure FailureHandler) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, err := requestbody.Read(r)
+			if err != nil {
+				onFailure(w, r, "", err)
+				return
+			}
+
+			var envelope struct {
+				RequestID string `json:"requestId"`
+			}
+			if json.Unmarshal(body, &envelope) != nil || envelope.RequestID == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if err := g.Check(r.URL.Path, envelope.RequestID); err != nil {
+				logging.FromContext(r.Context()).Warn("Rejected replayed requestId", "rejectedReplays", g.Rejected())
+				onFailure(w, r, envelope.RequestID, err)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package requestbody
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// MaxSize is the largest request body the service accepts
+const MaxSize = 10 << 20
+
+// ErrTooLarge is returned for a request body larger than MaxSize
+var ErrTooLarge = fmt.Errorf("request body exceeds %d bytes", MaxSize)
+
+// ErrUnreadable is returned when the request body fails to read
+var ErrUnreadable = errors.New("failed to read request body")
+
+// FailureHandler writes the response for a request whose body can't be read
+// or is larger than MaxSize
+type FailureHandler func(w http.ResponseWriter, r *http.Request, err error)
+
+// cachedBody is the request body read by Middleware
+type cachedBody struct {
+	data []byte
+	err  error
+}
+
+type bodyKey struct{}
+
+// Middleware reads the request body once and caches it on the request context
+// for Read. Bodies that can't be read or exceed MaxSize are rejected with
+// onFailure instead of being passed on truncated.
+func Middleware(onFailure FailureHandler) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			data, err := read(r)
+			r = r.WithContext(context.WithValue(r.Context(), bodyKey{}, &cachedBody{data: data, err: err}))
+			if err != nil {
+				onFailure(w, r, err)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Read returns the request body, leaving r.Body readable from the start for
+// the next handler. The body cached by Middleware is returned when present,
+// otherwise it is read from r.Body. A body larger than MaxSize returns
+// ErrTooLarge and r.Body keeps all of it.
+func Read(r *http.Request) ([]byte, error) {
+	if cached, ok := r.Context().Value(bodyKey{}).(*cachedBody); ok {
+		if cached.err == nil {
+			r.Body = io.NopCloser(bytes.NewReader(cached.data))
+		}
+		return cached.data, cached.err
+	}
+	return read(r)
+}
+
+// read reads up to MaxSize bytes of r.Body and replaces it with a reader
+// over everything that was sent
+func read(r *http.Request) ([]byte, error) {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil, nil
+	}
+
+	data, err := io.ReadAll(io.LimitReader(r.Body, MaxSize+1))
+	if err != nil {
+		r.Body.Close()
+		return nil, fmt.Errorf("%w: %v", ErrUnreadable, err)
+	}
+	if len(data) > MaxSize {
+		// Hand the rest of the body on untouched rather than truncated
+		r.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(data), r.Body), r.Body}
+		return nil, ErrTooLarge
+	}
+	r.Body.Close()
+	r.Body = io.NopCloser(bytes.NewReader(data))
+	return data, nil
+}
+
+// IsReadError reports whether err is ErrTooLarge or ErrUnreadable, so a
+// middleware's failure handler can tell them from its own rejections
+func IsReadError(err error) bool {
+	return errors.Is(err, ErrTooLarge) || errors.Is(err, ErrUnreadable)
+}
@@ -0,0 +1,77 @@
+package response
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"consent-service-extensions/internal/config"
+	"consent-service-extensions/internal/fapi"
+	"consent-service-extensions/internal/models"
+	"consent-service-extensions/internal/requestbody"
+)
+
+// WriteJSON sends a JSON response
+func WriteJSON(w http.ResponseWriter, statusCode int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		slog.Error("Error encoding response", "error", err)
+	}
+}
+
+// ErrorWriter sends error responses in the configured format
+type ErrorWriter struct {
+	// Format is config.ErrorFormatSpec or config.ErrorFormatLegacy
+	Format string
+}
+
+// Write sends an error response for a server or transport failure. The
+// interaction ID is taken from requestHeaders, the headers the accelerator
+// forwarded in the request body.
+func (ew ErrorWriter) Write(w http.ResponseWriter, r *http.Request, requestHeaders map[string]interface{}, statusCode int, code, message, details, responseID string) {
+	if ew.Format == config.ErrorFormatLegacy {
+		legacyResp := models.LegacyErrorResponse{
+			ResponseID:       responseID,
+			Status:           "ERROR",
+			ErrorMessage:     code,
+			ErrorDescription: message,
+		}
+
+		WriteJSON(w, statusCode, legacyResp)
+		return
+	}
+
+	errorResp := models.ErrorResponse{
+		ResponseID: responseID,
+		Status:     "ERROR",
+		Data: models.ErrorData{
+			Code:          code,
+			Message:       message,
+			Details:       details,
+			Path:          r.URL.Path,
+			InteractionID: fapi.Get(requestHeaders, fapi.HeaderInteractionID),
+		},
+	}
+
+	WriteJSON(w, statusCode, errorResp)
+}
+
+// RequestHeaders returns the headers forwarded in the body of a request that
+// has not been decoded yet, such as one rejected by a middleware. The body is
+// restored for later readers.
+func RequestHeaders(r *http.Request) map[string]interface{} {
+	body, err := requestbody.Read(r)
+	if err != nil || body == nil {
+		return nil
+	}
+
+	var envelope struct {
+		Data struct {
+			RequestHeaders map[string]interface{} `json:"requestHeaders"`
+		} `json:"data"`
+	}
+	// Malformed bodies have no headers to report
+	_ = json.Unmarshal(body, &envelope)
+	return envelope.Data.RequestHeaders
+}
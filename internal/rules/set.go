@@ -0,0 +1,175 @@
+package rules
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"consent-service-extensions/internal/apperrors"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Set is a versioned set of business rules and catalogue mappings. A Set is
+// never modified after it is parsed, so it can be shared between requests.
+type Set struct {
+	// Version is the operator assigned version of the rule file
+	Version string `json:"version" yaml:"version"`
+	// Permissions lists the permissions accepted per consent type. Consent
+	// types without a list accept any permission.
+	Permissions map[string][]string `json:"permissions" yaml:"permissions"`
+	// Purposes maps a permission to the purposes it resolves to
+	Purposes map[string][]string `json:"purposes" yaml:"purposes"`
+	// Errors overrides the error code and payload template of catalogue entries
+	Errors map[string]ErrorMapping `json:"errors" yaml:"errors"`
+
+	// Hash is the SHA-256 of the rule file, hex encoded
+	Hash string `json:"-" yaml:"-"`
+
+	allowed map[string]map[string]bool
+}
+
+// ErrorMapping replaces the error code and/or payload template of a catalogue
+// entry. The template may use the same {name} placeholders as the entry.
+type ErrorMapping struct {
+	ErrorCode int                    `json:"errorCode" yaml:"errorCode"`
+	Data      map[string]interface{} `json:"data" yaml:"data"`
+}
+
+// ValidationError lists every problem found in a rule file
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid rule set: " + strings.Join(e.Problems, "; ")
+}
+
+// Parse decodes and validates a YAML or JSON rule file, selected by the
+// extension of name
+func Parse(name string, data []byte) (*Set, error) {
+	var s Set
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".json":
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&s); err != nil {
+			return nil, fmt.Errorf("failed to parse rule file %s: %w", name, err)
+		}
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		if err := dec.Decode(&s); err != nil {
+			return nil, fmt.Errorf("failed to parse rule file %s: %w", name, err)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported rule file extension %q, use .yaml, .yml or .json", filepath.Ext(name))
+	}
+
+	if err := s.validate(); err != nil {
+		return nil, err
+	}
+
+	sum := sha256.Sum256(data)
+	s.Hash = hex.EncodeToString(sum[:])
+	s.allowed = make(map[string]map[string]bool, len(s.Permissions))
+	for consentType, permissions := range s.Permissions {
+		s.allowed[consentType] = make(map[string]bool, len(permissions))
+		for _, p := range permissions {
+			s.allowed[consentType][p] = true
+		}
+	}
+	return &s, nil
+}
+
+// validate checks every entry of the set, collecting all problems
+func (s *Set) validate() error {
+	var problems []string
+	for consentType, permissions := range s.Permissions {
+		if consentType == "" {
+			problems = append(problems, "permissions: empty consent type")
+		}
+		if len(permissions) == 0 {
+			problems = append(problems, fmt.Sprintf("permissions.%s: empty permission list", consentType))
+		}
+		for i, p := range permissions {
+			if strings.TrimSpace(p) == "" {
+				problems = append(problems, fmt.Sprintf("permissions.%s[%d]: empty permission", consentType, i))
+			}
+		}
+	}
+	for permission, purposes := range s.Purposes {
+		if permission == "" {
+			problems = append(problems, "purposes: empty permission")
+		}
+		if len(purposes) == 0 {
+			problems = append(problems, fmt.Sprintf("purposes.%s: empty purpose list", permission))
+		}
+		for i, p := range purposes {
+			if strings.TrimSpace(p) == "" {
+				problems = append(problems, fmt.Sprintf("purposes.%s[%d]: empty purpose", permission, i))
+			}
+		}
+	}
+	for code, m := range s.Errors {
+		if _, ok := apperrors.Lookup(code); !ok {
+			problems = append(problems, fmt.Sprintf("errors.%s: unknown catalogue code", code))
+		}
+		if m.ErrorCode == 0 && len(m.Data) == 0 {
+			problems = append(problems, fmt.Sprintf("errors.%s: needs an errorCode or data", code))
+		}
+		if m.ErrorCode != 0 && (m.ErrorCode < 100 || m.ErrorCode > 599) {
+			problems = append(problems, fmt.Sprintf("errors.%s: invalid errorCode %d", code, m.ErrorCode))
+		}
+	}
+
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+// PermissionAllowed reports whether a consent of consentType may request
+// permission. Every permission is allowed without a set or list.
+func (s *Set) PermissionAllowed(consentType, permission string) bool {
+	if s == nil {
+		return true
+	}
+	allowed, ok := s.allowed[consentType]
+	return !ok || allowed[permission]
+}
+
+// PurposeMapping returns the permission to purposes mapping, nil without a set
+func (s *Set) PurposeMapping() map[string][]string {
+	if s == nil {
+		return nil
+	}
+	return s.Purposes
+}
+
+// MapError applies the error mapping of be's catalogue code, returning be
+// unchanged when the set has none
+func (s *Set) MapError(be *apperrors.BusinessError) *apperrors.BusinessError {
+	if s == nil {
+		return be
+	}
+	m, ok := s.Errors[be.Code]
+	if !ok {
+		return be
+	}
+	errorCode := m.ErrorCode
+	if errorCode == 0 {
+		errorCode = be.ErrorCode
+	}
+	data := m.Data
+	if len(data) == 0 {
+		data = be.Data
+	}
+	return be.WithTemplate(errorCode, data)
+}
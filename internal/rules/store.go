@@ -0,0 +1,148 @@
+package rules
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Status describes the active rule set and the outcome of the last reload
+type Status struct {
+	Source   string    `json:"source"`
+	Version  string    `json:"version"`
+	Hash     string    `json:"hash"`
+	LoadedAt time.Time `json:"loadedAt"`
+	// LastError is the reason the most recent reload was rejected, empty once
+	// a reload succeeds
+	LastError   string     `json:"lastError,omitempty"`
+	LastErrorAt *time.Time `json:"lastErrorAt,omitempty"`
+}
+
+// Store holds the active rule set of a rule file. Reloads validate the file
+// before swapping it in atomically and keep the active set when it is invalid.
+type Store struct {
+	path string
+
+	current atomic.Pointer[Set]
+
+	mu       sync.Mutex
+	status   Status
+	modTime  time.Time
+	fileSize int64
+
+	stop chan struct{}
+	once sync.Once
+}
+
+// NewStore loads the rule file at path and, with a positive interval, polls
+// it for changes
+func NewStore(path string, interval time.Duration) (*Store, error) {
+	s := &Store{path: path, status: Status{Source: path}, stop: make(chan struct{})}
+	if err := s.Reload(); err != nil {
+		return nil, err
+	}
+	if interval > 0 {
+		go s.watch(interval)
+	}
+	return s, nil
+}
+
+// Current returns the active rule set, nil on a nil store
+func (s *Store) Current() *Set {
+	if s == nil {
+		return nil
+	}
+	return s.current.Load()
+}
+
+// Status returns the active version and hash and the last reload failure
+func (s *Store) Status() Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.status
+}
+
+// Reload reads and validates the rule file and swaps it in. The active rule
+// set is kept if the file is unreadable or invalid.
+func (s *Store) Reload() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	info, statErr := os.Stat(s.path)
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		err = fmt.Errorf("failed to read rule file: %w", err)
+		s.fail(err)
+		return err
+	}
+	// A rejected file is not retried until it changes again
+	if statErr == nil {
+		s.modTime, s.fileSize = info.ModTime(), info.Size()
+	}
+	set, err := Parse(s.path, data)
+	if err != nil {
+		s.fail(err)
+		return err
+	}
+
+	s.status.LastError, s.status.LastErrorAt = "", nil
+	if active := s.current.Load(); active != nil && active.Hash == set.Hash {
+		return nil
+	}
+
+	s.current.Store(set)
+	s.status.Version = set.Version
+	s.status.Hash = set.Hash
+	s.status.LoadedAt = time.Now().UTC()
+	return nil
+}
+
+// Close stops watching the rule file
+func (s *Store) Close() {
+	s.once.Do(func() { close(s.stop) })
+}
+
+// fail records a rejected reload, s.mu must be held
+func (s *Store) fail(err error) {
+	now := time.Now().UTC()
+	s.status.LastError = err.Error()
+	s.status.LastErrorAt = &now
+}
+
+// watch reloads the rule file when its modification time or size changes
+func (s *Store) watch(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			if !s.changed() {
+				continue
+			}
+			if err := s.Reload(); err != nil {
+				slog.Error("Rule reload failed, keeping current rules", "file", s.path, "error", err)
+				continue
+			}
+			status := s.Status()
+			slog.Info("Rules reloaded", "file", s.path, "version", status.Version, "hash", status.Hash)
+		}
+	}
+}
+
+// changed reports whether the rule file differs from the last one read
+func (s *Store) changed() bool {
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return !info.ModTime().Equal(s.modTime) || info.Size() != s.fileSize
+}
@@ -0,0 +1,56 @@
+package secrets
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// cacheEntry is a fetched secret
+type cacheEntry struct {
+	value     string
+	fetchedAt time.Time
+}
+
+// Cache serves secrets from a provider for a TTL before fetching them again,
+// so rotated secrets are picked up without hitting the backend on every use.
+// When a refresh fails the last value is served until the backend recovers.
+type Cache struct {
+	provider Provider
+	ttl      time.Duration
+
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+}
+
+// NewCache caches the secrets of provider for ttl
+func NewCache(provider Provider, ttl time.Duration) *Cache {
+	return &Cache{provider: provider, ttl: ttl, entries: make(map[string]cacheEntry)}
+}
+
+// Get returns the cached secret, fetching it when missing or older than the TTL
+func (c *Cache) Get(ctx context.Context, name string) (string, error) {
+	c.mu.Lock()
+	entry, cached := c.entries[name]
+	c.mu.Unlock()
+	if cached && time.Since(entry.fetchedAt) < c.ttl {
+		return entry.value, nil
+	}
+
+	value, err := c.provider.Get(ctx, name)
+	if err != nil {
+		// A deleted secret is not served from the cache
+		if cached && !errors.Is(err, ErrNotFound) {
+			slog.Warn("Secret refresh failed, serving cached value", "secret", name, "error", err)
+			return entry.value, nil
+		}
+		return "", err
+	}
+
+	c.mu.Lock()
+	c.entries[name] = cacheEntry{value: value, fetchedAt: time.Now()}
+	c.mu.Unlock()
+	return value, nil
+}
@@ -0,0 +1,40 @@
+package secrets
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// EnvProvider reads secrets from environment variables. The variable of a
+// secret is its name upper cased, with every character other than a letter
+// or digit replaced by "_", after Prefix: "oauth-client-secret" is read from
+// OAUTH_CLIENT_SECRET.
+type EnvProvider struct {
+	Prefix string
+}
+
+// Get returns the environment variable of the secret
+func (p *EnvProvider) Get(_ context.Context, name string) (string, error) {
+	key := p.Prefix + envName(name)
+	value, ok := os.LookupEnv(key)
+	if !ok || value == "" {
+		return "", fmt.Errorf("%w: environment variable %s is not set", ErrNotFound, key)
+	}
+	return value, nil
+}
+
+// envName maps a secret name to an environment variable name
+func envName(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		default:
+			return '_'
+		}
+	}, name)
+}
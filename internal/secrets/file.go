@@ -0,0 +1,33 @@
+package secrets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// FileProvider reads secrets from files named after them in Dir, the layout
+// of Kubernetes secrets mounted as a volume. A trailing newline is removed.
+type FileProvider struct {
+	Dir string
+}
+
+// Get returns the content of the secret's file
+func (p *FileProvider) Get(_ context.Context, name string) (string, error) {
+	if !filepath.IsLocal(name) {
+		return "", fmt.Errorf("invalid secret name %q", name)
+	}
+
+	data, err := os.ReadFile(filepath.Join(p.Dir, name))
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", fmt.Errorf("%w: no file %s in %s", ErrNotFound, name, p.Dir)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read secret %s: %w", name, err)
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
@@ -0,0 +1,85 @@
+package secrets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Backends
+const (
+	BackendEnv   = "env"
+	BackendFile  = "file"
+	BackendVault = "vault"
+)
+
+// Scheme prefixes configuration values that reference a secret, e.g.
+// "secret://oauth-client-secret"
+const Scheme = "secret://"
+
+// ErrNotFound is returned for secrets the backend does not hold
+var ErrNotFound = errors.New("secret not found")
+
+// Provider looks up secrets by name
+type Provider interface {
+	Get(ctx context.Context, name string) (string, error)
+}
+
+// Options selects and configures a backend
+type Options struct {
+	// Backend is BackendEnv, BackendFile or BackendVault
+	Backend string
+	// EnvPrefix is prepended to the environment variable of a secret
+	EnvPrefix string
+	// Dir is the directory of mounted secret files
+	Dir   string
+	Vault VaultOptions
+	// CacheTTL is how long a secret is served before it is fetched again,
+	// zero disables caching
+	CacheTTL time.Duration
+}
+
+// New creates the provider selected by opts, cached when opts.CacheTTL is positive
+func New(opts Options) (Provider, error) {
+	var p Provider
+	switch opts.Backend {
+	case "", BackendEnv:
+		p = &EnvProvider{Prefix: opts.EnvPrefix}
+	case BackendFile:
+		if opts.Dir == "" {
+			return nil, errors.New("file secrets require a directory")
+		}
+		p = &FileProvider{Dir: opts.Dir}
+	case BackendVault:
+		vault, err := NewVaultProvider(opts.Vault)
+		if err != nil {
+			return nil, err
+		}
+		p = vault
+	default:
+		return nil, fmt.Errorf("unknown secrets backend %q", opts.Backend)
+	}
+
+	if opts.CacheTTL > 0 {
+		p = NewCache(p, opts.CacheTTL)
+	}
+	return p, nil
+}
+
+// Ref returns the secret name referenced by a configuration value
+func Ref(value string) (string, bool) {
+	name, ok := strings.CutPrefix(value, Scheme)
+	return name, ok && name != ""
+}
+
+// Resolve returns value, or the secret it references read from p. Callers
+// that resolve on every use pick up a rotated secret once its cache expires.
+func Resolve(ctx context.Context, p Provider, value string) (string, error) {
+	name, ok := Ref(value)
+	if !ok {
+		return value, nil
+	}
+	return p.Get(ctx, name)
+}
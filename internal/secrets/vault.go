@@ -0,0 +1,130 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// DefaultVaultField is the field read from a Vault secret when a reference names none
+const DefaultVaultField = "value"
+
+// VaultOptions configures a VaultProvider
+type VaultOptions struct {
+	// Addr is the base URL of the Vault server, e.g. https://vault:8200
+	Addr  string
+	Token string
+	// Namespace is sent as X-Vault-Namespace when set
+	Namespace string
+	// Mount is the path of the KV version 2 secrets engine, "secret" when empty
+	Mount   string
+	Timeout time.Duration
+	// Client overrides the HTTP client, e.g. to trust a private CA
+	Client *http.Client
+}
+
+// VaultProvider reads secrets from a HashiCorp Vault compatible KV version 2
+// engine over HTTP. A secret name is "path#field": "db/consent#password"
+// reads the password field of the secret at db/consent. The field defaults
+// to DefaultVaultField.
+type VaultProvider struct {
+	base      *url.URL
+	token     string
+	namespace string
+	mount     string
+	client    *http.Client
+}
+
+// NewVaultProvider creates a VaultProvider
+func NewVaultProvider(opts VaultOptions) (*VaultProvider, error) {
+	if opts.Addr == "" {
+		return nil, errors.New("vault secrets require an address")
+	}
+	base, err := url.Parse(strings.TrimRight(opts.Addr, "/"))
+	if err != nil || base.Scheme == "" || base.Host == "" {
+		return nil, fmt.Errorf("invalid vault address %q", opts.Addr)
+	}
+	if opts.Token == "" {
+		return nil, errors.New("vault secrets require a token")
+	}
+
+	p := &VaultProvider{
+		base:      base,
+		token:     opts.Token,
+		namespace: opts.Namespace,
+		mount:     strings.Trim(opts.Mount, "/"),
+		client:    opts.Client,
+	}
+	if p.mount == "" {
+		p.mount = "secret"
+	}
+	if p.client == nil {
+		timeout := opts.Timeout
+		if timeout <= 0 {
+			timeout = 10 * time.Second
+		}
+		p.client = &http.Client{Timeout: timeout}
+	}
+	return p, nil
+}
+
+// Get reads a field of a KV version 2 secret
+func (p *VaultProvider) Get(ctx context.Context, name string) (string, error) {
+	path, field, _ := strings.Cut(name, "#")
+	path = strings.Trim(path, "/")
+	if path == "" || strings.Contains(path, "..") {
+		return "", fmt.Errorf("invalid secret name %q", name)
+	}
+	if field == "" {
+		field = DefaultVaultField
+	}
+
+	endpoint := p.base.JoinPath("v1", p.mount, "data", path)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-Vault-Token", p.token)
+	req.Header.Set("X-Vault-Request", "true")
+	if p.namespace != "" {
+		req.Header.Set("X-Vault-Namespace", p.namespace)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("vault request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return "", fmt.Errorf("%w: vault has no secret at %s", ErrNotFound, path)
+	case resp.StatusCode != http.StatusOK:
+		return "", fmt.Errorf("vault returned status %d for %s", resp.StatusCode, path)
+	}
+
+	var body struct {
+		Data struct {
+			Data map[string]interface{} `json:"data"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&body); err != nil {
+		return "", fmt.Errorf("malformed vault response: %w", err)
+	}
+
+	value, ok := body.Data.Data[field]
+	if !ok {
+		return "", fmt.Errorf("%w: vault secret %s has no field %s", ErrNotFound, path, field)
+	}
+	s, ok := value.(string)
+	if !ok {
+		return "", fmt.Errorf("vault secret %s field %s is not a string", path, field)
+	}
+	return s, nil
+}
@@ -0,0 +1,165 @@
+package signing
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"consent-service-extensions/internal/logging"
+	"consent-service-extensions/internal/requestbody"
+)
+
+// ErrStaleSignature is returned when the signature timestamp is outside the allowed window
+var ErrStaleSignature = errors.New("stale signature")
+
+// ErrReplayedSignature is returned when a signature has already been used
+var ErrReplayedSignature = errors.New("replayed signature")
+
+// FailureHandler writes the response for a request whose signature was
+// rejected, or whose body requestbody.Read rejected
+type FailureHandler func(w http.ResponseWriter, r *http.Request, err error)
+
+// Middleware verifies request signatures and signs JSON responses
+type Middleware struct {
+	keys      *KeySource
+	maxAge    time.Duration
+	onFailure FailureHandler
+
+	mu   sync.Mutex
+	seen map[string]time.Time
+}
+
+// NewMiddleware creates the signing middleware. Request timestamps older or
+// newer than maxAge are rejected, and each signature is accepted only once
+// within that window.
+func NewMiddleware(keys *KeySource, maxAge time.Duration, onFailure FailureHandler) *Middleware {
+	return &Middleware{
+		keys:      keys,
+		maxAge:    maxAge,
+		onFailure: onFailure,
+		seen:      make(map[string]time.Time),
+	}
+}
+
+// Handler wraps next with request verification and response signing
+func (m *Middleware) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keys := m.keys.Keys(r.Context())
+		if keys.CanSign() {
+			sw := &signingResponseWriter{ResponseWriter: w, status: http.StatusOK}
+			defer sw.finish(keys, logging.FromContext(r.Context()))
+			w = sw
+		}
+
+		body, err := requestbody.Read(r)
+		if err != nil {
+			m.onFailure(w, r, err)
+			return
+		}
+
+		if err := m.verify(r, keys, body); err != nil {
+			logging.FromContext(r.Context()).Warn("Rejected request signature", "error", err)
+			m.onFailure(w, r, err)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// verify checks the signature headers of a request
+func (m *Middleware) verify(r *http.Request, keys *Keys, body []byte) error {
+	timestampHeader := r.Header.Get(HeaderTimestamp)
+	signatureHeader := r.Header.Get(HeaderSignature)
+	if timestampHeader == "" || signatureHeader == "" {
+		return fmt.Errorf("%w: missing %s or %s header", ErrInvalidSignature, HeaderSignature, HeaderTimestamp)
+	}
+
+	timestamp, err := strconv.ParseInt(timestampHeader, 10, 64)
+	if err != nil {
+		return fmt.Errorf("%w: malformed timestamp", ErrInvalidSignature)
+	}
+	now := time.Now()
+	signedAt := time.Unix(timestamp, 0)
+	if signedAt.Before(now.Add(-m.maxAge)) || signedAt.After(now.Add(m.maxAge)) {
+		return ErrStaleSignature
+	}
+
+	// Strict decoding rejects non-zero padding bits, so each signature has one encoding
+	signature, err := base64.StdEncoding.Strict().DecodeString(strings.TrimSpace(signatureHeader))
+	if err != nil {
+		return fmt.Errorf("%w: malformed signature", ErrInvalidSignature)
+	}
+
+	if err := keys.Verify(RequestSigningInput(timestamp, r.Method, r.URL.Path, body), signature); err != nil {
+		return err
+	}
+
+	return m.remember(signature, signedAt.Add(m.maxAge))
+}
+
+// remember records a verified signature, rejecting it if it was seen before.
+// Signatures are keyed on their decoded bytes, not on how they were encoded.
+func (m *Middleware) remember(signature []byte, expiry time.Time) error {
+	key := hex.EncodeToString(signature)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	if exp, ok := m.seen[key]; ok && now.Before(exp) {
+		return ErrReplayedSignature
+	}
+
+	for sig, exp := range m.seen {
+		if now.After(exp) {
+			delete(m.seen, sig)
+		}
+	}
+	m.seen[key] = expiry
+	return nil
+}
+
+// signingResponseWriter buffers a response so it can be signed before it is sent
+type signingResponseWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+// WriteHeader records the status code
+func (w *signingResponseWriter) WriteHeader(status int) {
+	w.status = status
+}
+
+// Write buffers the response body
+func (w *signingResponseWriter) Write(b []byte) (int, error) {
+	return w.body.Write(b)
+}
+
+// finish signs JSON responses and sends the buffered response
+func (w *signingResponseWriter) finish(keys *Keys, logger *slog.Logger) {
+	if strings.HasPrefix(w.Header().Get("Content-Type"), "application/json") {
+		timestamp := time.Now().Unix()
+		signature, err := keys.Sign(ResponseSigningInput(timestamp, w.status, w.body.Bytes()))
+		if err != nil {
+			logger.Error("Error signing response", "error", err)
+		} else {
+			w.Header().Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+			w.Header().Set(HeaderSignature, base64.StdEncoding.EncodeToString(signature))
+		}
+	}
+
+	w.ResponseWriter.WriteHeader(w.status)
+	if _, err := w.ResponseWriter.Write(w.body.Bytes()); err != nil {
+		logger.Error("Error writing signed response", "error", err)
+	}
+}
@@ -0,0 +1,224 @@
+package signing
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Signature algorithms
+const (
+	AlgorithmHMACSHA256 = "hmac-sha256"
+	AlgorithmEd25519    = "ed25519"
+)
+
+// Signature headers set on signed requests and responses
+const (
+	HeaderSignature = "X-Signature"
+	HeaderTimestamp = "X-Signature-Timestamp"
+)
+
+// ErrInvalidSignature is returned when a signature does not verify against any key
+var ErrInvalidSignature = errors.New("invalid signature")
+
+// Keys holds the keys used to verify request signatures and sign responses
+type Keys struct {
+	algorithm string
+	hmacKeys  [][]byte
+	publicKey []ed25519.PublicKey
+	signKey   []byte
+}
+
+// NewKeys creates the signing keys from base64 encoded key material. For
+// hmac-sha256 the verify keys are shared secrets; for ed25519 they are public
+// keys and responseKey is a 32 byte private key seed. Several verify keys may be
+// given so keys can be rotated. An empty responseKey disables response signing,
+// except for hmac-sha256 where the first verify key is used.
+func NewKeys(algorithm string, verifyKeys []string, responseKey string) (*Keys, error) {
+	if len(verifyKeys) == 0 {
+		return nil, fmt.Errorf("no signature verification keys configured")
+	}
+
+	k := &Keys{algorithm: algorithm}
+	for i, encoded := range verifyKeys {
+		raw, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("signing key %d: invalid base64: %w", i+1, err)
+		}
+
+		switch algorithm {
+		case AlgorithmHMACSHA256:
+			if len(raw) < 32 {
+				return nil, fmt.Errorf("signing key %d: HMAC keys must be at least 32 bytes", i+1)
+			}
+			k.hmacKeys = append(k.hmacKeys, raw)
+		case AlgorithmEd25519:
+			if len(raw) != ed25519.PublicKeySize {
+				return nil, fmt.Errorf("signing key %d: Ed25519 public keys must be %d bytes", i+1, ed25519.PublicKeySize)
+			}
+			k.publicKey = append(k.publicKey, ed25519.PublicKey(raw))
+		default:
+			return nil, fmt.Errorf("unknown signature algorithm %q", algorithm)
+		}
+	}
+
+	if responseKey != "" {
+		raw, err := base64.StdEncoding.DecodeString(responseKey)
+		if err != nil {
+			return nil, fmt.Errorf("response signing key: invalid base64: %w", err)
+		}
+		if algorithm == AlgorithmEd25519 && len(raw) != ed25519.SeedSize {
+			return nil, fmt.Errorf("response signing key: Ed25519 seeds must be %d bytes", ed25519.SeedSize)
+		}
+		k.signKey = raw
+	} else if algorithm == AlgorithmHMACSHA256 {
+		k.signKey = k.hmacKeys[0]
+	}
+
+	return k, nil
+}
+
+// KeySource provides the current signing keys. Keys read from a source are
+// rebuilt whenever their key material changes, so keys held as secrets are
+// rotated without a restart.
+type KeySource struct {
+	algorithm string
+	load      func(ctx context.Context) (verifyKeys []string, responseKey string, err error)
+
+	mu       sync.Mutex
+	material string
+	keys     *Keys
+}
+
+// StaticKeys returns a KeySource that always provides keys
+func StaticKeys(keys *Keys) *KeySource {
+	return &KeySource{keys: keys}
+}
+
+// NewKeySource creates the keys from the key material read by load, and reads
+// it again on every use, e.g. through a secrets cache
+func NewKeySource(ctx context.Context, algorithm string, load func(ctx context.Context) ([]string, string, error)) (*KeySource, error) {
+	verifyKeys, responseKey, err := load(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read signing keys: %w", err)
+	}
+	keys, err := NewKeys(algorithm, verifyKeys, responseKey)
+	if err != nil {
+		return nil, err
+	}
+	return &KeySource{
+		algorithm: algorithm,
+		load:      load,
+		material:  keyMaterial(verifyKeys, responseKey),
+		keys:      keys,
+	}, nil
+}
+
+// Keys returns the current keys. When the key material cannot be read or is
+// invalid the previous keys are kept.
+func (s *KeySource) Keys(ctx context.Context) *Keys {
+	if s.load == nil {
+		return s.keys
+	}
+	verifyKeys, responseKey, err := s.load(ctx)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err != nil {
+		slog.Warn("Failed to read signing keys, keeping current keys", "error", err)
+		return s.keys
+	}
+	material := keyMaterial(verifyKeys, responseKey)
+	if material == s.material {
+		return s.keys
+	}
+	keys, err := NewKeys(s.algorithm, verifyKeys, responseKey)
+	if err != nil {
+		slog.Error("Invalid signing keys, keeping current keys", "error", err)
+		return s.keys
+	}
+	s.material = material
+	s.keys = keys
+	slog.Info("Signing keys rotated")
+	return keys
+}
+
+// keyMaterial identifies a set of key material
+func keyMaterial(verifyKeys []string, responseKey string) string {
+	return strings.Join(verifyKeys, ",") + ";" + responseKey
+}
+
+// CanSign reports whether responses can be signed
+func (k *Keys) CanSign() bool {
+	return len(k.signKey) > 0
+}
+
+// Verify checks a signature over data against every verify key
+func (k *Keys) Verify(data, signature []byte) error {
+	switch k.algorithm {
+	case AlgorithmHMACSHA256:
+		for _, key := range k.hmacKeys {
+			if hmac.Equal(hmacSHA256(key, data), signature) {
+				return nil
+			}
+		}
+	case AlgorithmEd25519:
+		for _, key := range k.publicKey {
+			if ed25519.Verify(key, data, signature) {
+				return nil
+			}
+		}
+	}
+	return ErrInvalidSignature
+}
+
+// Sign signs data with the response signing key
+func (k *Keys) Sign(data []byte) ([]byte, error) {
+	if !k.CanSign() {
+		return nil, fmt.Errorf("no response signing key configured")
+	}
+	if k.algorithm == AlgorithmEd25519 {
+		return ed25519.Sign(ed25519.NewKeyFromSeed(k.signKey), data), nil
+	}
+	return hmacSHA256(k.signKey, data), nil
+}
+
+// RequestSigningInput builds the data covered by a request signature
+func RequestSigningInput(timestamp int64, method, path string, body []byte) []byte {
+	var b bytes.Buffer
+	b.WriteString(strconv.FormatInt(timestamp, 10))
+	b.WriteByte('\n')
+	b.WriteString(method)
+	b.WriteByte('\n')
+	b.WriteString(path)
+	b.WriteByte('\n')
+	b.Write(body)
+	return b.Bytes()
+}
+
+// ResponseSigningInput builds the data covered by a response signature
+func ResponseSigningInput(timestamp int64, status int, body []byte) []byte {
+	var b bytes.Buffer
+	b.WriteString(strconv.FormatInt(timestamp, 10))
+	b.WriteByte('\n')
+	b.WriteString(strconv.Itoa(status))
+	b.WriteByte('\n')
+	b.Write(body)
+	return b.Bytes()
+}
+
+// hmacSHA256 computes an HMAC-SHA256 of data
+func hmacSHA256(key, data []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(data)
+	return mac.Sum(nil)
+}
@@ -0,0 +1,157 @@
+package tlsserver
+
+import (
+	"encoding/asn1"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// attributeTypes maps the RFC 4514 attribute names to their OIDs
+var attributeTypes = map[string]string{
+	"CN":           "2.5.4.3",
+	"SERIALNUMBER": "2.5.4.5",
+	"C":            "2.5.4.6",
+	"L":            "2.5.4.7",
+	"ST":           "2.5.4.8",
+	"STREET":       "2.5.4.9",
+	"O":            "2.5.4.10",
+	"OU":           "2.5.4.11",
+	"POSTALCODE":   "2.5.4.17",
+	"DC":           "0.9.2342.19200300.100.1.25",
+	"UID":          "0.9.2342.19200300.100.1.1",
+}
+
+// attribute is one type and value of a relative distinguished name
+type attribute struct {
+	oid   string
+	value string
+}
+
+// canonicalDN renders RDNs, most specific first, as a comparison key. Attribute
+// types are OIDs, values compare case-insensitively and the attributes of a
+// multi-valued RDN are sorted.
+func canonicalDN(rdns [][]attribute) string {
+	parts := make([]string, len(rdns))
+	for i, rdn := range rdns {
+		attrs := make([]string, len(rdn))
+		for j, a := range rdn {
+			attrs[j] = a.oid + "=" + strconv.Quote(strings.ToLower(strings.TrimSpace(a.value)))
+		}
+		sort.Strings(attrs)
+		parts[i] = strings.Join(attrs, "+")
+	}
+	return strings.Join(parts, ",")
+}
+
+// parseDN parses an RFC 4514 distinguished name such as
+// "CN=accelerator, O=Bank\, Ltd" into its canonical comparison key
+func parseDN(dn string) (string, error) {
+	var rdns [][]attribute
+	var rdn []attribute
+	var key string
+	var value strings.Builder
+	inValue := false
+
+	flush := func() error {
+		if !inValue {
+			return fmt.Errorf("invalid subject DN %q: missing '='", dn)
+		}
+		oid, err := attributeOID(key)
+		if err != nil {
+			return fmt.Errorf("invalid subject DN %q: %w", dn, err)
+		}
+		rdn = append(rdn, attribute{oid: oid, value: value.String()})
+		key, inValue = "", false
+		value.Reset()
+		return nil
+	}
+
+	for i := 0; i < len(dn); i++ {
+		c := dn[i]
+		switch {
+		case !inValue && c == '=':
+			key, inValue = strings.TrimSpace(key), true
+			if strings.HasPrefix(strings.TrimLeft(dn[i+1:], " "), "#") {
+				return "", fmt.Errorf("invalid subject DN %q: hex-encoded values are not supported", dn)
+			}
+		case !inValue:
+			key += string(c)
+		case c == '\\':
+			if i+1 >= len(dn) {
+				return "", fmt.Errorf("invalid subject DN %q: trailing escape", dn)
+			}
+			if i+2 < len(dn) && isHex(dn[i+1]) && isHex(dn[i+2]) {
+				b, _ := hex.DecodeString(dn[i+1 : i+3])
+				value.Write(b)
+				i += 2
+				continue
+			}
+			value.WriteByte(dn[i+1])
+			i++
+		case c == '+':
+			if err := flush(); err != nil {
+				return "", err
+			}
+		case c == ',' || c == ';':
+			if err := flush(); err != nil {
+				return "", err
+			}
+			rdns, rdn = append(rdns, rdn), nil
+		default:
+			value.WriteByte(c)
+		}
+	}
+	if err := flush(); err != nil {
+		return "", err
+	}
+	return canonicalDN(append(rdns, rdn)), nil
+}
+
+// subjectKey returns the canonical comparison key of a DER encoded subject
+func subjectKey(raw []byte) (string, error) {
+	var sequence []asn1.RawValue
+	if _, err := asn1.Unmarshal(raw, &sequence); err != nil {
+		return "", err
+	}
+
+	// The DER sequence is least specific first, DN strings are the reverse
+	rdns := make([][]attribute, 0, len(sequence))
+	for i := len(sequence) - 1; i >= 0; i-- {
+		var set []struct {
+			Type  asn1.ObjectIdentifier
+			Value asn1.RawValue
+		}
+		if _, err := asn1.UnmarshalWithParams(sequence[i].FullBytes, &set, "set"); err != nil {
+			return "", err
+		}
+		rdn := make([]attribute, len(set))
+		for j, atv := range set {
+			rdn[j] = attribute{oid: atv.Type.String(), value: string(atv.Value.Bytes)}
+		}
+		rdns = append(rdns, rdn)
+	}
+	return canonicalDN(rdns), nil
+}
+
+// attributeOID resolves an attribute name or dotted OID
+func attributeOID(name string) (string, error) {
+	if oid, ok := attributeTypes[strings.ToUpper(name)]; ok {
+		return oid, nil
+	}
+	if strings.HasPrefix(strings.ToLower(name), "oid.") {
+		name = name[len("oid."):]
+	}
+	for _, arc := range strings.Split(name, ".") {
+		if _, err := strconv.ParseUint(arc, 10, 32); err != nil || !strings.Contains(name, ".") {
+			return "", fmt.Errorf("unknown attribute type %q", name)
+		}
+	}
+	return name, nil
+}
+
+func isHex(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
@@ -0,0 +1,290 @@
+package tlsserver
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Client certificate modes
+const (
+	// ClientAuthNone does not ask for a client certificate
+	ClientAuthNone = "none"
+	// ClientAuthRequest verifies a client certificate when one is presented
+	ClientAuthRequest = "request"
+	// ClientAuthRequire rejects connections without a verified client certificate
+	ClientAuthRequire = "require"
+)
+
+// ErrCertificateNotPinned is returned when a client certificate matches no pin
+var ErrCertificateNotPinned = errors.New("client certificate is not pinned")
+
+// Options configures the TLS server
+type Options struct {
+	CertFile     string
+	KeyFile      string
+	ClientCAFile string
+	// ClientAuth is ClientAuthNone, ClientAuthRequest or ClientAuthRequire
+	ClientAuth string
+	// PinnedSubjects are the subject DNs of allowed client certificates, e.g. "CN=accelerator,O=Bank"
+	PinnedSubjects []string
+	// PinnedSPKIHashes are SHA-256 hashes of allowed client public keys, base64 or hex encoded
+	PinnedSPKIHashes []string
+	// ReloadInterval is how often the files are checked for changes, zero disables hot reload
+	ReloadInterval time.Duration
+	// ReadFile reads CertFile, KeyFile and ClientCAFile, os.ReadFile when nil.
+	// It lets the PEM data come from a secrets backend instead of the disk.
+	ReadFile func(name string) ([]byte, error)
+}
+
+// Reloader serves a TLS configuration whose certificates are reloaded when the
+// files change
+type Reloader struct {
+	opts       Options
+	clientAuth tls.ClientAuthType
+	subjects   map[string]bool
+	spkiHashes map[[sha256.Size]byte]bool
+
+	mu      sync.RWMutex
+	current *tls.Config
+	// loaded is the hash of the PEM data of the current configuration
+	loaded [sha256.Size]byte
+
+	stop chan struct{}
+	once sync.Once
+}
+
+// NewReloader loads the certificates and starts watching them for changes
+func NewReloader(opts Options) (*Reloader, error) {
+	if opts.CertFile == "" || opts.KeyFile == "" {
+		return nil, fmt.Errorf("TLS requires a certificate and a key file")
+	}
+
+	r := &Reloader{
+		opts:       opts,
+		subjects:   make(map[string]bool),
+		spkiHashes: make(map[[sha256.Size]byte]bool),
+		stop:       make(chan struct{}),
+	}
+	if r.opts.ReadFile == nil {
+		r.opts.ReadFile = os.ReadFile
+	}
+
+	switch opts.ClientAuth {
+	case "", ClientAuthNone:
+		r.clientAuth = tls.NoClientCert
+	case ClientAuthRequest:
+		r.clientAuth = tls.VerifyClientCertIfGiven
+	case ClientAuthRequire:
+		r.clientAuth = tls.RequireAndVerifyClientCert
+	default:
+		return nil, fmt.Errorf("unknown client auth mode %q", opts.ClientAuth)
+	}
+	if r.clientAuth != tls.NoClientCert && opts.ClientCAFile == "" {
+		return nil, fmt.Errorf("client auth mode %q requires a client CA bundle", opts.ClientAuth)
+	}
+
+	for _, dn := range opts.PinnedSubjects {
+		key, err := parseDN(dn)
+		if err != nil {
+			return nil, err
+		}
+		r.subjects[key] = true
+	}
+	for _, pin := range opts.PinnedSPKIHashes {
+		hash, err := decodeSPKIHash(pin)
+		if err != nil {
+			return nil, err
+		}
+		r.spkiHashes[hash] = true
+	}
+	// Pins only hold when every connection has to present a certificate
+	if (len(r.subjects) > 0 || len(r.spkiHashes) > 0) && r.clientAuth != tls.RequireAndVerifyClientCert {
+		return nil, fmt.Errorf("client certificate pins require client auth mode %q, got %q", ClientAuthRequire, opts.ClientAuth)
+	}
+
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+
+	if opts.ReloadInterval > 0 {
+		go r.watch()
+	}
+
+	return r, nil
+}
+
+// TLSConfig returns the server TLS configuration. Each handshake uses the most
+// recently loaded certificates.
+func (r *Reloader) TLSConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			r.mu.RLock()
+			defer r.mu.RUnlock()
+			return r.current, nil
+		},
+	}
+}
+
+// pemData is the PEM data of the certificate, key and CA bundle
+type pemData struct {
+	cert, key, clientCA []byte
+}
+
+// hash identifies the PEM data
+func (d pemData) hash() [sha256.Size]byte {
+	h := sha256.New()
+	for _, b := range [][]byte{d.cert, d.key, d.clientCA} {
+		h.Write(b)
+		h.Write([]byte{0})
+	}
+	var sum [sha256.Size]byte
+	copy(sum[:], h.Sum(nil))
+	return sum
+}
+
+// read reads the certificate, key and CA bundle
+func (r *Reloader) read() (pemData, error) {
+	var d pemData
+	var err error
+	if d.cert, err = r.opts.ReadFile(r.opts.CertFile); err != nil {
+		return d, fmt.Errorf("failed to read server certificate: %w", err)
+	}
+	if d.key, err = r.opts.ReadFile(r.opts.KeyFile); err != nil {
+		return d, fmt.Errorf("failed to read server key: %w", err)
+	}
+	if r.opts.ClientCAFile != "" {
+		if d.clientCA, err = r.opts.ReadFile(r.opts.ClientCAFile); err != nil {
+			return d, fmt.Errorf("failed to read client CA bundle: %w", err)
+		}
+	}
+	return d, nil
+}
+
+// Reload loads the certificate, key and CA bundle. The active configuration is
+// kept if loading fails.
+func (r *Reloader) Reload() error {
+	d, err := r.read()
+	if err != nil {
+		return err
+	}
+	return r.apply(d)
+}
+
+// apply builds the TLS configuration from the PEM data and makes it current
+func (r *Reloader) apply(d pemData) error {
+	cert, err := tls.X509KeyPair(d.cert, d.key)
+	if err != nil {
+		return fmt.Errorf("failed to load server certificate: %w", err)
+	}
+
+	cfg := &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		NextProtos:   []string{"h2", "http/1.1"},
+		Certificates: []tls.Certificate{cert},
+		ClientAuth:   r.clientAuth,
+	}
+
+	if r.opts.ClientCAFile != "" {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(d.clientCA) {
+			return fmt.Errorf("no certificates found in client CA bundle %s", r.opts.ClientCAFile)
+		}
+		cfg.ClientCAs = pool
+	}
+
+	if len(r.subjects) > 0 || len(r.spkiHashes) > 0 {
+		cfg.VerifyConnection = r.verifyPins
+	}
+
+	r.mu.Lock()
+	r.current = cfg
+	r.loaded = d.hash()
+	r.mu.Unlock()
+
+	return nil
+}
+
+// Close stops watching the certificate files
+func (r *Reloader) Close() {
+	r.once.Do(func() { close(r.stop) })
+}
+
+// verifyPins checks the verified client certificate against the configured
+// pins. A connection without a certificate matches no pin.
+func (r *Reloader) verifyPins(cs tls.ConnectionState) error {
+	if len(cs.PeerCertificates) == 0 {
+		return ErrCertificateNotPinned
+	}
+
+	leaf := cs.PeerCertificates[0]
+	if key, err := subjectKey(leaf.RawSubject); err == nil && r.subjects[key] {
+		return nil
+	}
+	if r.spkiHashes[sha256.Sum256(leaf.RawSubjectPublicKeyInfo)] {
+		return nil
+	}
+
+	slog.Warn("Rejected client certificate", "subject", leaf.Subject.String(), "error", ErrCertificateNotPinned)
+	return ErrCertificateNotPinned
+}
+
+// watch reloads the certificates when any of them change
+func (r *Reloader) watch() {
+	ticker := time.NewTicker(r.opts.ReloadInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.stop:
+			return
+		case <-ticker.C:
+			d, err := r.read()
+			if err != nil {
+				slog.Error("TLS certificate reload failed, keeping current certificates", "error", err)
+				continue
+			}
+			r.mu.RLock()
+			unchanged := d.hash() == r.loaded
+			r.mu.RUnlock()
+			if unchanged {
+				continue
+			}
+			if err := r.apply(d); err != nil {
+				slog.Error("TLS certificate reload failed, keeping current certificates", "error", err)
+				continue
+			}
+			slog.Info("TLS certificates reloaded")
+		}
+	}
+}
+
+// decodeSPKIHash decodes a base64 or hex encoded SHA-256 SPKI hash
+func decodeSPKIHash(pin string) ([sha256.Size]byte, error) {
+	var hash [sha256.Size]byte
+	pin = strings.TrimPrefix(strings.TrimSpace(pin), "sha256/")
+
+	var raw []byte
+	var err error
+	if len(pin) == hex.EncodedLen(sha256.Size) {
+		raw, err = hex.DecodeString(pin)
+	} else {
+		raw, err = base64.StdEncoding.DecodeString(pin)
+	}
+	if err != nil || len(raw) != sha256.Size {
+		return hash, fmt.Errorf("invalid SPKI pin %q: expected a base64 or hex encoded SHA-256 hash", pin)
+	}
+
+	copy(hash[:], raw)
+	return hash, nil
+}
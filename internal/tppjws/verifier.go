@@ -0,0 +1,261 @@
+package tppjws
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"consent-service-extensions/internal/apperrors"
+	"consent-service-extensions/internal/fapi"
+	"consent-service-extensions/internal/jose"
+)
+
+// HeaderName is the request header carrying the detached JWS
+const HeaderName = "x-jws-signature"
+
+// OBIE private header parameters
+const (
+	claimIat = "http://openbanking.org.uk/iat"
+	claimIss = "http://openbanking.org.uk/iss"
+	claimTan = "http://openbanking.org.uk/tan"
+)
+
+// maxClockSkew is the tolerated skew for the iat claim
+const maxClockSkew = 5 * time.Minute
+
+// defaultMaxAge is the maximum age of the iat claim when Options.MaxAge is unset
+const defaultMaxAge = 5 * time.Minute
+
+// Options configures the detached JWS verifier
+type Options struct {
+	// KeysDir holds one JWK Set per TPP, named <thirdPartyId>.json
+	KeysDir string
+	// TrustAnchor is the expected tan claim
+	TrustAnchor string
+	// Algorithms are the accepted signing algorithms
+	Algorithms []string
+	// RequiredConsentTypes are the consent types for which the signature is mandatory.
+	// Signatures on other consent types are verified when present.
+	RequiredConsentTypes []string
+	// MaxAge is how old the iat claim may be, in addition to the clock skew. Five
+	// minutes when zero.
+	MaxAge time.Duration
+}
+
+// Verifier verifies OBIE detached JWS signatures of TPP payloads
+type Verifier struct {
+	opts Options
+
+	mu    sync.Mutex
+	cache map[string]cachedKeys
+}
+
+// cachedKeys is a TPP key set with the modification time of its file
+type cachedKeys struct {
+	modTime time.Time
+	keys    []jose.Key
+}
+
+// NewVerifier creates a detached JWS verifier
+func NewVerifier(opts Options) (*Verifier, error) {
+	info, err := os.Stat(opts.KeysDir)
+	if err != nil {
+		return nil, fmt.Errorf("TPP JWKS directory: %w", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("TPP JWKS directory %s is not a directory", opts.KeysDir)
+	}
+	if opts.TrustAnchor == "" {
+		opts.TrustAnchor = "openbanking.org.uk"
+	}
+	if len(opts.Algorithms) == 0 {
+		opts.Algorithms = []string{"PS256"}
+	}
+	if opts.MaxAge <= 0 {
+		opts.MaxAge = defaultMaxAge
+	}
+
+	return &Verifier{opts: opts, cache: make(map[string]cachedKeys)}, nil
+}
+
+// Verify checks the x-jws-signature header in requestHeaders over payload, using
+// the keys of the TPP identified by thirdPartyID. It returns a catalogue
+// business error when the signature is rejected.
+func (v *Verifier) Verify(requestHeaders map[string]interface{}, consentType, thirdPartyID string, payload []byte) error {
+	signature := fapi.Get(requestHeaders, HeaderName)
+	if signature == "" {
+		if v.required(consentType) {
+			return apperrors.ErrSignatureMissing
+		}
+		return nil
+	}
+
+	jws, err := jose.ParseCompact(signature)
+	if err != nil {
+		return apperrors.ErrSignatureMalformed.WithParam("reason", err.Error())
+	}
+	if !jws.Detached() {
+		return apperrors.ErrSignatureMalformed.WithParam("reason", "payload must be detached")
+	}
+
+	if err := v.checkHeader(jws); err != nil {
+		return err
+	}
+
+	if thirdPartyID == "" {
+		return apperrors.ErrSignatureInvalid.WithParam("reason", "the consent has no thirdPartyId attribute")
+	}
+	keys, err := v.keys(thirdPartyID)
+	if err != nil {
+		return apperrors.ErrSignatureInvalid.WithParam("reason", err.Error())
+	}
+
+	for _, key := range keys {
+		if key.ID != jws.Header.Kid {
+			continue
+		}
+		// A key restricted to one algorithm must not verify signatures of another
+		if key.Algorithm != "" && key.Algorithm != jws.Header.Alg {
+			return apperrors.ErrSignatureInvalid.WithParam("reason", fmt.Sprintf("key %q is restricted to %s, got %s", key.ID, key.Algorithm, jws.Header.Alg))
+		}
+		if err := jws.VerifyDetached(key.Public, payload); err != nil {
+			return apperrors.ErrSignatureInvalid.WithParam("reason", err.Error())
+		}
+		// A TPP's key must not sign on behalf of another TPP
+		if iss, _ := jws.Params[claimIss].(string); !issuedBy(iss, thirdPartyID) {
+			return apperrors.ErrSignatureIssuerMismatch.WithParam("iss", iss).WithParam("thirdPartyId", thirdPartyID)
+		}
+		return nil
+	}
+
+	return apperrors.ErrSignatureInvalid.WithParam("reason", fmt.Sprintf("unknown kid %q for TPP %s", jws.Header.Kid, thirdPartyID))
+}
+
+// checkHeader validates the protected header and the OBIE claims
+func (v *Verifier) checkHeader(jws *jose.JWS) error {
+	h := jws.Header
+
+	if !containsString(v.opts.Algorithms, h.Alg) {
+		return apperrors.ErrSignatureInvalidClaim.WithParam("claim", "alg").WithParam("reason", fmt.Sprintf("algorithm %q is not allowed", h.Alg))
+	}
+	if h.Kid == "" {
+		return apperrors.ErrSignatureMissingClaim.WithParam("claim", "kid")
+	}
+	if h.Typ != "" && !strings.EqualFold(h.Typ, "JOSE") {
+		return apperrors.ErrSignatureInvalidClaim.WithParam("claim", "typ").WithParam("reason", "must be JOSE")
+	}
+	if h.Cty != "" && !strings.EqualFold(h.Cty, "json") && !strings.EqualFold(h.Cty, "application/json") {
+		return apperrors.ErrSignatureInvalidClaim.WithParam("claim", "cty").WithParam("reason", "must be application/json")
+	}
+	if h.B64 == nil {
+		return apperrors.ErrSignatureMissingClaim.WithParam("claim", "b64")
+	}
+	if *h.B64 {
+		return apperrors.ErrSignatureInvalidClaim.WithParam("claim", "b64").WithParam("reason", "must be false")
+	}
+
+	// Every critical parameter must be understood, and the OBIE claims must be marked critical
+	expectedCrit := []string{"b64", claimIat, claimIss, claimTan}
+	for _, name := range h.Crit {
+		if !containsString(expectedCrit, name) {
+			return apperrors.ErrSignatureInvalidClaim.WithParam("claim", "crit").WithParam("reason", fmt.Sprintf("unsupported critical parameter %q", name))
+		}
+	}
+	for _, name := range expectedCrit {
+		if !containsString(h.Crit, name) {
+			return apperrors.ErrSignatureInvalidClaim.WithParam("claim", "crit").WithParam("reason", fmt.Sprintf("%q must be critical", name))
+		}
+	}
+
+	iat, ok := jws.Params[claimIat].(float64)
+	if !ok {
+		return apperrors.ErrSignatureMissingClaim.WithParam("claim", claimIat)
+	}
+	issued := time.Unix(int64(iat), 0)
+	if issued.After(time.Now().Add(maxClockSkew)) {
+		return apperrors.ErrSignatureInvalidClaim.WithParam("claim", claimIat).WithParam("reason", "issued in the future")
+	}
+	if issued.Before(time.Now().Add(-v.opts.MaxAge - maxClockSkew)) {
+		return apperrors.ErrSignatureInvalidClaim.WithParam("claim", claimIat).WithParam("reason", "signature has expired")
+	}
+
+	if iss, ok := jws.Params[claimIss].(string); !ok || iss == "" {
+		return apperrors.ErrSignatureMissingClaim.WithParam("claim", claimIss)
+	}
+
+	tan, ok := jws.Params[claimTan].(string)
+	if !ok {
+		return apperrors.ErrSignatureMissingClaim.WithParam("claim", claimTan)
+	}
+	if tan != v.opts.TrustAnchor {
+		return apperrors.ErrSignatureInvalidClaim.WithParam("claim", claimTan).WithParam("reason", fmt.Sprintf("untrusted anchor %q", tan))
+	}
+
+	return nil
+}
+
+// issuedBy reports whether an OBIE iss claim names the TPP. TPPs sign as
+// "<orgId>/<softwareStatementId>", either of which may be the thirdPartyId;
+// a bare iss must equal it.
+func issuedBy(iss, thirdPartyID string) bool {
+	if iss == thirdPartyID {
+		return true
+	}
+	orgID, softwareID, ok := strings.Cut(iss, "/")
+	return ok && (orgID == thirdPartyID || softwareID == thirdPartyID)
+}
+
+// keys returns the JWK Set of a TPP, reloading it when its file changes
+func (v *Verifier) keys(thirdPartyID string) ([]jose.Key, error) {
+	if strings.ContainsAny(thirdPartyID, `/\`) || thirdPartyID == "." || thirdPartyID == ".." {
+		return nil, fmt.Errorf("invalid thirdPartyId %q", thirdPartyID)
+	}
+	path := filepath.Join(v.opts.KeysDir, thirdPartyID+".json")
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("no keys registered for TPP %s", thirdPartyID)
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if cached, ok := v.cache[thirdPartyID]; ok && cached.modTime.Equal(info.ModTime()) {
+		return cached.keys, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read keys for TPP %s", thirdPartyID)
+	}
+	keys, err := jose.ParseKeySet(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid keys for TPP %s", thirdPartyID)
+	}
+
+	v.cache[thirdPartyID] = cachedKeys{modTime: info.ModTime(), keys: keys}
+	return keys, nil
+}
+
+// required reports whether the signature is mandatory for a consent type
+func (v *Verifier) required(consentType string) bool {
+	for _, t := range v.opts.RequiredConsentTypes {
+		if strings.EqualFold(t, consentType) {
+			return true
+		}
+	}
+	return false
+}
+
+// containsString reports whether values contains s
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
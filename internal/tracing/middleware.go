@@ -0,0 +1,50 @@
+package tracing
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// Middleware starts a server span for each request, continuing the trace of
+// an incoming W3C traceparent header. Handlers add attributes and child spans
+// through the request context.
+func (t *Tracer) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// A malformed traceparent starts a new trace, as the W3C spec requires
+		remote, _ := ParseTraceparent(r.Header.Get(HeaderTraceparent))
+
+		route := r.URL.Path
+		if current := mux.CurrentRoute(r); current != nil {
+			if tpl, err := current.GetPathTemplate(); err == nil {
+				route = tpl
+			}
+		}
+
+		ctx, span := t.Start(r.Context(), r.Method+" "+route, KindServer, remote)
+		defer span.End()
+		span.SetAttribute("http.request.method", r.Method)
+		span.SetAttribute("http.route", route)
+
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(sw, r.WithContext(ctx))
+
+		span.SetAttribute("http.response.status_code", sw.status)
+		if sw.status >= http.StatusInternalServerError {
+			span.RecordError(fmt.Errorf("%d %s", sw.status, http.StatusText(sw.status)))
+		}
+	})
+}
+
+// statusWriter records the status code of a response
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+// WriteHeader records the status code
+func (w *statusWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
@@ -0,0 +1,287 @@
+package tracing
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Span kinds, as numbered by OTLP
+const (
+	KindInternal = 1
+	KindServer   = 2
+)
+
+// Defaults of the batching tracer
+const (
+	DefaultBatchSize     = 512
+	DefaultBatchInterval = 5 * time.Second
+	queueSize            = 2048
+)
+
+// Tracer creates spans and exports the sampled ones in batches
+type Tracer struct {
+	exporter      Exporter
+	batchSize     int
+	batchInterval time.Duration
+
+	queue    chan SpanData
+	flush    chan chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+	dropped  atomic.Uint64
+}
+
+// Option configures a Tracer
+type Option func(*Tracer)
+
+// WithBatchSize exports as soon as n spans are queued
+func WithBatchSize(n int) Option {
+	return func(t *Tracer) {
+		t.batchSize = n
+	}
+}
+
+// WithBatchInterval exports queued spans at least every d
+func WithBatchInterval(d time.Duration) Option {
+	return func(t *Tracer) {
+		t.batchInterval = d
+	}
+}
+
+// NewTracer creates a tracer exporting to exporter, and starts its export loop
+func NewTracer(exporter Exporter, opts ...Option) *Tracer {
+	t := &Tracer{
+		exporter:      exporter,
+		batchSize:     DefaultBatchSize,
+		batchInterval: DefaultBatchInterval,
+		queue:         make(chan SpanData, queueSize),
+		flush:         make(chan chan struct{}),
+		done:          make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+	go t.run()
+	return t
+}
+
+// Start starts a span named name. The parent is the span in ctx or, for a
+// root span, the remote span context if valid. Root spans without a remote
+// parent are always sampled.
+func (t *Tracer) Start(ctx context.Context, name string, kind int, remote SpanContext) (context.Context, *Span) {
+	sc := SpanContext{SpanID: newSpanID(), Sampled: true}
+	var parent SpanID
+	if p := SpanFromContext(ctx); p != nil {
+		sc.TraceID, sc.Sampled, parent = p.sc.TraceID, p.sc.Sampled, p.sc.SpanID
+	} else if remote.IsValid() {
+		sc.TraceID, sc.Sampled, parent = remote.TraceID, remote.Sampled, remote.SpanID
+	} else {
+		sc.TraceID = newTraceID()
+	}
+
+	span := &Span{
+		tracer: t,
+		data: SpanData{
+			Name:       name,
+			Kind:       kind,
+			TraceID:    sc.TraceID,
+			SpanID:     sc.SpanID,
+			ParentID:   parent,
+			Start:      time.Now(),
+			Attributes: make(map[string]interface{}),
+		},
+		sc: sc,
+	}
+	return ContextWithSpan(ctx, span), span
+}
+
+// Dropped returns the number of spans dropped because the export queue was full
+func (t *Tracer) Dropped() uint64 {
+	return t.dropped.Load()
+}
+
+// Flush exports every queued span
+func (t *Tracer) Flush(ctx context.Context) {
+	ack := make(chan struct{})
+	select {
+	case t.flush <- ack:
+		select {
+		case <-ack:
+		case <-ctx.Done():
+		}
+	case <-t.done:
+	case <-ctx.Done():
+	}
+}
+
+// Shutdown flushes queued spans, stops the export loop and shuts the exporter down
+func (t *Tracer) Shutdown(ctx context.Context) error {
+	t.Flush(ctx)
+	t.stopOnce.Do(func() { close(t.done) })
+	return t.exporter.Shutdown(ctx)
+}
+
+// enqueue queues an ended span, dropping it rather than blocking the request
+func (t *Tracer) enqueue(data SpanData) {
+	select {
+	case <-t.done:
+		t.dropped.Add(1)
+		return
+	default:
+	}
+	select {
+	case t.queue <- data:
+	default:
+		t.dropped.Add(1)
+	}
+}
+
+// run exports batches when they are full, on every interval and on flush
+func (t *Tracer) run() {
+	ticker := time.NewTicker(t.batchInterval)
+	defer ticker.Stop()
+
+	var batch []SpanData
+	export := func() {
+		if len(batch) == 0 {
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), t.batchInterval)
+		defer cancel()
+		if err := t.exporter.Export(ctx, batch); err != nil {
+			slog.Warn("Failed to export spans", "spans", len(batch), "error", err)
+		}
+		batch = nil
+	}
+
+	for {
+		select {
+		case data := <-t.queue:
+			batch = append(batch, data)
+			if len(batch) >= t.batchSize {
+				export()
+			}
+		case <-ticker.C:
+			export()
+		case ack := <-t.flush:
+			for drained := false; !drained; {
+				select {
+				case data := <-t.queue:
+					batch = append(batch, data)
+				default:
+					drained = true
+				}
+			}
+			export()
+			close(ack)
+		case <-t.done:
+			return
+		}
+	}
+}
+
+// SpanData is the recorded content of an ended span
+type SpanData struct {
+	Name       string
+	Kind       int
+	TraceID    TraceID
+	SpanID     SpanID
+	ParentID   SpanID
+	Start      time.Time
+	End        time.Time
+	Attributes map[string]interface{}
+	// Err is the error recorded on the span, if any
+	Err string
+}
+
+// Span is an operation being traced. A nil Span is a no-op, so callers do
+// not need to check whether tracing is enabled.
+type Span struct {
+	tracer *Tracer
+	sc     SpanContext
+
+	mu    sync.Mutex
+	data  SpanData
+	ended bool
+}
+
+// SpanContext returns the span's propagated identity
+func (s *Span) SpanContext() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return s.sc
+}
+
+// SetAttribute records a string, bool, integer or float attribute. Empty
+// strings are skipped.
+func (s *Span) SetAttribute(key string, value interface{}) {
+	if s == nil {
+		return
+	}
+	if str, ok := value.(string); ok && str == "" {
+		return
+	}
+	s.mu.Lock()
+	if !s.ended {
+		s.data.Attributes[key] = value
+	}
+	s.mu.Unlock()
+}
+
+// RecordError marks the span as failed with err. A nil error is ignored.
+func (s *Span) RecordError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.mu.Lock()
+	s.data.Err = err.Error()
+	s.mu.Unlock()
+}
+
+// End ends the span and queues it for export if sampled
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.data.End = time.Now()
+	data := s.data
+	s.mu.Unlock()
+
+	if s.sc.Sampled {
+		s.tracer.enqueue(data)
+	}
+}
+
+// spanKey is the context key of the current span
+type spanKey struct{}
+
+// ContextWithSpan returns a context carrying span as the current span
+func ContextWithSpan(ctx context.Context, span *Span) context.Context {
+	return context.WithValue(ctx, spanKey{}, span)
+}
+
+// SpanFromContext returns the current span, or nil when the request is not traced
+func SpanFromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanKey{}).(*Span)
+	return span
+}
+
+// Start starts a child of the current span. Without a current span it returns
+// ctx and a nil, no-op span.
+func Start(ctx context.Context, name string) (context.Context, *Span) {
+	parent := SpanFromContext(ctx)
+	if parent == nil {
+		return ctx, nil
+	}
+	return parent.tracer.Start(ctx, name, KindInternal, SpanContext{})
+}
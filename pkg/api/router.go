@@ -1,34 +1,355 @@
 package api
 
 import (
+	"errors"
+	"log/slog"
+	"net"
 	"net/http"
+	"time"
 
+	"consent-service-extensions/internal/audit"
+	"consent-service-extensions/internal/auth"
+	"consent-service-extensions/internal/capture"
+	"consent-service-extensions/internal/config"
+	"consent-service-extensions/internal/counter"
+	"consent-service-extensions/internal/fapi"
 	"consent-service-extensions/internal/handlers"
+	"consent-service-extensions/internal/health"
+	"consent-service-extensions/internal/idempotency"
+	"consent-service-extensions/internal/logging"
+	"consent-service-extensions/internal/metrics"
+	"consent-service-extensions/internal/netacl"
+	"consent-service-extensions/internal/ratelimit"
+	"consent-service-extensions/internal/replay"
+	"consent-service-extensions/internal/requestbody"
+	"consent-service-extensions/internal/response"
+	"consent-service-extensions/internal/rules"
+	"consent-service-extensions/internal/signing"
+	"consent-service-extensions/internal/tppjws"
+	"consent-service-extensions/internal/tracing"
 
 	"github.com/gorilla/mux"
 )
 
+// Option configures the router
+type Option func(*routerOptions)
+
+// routerOptions holds the settings used to build the router
+type routerOptions struct {
+	cfg            *config.Config
+	authenticators []auth.Authenticator
+	signingKeys    *signing.KeySource
+	tppVerifier    *tppjws.Verifier
+	fapiValidator  *fapi.Validator
+
+	idempotencyStore idempotency.Store
+	idempotencyTTL   time.Duration
+	replayGuard      *replay.Guard
+	rateLimiter      *ratelimit.Limiter
+	accessCounter    counter.Store
+	accessLocation   *time.Location
+	networkACLs      map[string]*netacl.ACL
+	logger           *slog.Logger
+	metrics          *metrics.Metrics
+	tracer           *tracing.Tracer
+	auditLog         *audit.Log
+	recorder         *capture.Recorder
+	healthState      *health.State
+	readiness        *health.Registry
+	rules            *rules.Store
+}
+
+// WithConfig builds the router from the given application configuration
+func WithConfig(cfg *config.Config) Option {
+	return func(o *routerOptions) {
+		o.cfg = cfg
+	}
+}
+
+// WithAuthenticators requires every /api/services request to authenticate with one of the given authenticators
+func WithAuthenticators(authenticators ...auth.Authenticator) Option {
+	return func(o *routerOptions) {
+		o.authenticators = append(o.authenticators, authenticators...)
+	}
+}
+
+// WithSigningKeys verifies request signatures and signs responses on every /api/services request
+func WithSigningKeys(keys *signing.Keys) Option {
+	return func(o *routerOptions) {
+		o.signingKeys = signing.StaticKeys(keys)
+	}
+}
+
+// WithSigningKeySource is WithSigningKeys with keys that are reread on every request
+func WithSigningKeySource(source *signing.KeySource) Option {
+	return func(o *routerOptions) {
+		o.signingKeys = source
+	}
+}
+
+// WithTPPSignatureVerifier verifies the TPP's detached JWS on consent and file payloads
+func WithTPPSignatureVerifier(v *tppjws.Verifier) Option {
+	return func(o *routerOptions) {
+		o.tppVerifier = v
+	}
+}
+
+// WithHeaderValidator validates forwarded FAPI headers against the given profile
+func WithHeaderValidator(v *fapi.Validator) Option {
+	return func(o *routerOptions) {
+		o.fapiValidator = v
+	}
+}
+
+// WithIdempotencyStore replays consent creation responses for repeated x-idempotency-key values
+func WithIdempotencyStore(store idempotency.Store, ttl time.Duration) Option {
+	return func(o *routerOptions) {
+		o.idempotencyStore = store
+		o.idempotencyTTL = ttl
+	}
+}
+
+// WithReplayGuard rejects /api/services requests that repeat a requestId within the guard's window
+func WithReplayGuard(g *replay.Guard) Option {
+	return func(o *routerOptions) {
+		o.replayGuard = g
+	}
+}
+
+// WithRateLimiter throttles /api/services requests and enforces daily consent creation quotas
+func WithRateLimiter(l *ratelimit.Limiter) Option {
+	return func(o *routerOptions) {
+		o.rateLimiter = l
+	}
+}
+
+// WithAccessCounter enforces consent access frequency limits on retrieval, with
+// calendar days in loc
+func WithAccessCounter(store counter.Store, loc *time.Location) Option {
+	return func(o *routerOptions) {
+		o.accessCounter = store
+		o.accessLocation = loc
+	}
+}
+
+// WithNetworkACL restricts a route group (netacl.GroupAPI or netacl.GroupOps) to the addresses the ACL allows
+func WithNetworkACL(group string, acl *netacl.ACL) Option {
+	return func(o *routerOptions) {
+		if o.networkACLs == nil {
+			o.networkACLs = make(map[string]*netacl.ACL)
+		}
+		o.networkACLs[group] = acl
+	}
+}
+
+// WithLogger logs /api/services requests with their requestId, interaction id, consent type and endpoint
+func WithLogger(logger *slog.Logger) Option {
+	return func(o *routerOptions) {
+		o.logger = logger
+	}
+}
+
+// WithMetrics instruments /api/services requests and serves them on /metrics
+func WithMetrics(m *metrics.Metrics) Option {
+	return func(o *routerOptions) {
+		o.metrics = m
+	}
+}
+
+// WithTracer traces /api/services requests, continuing incoming W3C trace contexts
+func WithTracer(t *tracing.Tracer) Option {
+	return func(o *routerOptions) {
+		o.tracer = t
+	}
+}
+
+// WithAuditLog records the decision on every /api/services request in a hash chained audit log
+func WithAuditLog(l *audit.Log) Option {
+	return func(o *routerOptions) {
+		o.auditLog = l
+	}
+}
+
+// WithCapture writes redacted /api/services request/response pairs for replay
+func WithCapture(rec *capture.Recorder) Option {
+	return func(o *routerOptions) {
+		o.recorder = rec
+	}
+}
+
+// WithHealthState reports the service as not ready on /health while state is draining
+func WithHealthState(state *health.State) Option {
+	return func(o *routerOptions) {
+		o.healthState = state
+	}
+}
+
+// WithReadinessChecks runs the registry's dependency checks on /health/ready
+func WithReadinessChecks(registry *health.Registry) Option {
+	return func(o *routerOptions) {
+		o.readiness = registry
+	}
+}
+
+// WithRules applies the store's active rule set to consent requests and reports
+// its version and hash on /admin/rules
+func WithRules(store *rules.Store) Option {
+	return func(o *routerOptions) {
+		o.rules = store
+	}
+}
+
 // NewRouter creates and configures the main application router
-func NewRouter() *mux.Router {
+func NewRouter(opts ...Option) *mux.Router {
+	options := routerOptions{cfg: &config.Config{}, logger: slog.Default(), readiness: health.NewRegistry(0)}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	cfg := options.cfg
+
 	router := mux.NewRouter()
+	errorWriter := response.ErrorWriter{Format: cfg.ErrorResponseFormat}
 
 	// Create handlers
-	consentHandler := handlers.NewConsentHandler()
+	var handlerOpts []handlers.Option
+	if cfg.ErrorResponseFormat != "" {
+		handlerOpts = append(handlerOpts, handlers.WithErrorResponseFormat(cfg.ErrorResponseFormat))
+	}
+	if options.tppVerifier != nil {
+		handlerOpts = append(handlerOpts, handlers.WithSignatureVerifier(options.tppVerifier))
+	}
+	if options.fapiValidator != nil {
+		handlerOpts = append(handlerOpts, handlers.WithHeaderValidator(options.fapiValidator))
+	}
+	if options.idempotencyStore != nil {
+		handlerOpts = append(handlerOpts, handlers.WithIdempotencyStore(options.idempotencyStore, options.idempotencyTTL))
+	}
+	if options.accessCounter != nil {
+		handlerOpts = append(handlerOpts, handlers.WithAccessCounter(options.accessCounter, options.accessLocation))
+	}
+	if options.metrics != nil {
+		handlerOpts = append(handlerOpts, handlers.WithObserver(options.metrics))
+	}
+	if options.rules != nil {
+		handlerOpts = append(handlerOpts, handlers.WithRules(options.rules))
+	}
+	if len(cfg.Purposes.Mapping) > 0 {
+		handlerOpts = append(handlerOpts, handlers.WithPurposeMapping(cfg.Purposes.Mapping))
+	}
+	consentHandler := handlers.NewConsentHandler(handlerOpts...)
 
 	// Register routes
 	api := router.PathPrefix("/api/services").Subrouter()
 
+	// The server span covers every other middleware
+	if options.tracer != nil {
+		api.Use(options.tracer.Middleware)
+	}
+
+	// Bodies are read once, before any middleware inspects them
+	bodyFailure := func(w http.ResponseWriter, r *http.Request, err error) {
+		if errors.Is(err, requestbody.ErrTooLarge) {
+			errorWriter.Write(w, r, nil, http.StatusRequestEntityTooLarge, "request_too_large", "Request body is too large", err.Error(), "")
+			return
+		}
+		errorWriter.Write(w, r, nil, http.StatusBadRequest, "invalid_request", "Invalid request body", err.Error(), "")
+	}
+	api.Use(requestbody.Middleware(bodyFailure))
+
+	// Request scoped logging wraps every other middleware
+	api.Use(logging.Middleware(options.logger))
+
+	// Every call is audited, including those rejected by later middlewares
+	if options.auditLog != nil {
+		api.Use(options.auditLog.Middleware)
+	}
+
+	// Captures hold the responses as the caller saw them
+	if options.recorder != nil {
+		api.Use(options.recorder.Middleware)
+	}
+
+	// Metrics see every response, including middleware rejections
+	if options.metrics != nil {
+		api.Use(options.metrics.Middleware)
+	}
+
+	// Network ACLs run before authentication so denied callers never reach it
+	denied := func(w http.ResponseWriter, r *http.Request, source net.IP) {
+		errorWriter.Write(w, r, response.RequestHeaders(r), http.StatusForbidden, "access_denied", "Source address is not allowed", "", "")
+	}
+	if acl := options.networkACLs[netacl.GroupAPI]; acl != nil {
+		api.Use(acl.Middleware(netacl.GroupAPI, denied))
+	}
+	ops := func(h http.HandlerFunc) http.Handler {
+		if acl := options.networkACLs[netacl.GroupOps]; acl != nil {
+			return acl.Middleware(netacl.GroupOps, denied)(h)
+		}
+		return h
+	}
+
+	// Authentication, health check stays open
+	if len(options.authenticators) > 0 {
+		api.Use(auth.Middleware(func(w http.ResponseWriter, r *http.Request, err error) {
+			if errors.Is(err, auth.ErrInsufficientScope) {
+				errorWriter.Write(w, r, response.RequestHeaders(r), http.StatusForbidden, "insufficient_scope", "Access token lacks the required scope", err.Error(), "")
+				return
+			}
+			errorWriter.Write(w, r, response.RequestHeaders(r), http.StatusUnauthorized, "unauthorized", "Authentication failed", err.Error(), "")
+		}, options.authenticators...))
+	}
+
+	// Rate limits and quotas, after authentication so callers are known
+	if options.rateLimiter != nil {
+		api.Use(options.rateLimiter.Middleware(func(w http.ResponseWriter, r *http.Request, err error) {
+			if requestbody.IsReadError(err) {
+				bodyFailure(w, r, err)
+				return
+			}
+			if errors.Is(err, ratelimit.ErrQuotaExceeded) {
+				errorWriter.Write(w, r, response.RequestHeaders(r), http.StatusTooManyRequests, "quota_exceeded", "Daily consent creation quota exceeded", err.Error(), "")
+				return
+			}
+			errorWriter.Write(w, r, response.RequestHeaders(r), http.StatusTooManyRequests, "rate_limited", "Too many requests", err.Error(), "")
+		}))
+	}
+
+	// Message-level signatures
+	if options.signingKeys != nil {
+		maxAge := cfg.Signing.MaxAge
+		if maxAge == 0 {
+			maxAge = 5 * time.Minute
+		}
+		api.Use(signing.NewMiddleware(options.signingKeys, maxAge, func(w http.ResponseWriter, r *http.Request, err error) {
+			if requestbody.IsReadError(err) {
+				bodyFailure(w, r, err)
+				return
+			}
+			errorWriter.Write(w, r, response.RequestHeaders(r), http.StatusUnauthorized, "invalid_signature", "Request signature verification failed", err.Error(), "")
+		}).Handler)
+	}
+
+	// Replay protection on requestId
+	if options.replayGuard != nil {
+		api.Use(options.replayGuard.Middleware(func(w http.ResponseWriter, r *http.Request, requestID string, err error) {
+			if requestbody.IsReadError(err) {
+				bodyFailure(w, r, err)
+				return
+			}
+			errorWriter.Write(w, r, response.RequestHeaders(r), http.StatusConflict, "duplicate_request", "The requestId was already processed", err.Error(), requestID)
+		}))
+	}
+
 	// Consent endpoints
 	api.HandleFunc("/pre-process-consent-creation", consentHandler.PreProcessConsentCreation).Methods(http.MethodPost)
+	api.HandleFunc("/enrich-consent-creation-response", consentHandler.EnrichConsentCreationResponse).Methods(http.MethodPost)
 	api.HandleFunc("/pre-process-consent-update", consentHandler.PreProcessConsentUpdate).Methods(http.MethodPost)
+	api.HandleFunc("/enrich-consent-update-response", consentHandler.EnrichConsentUpdateResponse).Methods(http.MethodPost)
+	api.HandleFunc("/pre-process-consent-file-upload", consentHandler.PreProcessConsentFileUpload).Methods(http.MethodPost)
+	api.HandleFunc("/pre-process-consent-retrieval", consentHandler.PreProcessConsentRetrieval).Methods(http.MethodPost)
 
 	// TODO: Add more endpoints as needed:
-	// api.HandleFunc("/enrich-consent-creation-response", consentHandler.EnrichConsentCreationResponse).Methods(http.MethodPost)
-	// api.HandleFunc("/pre-process-consent-retrieval", consentHandler.PreProcessConsentRetrieval).Methods(http.MethodPost)
-	// api.HandleFunc("/enrich-consent-update-response", consentHandler.EnrichConsentUpdateResponse).Methods(http.MethodPost)
-	// api.HandleFunc("/enrich-consent-update-response", consentHandler.EnrichConsentUpdateResponse).Methods(http.MethodPost)
 	// api.HandleFunc("/pre-process-consent-revoke", consentHandler.PreProcessConsentRevoke).Methods(http.MethodPost)
-	// api.HandleFunc("/pre-process-consent-file-upload", consentHandler.PreProcessConsentFileUpload).Methods(http.MethodPost)
 	// api.HandleFunc("/enrich-consent-file-response", consentHandler.EnrichConsentFileResponse).Methods(http.MethodPost)
 	// api.HandleFunc("/validate-consent-file-retrieval", consentHandler.ValidateConsentFileRetrieval).Methods(http.MethodPost)
 	// api.HandleFunc("/pre-process-consent-file-update", consentHandler.PreProcessConsentFileUpdate).Methods(http.MethodPost)
@@ -36,16 +357,85 @@ func NewRouter() *mux.Router {
 	// api.HandleFunc("/map-accelerator-error-response", errorHandler.MapAcceleratorErrorResponse).Methods(http.MethodPost)
 
 	// Health check endpoint
-	router.HandleFunc("/health", healthCheckHandler).Methods(http.MethodGet)
+	router.Handle("/health", ops(healthCheckHandler(options.healthState))).Methods(http.MethodGet)
+	router.Handle("/health/live", ops(livenessHandler)).Methods(http.MethodGet)
+	router.Handle("/health/ready", ops(readinessHandler(options.readiness, options.healthState))).Methods(http.MethodGet)
+
+	// Replay guard statistics
+	if options.replayGuard != nil {
+		router.Handle("/stats/replay", ops(replayStatsHandler(options.replayGuard))).Methods(http.MethodGet)
+	}
+
+	// Active rule set
+	if options.rules != nil {
+		router.Handle("/admin/rules", ops(rulesStatusHandler(options.rules))).Methods(http.MethodGet)
+	}
+
+	// Prometheus metrics
+	if options.metrics != nil {
+		if options.replayGuard != nil {
+			guard := options.replayGuard
+			options.metrics.Registry().NewCounterFunc("consent_extensions_replay_rejections_total",
+				"Requests rejected for repeating a requestId.", func() float64 { return float64(guard.Rejected()) })
+		}
+		router.Handle("/metrics", ops(options.metrics.Handler)).Methods(http.MethodGet)
+	}
 
 	return router
 }
 
-// healthCheckHandler returns service health status
-func healthCheckHandler(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	if _, err := w.Write([]byte(`{"status":"healthy"}`)); err != nil {
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+// healthCheckHandler returns service health status, which is not ready while draining
+func healthCheckHandler(state *health.State) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		status, body := http.StatusOK, `{"status":"healthy"}`
+		if state.Draining() {
+			status, body = http.StatusServiceUnavailable, `{"status":"draining"}`
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		if _, err := w.Write([]byte(body)); err != nil {
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		}
+	}
+}
+
+// livenessHandler reports that the process is serving requests
+func livenessHandler(w http.ResponseWriter, r *http.Request) {
+	response.WriteJSON(w, http.StatusOK, map[string]string{"status": "alive"})
+}
+
+// readinessHandler runs the dependency checks, returning 503 when a critical
+// check fails or the server is draining
+func readinessHandler(registry *health.Registry, state *health.State) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		report := registry.Run(r.Context())
+		if state.Draining() {
+			report.Status = health.StatusDraining
+		}
+
+		status := http.StatusOK
+		if !report.Ready() {
+			status = http.StatusServiceUnavailable
+		}
+		response.WriteJSON(w, status, report)
+	}
+}
+
+// rulesStatusHandler reports the version and hash of the active rule set and
+// the last rejected reload
+func rulesStatusHandler(store *rules.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		response.WriteJSON(w, http.StatusOK, store.Status())
+	}
+}
+
+// replayStatsHandler reports the replay guard's counters
+func replayStatsHandler(g *replay.Guard) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		response.WriteJSON(w, http.StatusOK, map[string]interface{}{
+			"rejectedReplays":   g.Rejected(),
+			"trackedRequestIds": g.Tracked(),
+		})
 	}
 }
@@ -0,0 +1,224 @@
+package integration
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"consent-service-extensions/internal/counter"
+	"consent-service-extensions/internal/models"
+	"consent-service-extensions/pkg/api"
+)
+
+// postConsentRetrieval sends a consent retrieval request for a stored consent
+func postConsentRetrieval(t *testing.T, serverURL string, consent models.StoredDetailedConsentResourceData) map[string]interface{} {
+	t.Helper()
+
+	requestBody := models.PreProcessConsentRetrievalRequest{
+		RequestID: "REQ-RETRIEVAL",
+		Data: models.PreProcessConsentRetrievalData{
+			ConsentResource: consent,
+			RequestHeaders: map[string]interface{}{
+				"x-fapi-interaction-id": "93bac548-d2de-4546-b106-880a5018460d",
+			},
+		},
+	}
+
+	body, _ := json.Marshal(requestBody)
+	resp, err := http.Post(serverURL+"/api/services/pre-process-consent-retrieval", "application/json", bytes.NewBuffer(body))
+	if err != nil {
+		t.Fatalf("Failed to send request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", resp.StatusCode)
+	}
+
+	var result map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	return result
+}
+
+func TestPreProcessConsentRetrieval_Success(t *testing.T) {
+	server := httptest.NewServer(api.NewRouter())
+	defer server.Close()
+
+	result := postConsentRetrieval(t, server.URL, models.StoredDetailedConsentResourceData{
+		ID:     "consent-1",
+		Type:   "accounts",
+		Status: "authorised",
+	})
+
+	if result["status"] != "SUCCESS" {
+		t.Fatalf("Expected SUCCESS, got %v", result)
+	}
+	data, _ := result["data"].(map[string]interface{})
+	headers, _ := data["responseHeaders"].(map[string]interface{})
+	if headers["x-fapi-interaction-id"] != "93bac548-d2de-4546-b106-880a5018460d" {
+		t.Errorf("Expected the interaction id to be echoed, got %v", data)
+	}
+}
+
+func TestAccessFrequency_RejectsOverLimit(t *testing.T) {
+	server := httptest.NewServer(api.NewRouter(api.WithAccessCounter(counter.NewMemoryStore(), time.UTC)))
+	defer server.Close()
+
+	consent := models.StoredDetailedConsentResourceData{ID: "consent-1", Type: "accounts", Status: "authorised", Frequency: 2}
+	for i := 0; i < 2; i++ {
+		if result := postConsentRetrieval(t, server.URL, consent); result["status"] != "SUCCESS" {
+			t.Fatalf("Expected access %d to pass, got %v", i+1, result)
+		}
+	}
+
+	result := postConsentRetrieval(t, server.URL, consent)
+	if result["status"] != "ERROR" || result["errorCode"] != float64(http.StatusTooManyRequests) {
+		t.Fatalf("Expected a FailedResponse with errorCode 429, got %v", result)
+	}
+	data, _ := result["data"].(map[string]interface{})
+	if data["errorDescription"] != "Consent consent-1 has reached its limit of 2 accesses per day" {
+		t.Errorf("Unexpected failed response data: %v", data)
+	}
+
+	// Other consents are counted separately
+	other := consent
+	other.ID = "consent-2"
+	if result := postConsentRetrieval(t, server.URL, other); result["status"] != "SUCCESS" {
+		t.Errorf("Expected another consent to pass, got %v", result)
+	}
+}
+
+func TestAccessFrequency_MaxFrequencyPerDayAttribute(t *testing.T) {
+	server := httptest.NewServer(api.NewRouter(api.WithAccessCounter(counter.NewMemoryStore(), time.UTC)))
+	defer server.Close()
+
+	consent := models.StoredDetailedConsentResourceData{
+		ID:         "consent-1",
+		Type:       "accounts",
+		Status:     "authorised",
+		Frequency:  10,
+		Attributes: map[string]interface{}{"maxFrequencyPerDay": "1"},
+	}
+
+	postConsentRetrieval(t, server.URL, consent)
+	if result := postConsentRetrieval(t, server.URL, consent); result["status"] != "ERROR" {
+		t.Errorf("Expected maxFrequencyPerDay to take precedence over frequency, got %v", result)
+	}
+}
+
+func TestAccessFrequency_ZeroMeansUnlimited(t *testing.T) {
+	store := counter.NewMemoryStore()
+	server := httptest.NewServer(api.NewRouter(api.WithAccessCounter(store, time.UTC)))
+	defer server.Close()
+
+	consent := models.StoredDetailedConsentResourceData{ID: "consent-1", Type: "accounts", Status: "authorised"}
+	for i := 0; i < 3; i++ {
+		if result := postConsentRetrieval(t, server.URL, consent); result["status"] != "SUCCESS" {
+			t.Fatalf("Expected unlimited access, got %v", result)
+		}
+	}
+	if count, _ := store.Count(context.Background(), "consent-1", counter.Day(time.Now(), time.UTC)); count != 0 {
+		t.Errorf("Expected unlimited consents not to be counted, got %d", count)
+	}
+}
+
+func TestAccessCounter_Stores(t *testing.T) {
+	dir := t.TempDir()
+	stores := map[string]func() (counter.Store, error){
+		"memory": func() (counter.Store, error) { return counter.NewMemoryStore(), nil },
+		"file":   func() (counter.Store, error) { return counter.NewFileStore(filepath.Join(dir, "counters.json")) },
+		"bolt":   func() (counter.Store, error) { return counter.NewBoltStore(filepath.Join(dir, "counters.db")) },
+	}
+
+	for name, open := range stores {
+		t.Run(name, func(t *testing.T) {
+			store, err := open()
+			if err != nil {
+				t.Fatalf("Failed to open store: %v", err)
+			}
+			defer store.Close()
+			ctx := context.Background()
+
+			for i := int64(1); i <= 2; i++ {
+				if count, err := store.Increment(ctx, "consent-1", "2026-03-01", 2); err != nil || count != i {
+					t.Fatalf("Expected count %d, got %d (%v)", i, count, err)
+				}
+			}
+			if count, err := store.Increment(ctx, "consent-1", "2026-03-01", 2); err != counter.ErrLimitExceeded || count != 2 {
+				t.Errorf("Expected ErrLimitExceeded at 2, got %d (%v)", count, err)
+			}
+
+			// A decrement frees a slot and never goes below zero
+			for i := 0; i < 3; i++ {
+				if err := store.Decrement(ctx, "consent-1", "2026-03-01"); err != nil {
+					t.Fatalf("Failed to decrement: %v", err)
+				}
+			}
+			if count, _ := store.Count(ctx, "consent-1", "2026-03-01"); count != 0 {
+				t.Errorf("Expected decrements to stop at 0, got %d", count)
+			}
+			if count, err := store.Increment(ctx, "consent-1", "2026-03-01", 2); err != nil || count != 1 {
+				t.Errorf("Expected a freed slot to be reusable, got %d (%v)", count, err)
+			}
+
+			// A new day starts a new counter and prunes counters older than the previous day
+			if count, _ := store.Increment(ctx, "consent-1", "2026-03-03", 2); count != 1 {
+				t.Errorf("Expected a new day to start at 1, got %d", count)
+			}
+			if count, _ := store.Count(ctx, "consent-1", "2026-03-01"); count != 0 {
+				t.Errorf("Expected stale counters to be pruned, got %d", count)
+			}
+
+			if _, err := store.Increment(ctx, "consent-1", "yesterday", 0); err == nil {
+				t.Error("Expected an invalid day to be rejected")
+			}
+		})
+	}
+}
+
+func TestAccessCounter_BoltStoreSurvivesReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "counters.db")
+	ctx := context.Background()
+
+	store, err := counter.NewBoltStore(path)
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	store.Increment(ctx, "consent-1", "2026-03-01", 0)
+	store.Close()
+
+	reopened, err := counter.NewBoltStore(path)
+	if err != nil {
+		t.Fatalf("Failed to reopen store: %v", err)
+	}
+	defer reopened.Close()
+	if count, _ := reopened.Count(ctx, "consent-1", "2026-03-01"); count != 1 {
+		t.Errorf("Expected the count to survive reopening, got %d", count)
+	}
+}
+
+func TestAccessCounter_TimezoneDayBoundary(t *testing.T) {
+	sydney, err := time.LoadLocation("Australia/Sydney")
+	if err != nil {
+		t.Skipf("Time zone data unavailable: %v", err)
+	}
+
+	// 20:00 UTC on 1 March is already 2 March in Sydney
+	instant := time.Date(2026, 3, 1, 20, 0, 0, 0, time.UTC)
+	if day := counter.Day(instant, time.UTC); day != "2026-03-01" {
+		t.Errorf("Expected 2026-03-01 in UTC, got %s", day)
+	}
+	if day := counter.Day(instant, sydney); day != "2026-03-02" {
+		t.Errorf("Expected 2026-03-02 in Sydney, got %s", day)
+	}
+	if next := counter.NextDay(instant, sydney); !next.Equal(time.Date(2026, 3, 3, 0, 0, 0, 0, sydney)) {
+		t.Errorf("Expected the next day to start at Sydney midnight, got %s", next)
+	}
+}
@@ -0,0 +1,193 @@
+package integration
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"consent-service-extensions/internal/audit"
+	"consent-service-extensions/internal/models"
+	"consent-service-extensions/pkg/api"
+)
+
+// postAuditedConsent sends a consent creation request and returns its raw body
+func postAuditedConsent(t *testing.T, serverURL, requestID string, data models.DetailedConsentResourceData) []byte {
+	t.Helper()
+
+	body, _ := json.Marshal(models.PreProcessConsentCreationRequest{
+		RequestID: requestID,
+		Data:      models.Request{ConsentInitiationData: data},
+	})
+	resp, err := http.Post(serverURL+"/api/services/pre-process-consent-creation", "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("Failed to send request: %v", err)
+	}
+	resp.Body.Close()
+	return body
+}
+
+// readAuditRecords decodes every record of an audit log file
+func readAuditRecords(t *testing.T, path string) []audit.Record {
+	t.Helper()
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Failed to open audit log: %v", err)
+	}
+	defer f.Close()
+
+	var records []audit.Record
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var rec audit.Record
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			t.Fatalf("Failed to decode audit record: %v", err)
+		}
+		records = append(records, rec)
+	}
+	return records
+}
+
+// verifyAuditLog verifies the chain across the log at path and its rotated files
+func verifyAuditLog(t *testing.T, path string) (audit.Verifier, error) {
+	t.Helper()
+
+	var v audit.Verifier
+	for _, name := range audit.Files(path) {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", name, err)
+		}
+		if err := v.Verify(name, bytes.NewReader(data)); err != nil {
+			return v, err
+		}
+	}
+	return v, nil
+}
+
+// newAuditedServer starts a router writing its audit log to a file sink at path
+func newAuditedServer(t *testing.T, path string, maxBytes int64) (*httptest.Server, *audit.Log) {
+	t.Helper()
+
+	sink, err := audit.NewFileSink(path, maxBytes, 5)
+	if err != nil {
+		t.Fatalf("Failed to open audit sink: %v", err)
+	}
+	auditLog, err := audit.New(nil, sink)
+	if err != nil {
+		t.Fatalf("Failed to create audit log: %v", err)
+	}
+	return httptest.NewServer(api.NewRouter(api.WithAuditLog(auditLog))), auditLog
+}
+
+func TestAuditLog_RecordsDecisions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	server, auditLog := newAuditedServer(t, path, 0)
+
+	accepted := postAuditedConsent(t, server.URL, "REQ-AUDIT-1", models.DetailedConsentResourceData{
+		Type:   "accounts",
+		Status: "AwaitingAuthorisation",
+		RequestPayload: map[string]interface{}{
+			"Data": map[string]interface{}{"Permissions": []interface{}{"ReadAccountsBasic", "ReadBalances"}},
+		},
+	})
+	postAuditedConsent(t, server.URL, "REQ-AUDIT-2", models.DetailedConsentResourceData{Status: "AwaitingAuthorisation"})
+	resp, err := http.Post(server.URL+"/api/services/pre-process-consent-creation", "application/json", strings.NewReader("{"))
+	if err != nil {
+		t.Fatalf("Failed to send request: %v", err)
+	}
+	resp.Body.Close()
+
+	// Close waits for in-flight handlers, so every record is written
+	server.Close()
+	auditLog.Close()
+
+	records := readAuditRecords(t, path)
+	if len(records) != 3 {
+		t.Fatalf("Expected 3 audit records, got %d", len(records))
+	}
+
+	if r := records[0]; r.Decision != audit.DecisionAccepted || r.RequestID != "REQ-AUDIT-1" || r.ConsentType != "accounts" ||
+		r.Endpoint != "pre-process-consent-creation" || strings.Join(r.Purposes, ",") != "ReadAccountsBasic,ReadBalances" ||
+		r.PayloadHash != audit.HashPayload(accepted) || r.PrevHash != audit.GenesisHash {
+		t.Errorf("Unexpected accepted record: %+v", r)
+	}
+	if r := records[1]; r.Decision != audit.DecisionRejected || strings.Join(r.Rules, ",") != "CONSENT_TYPE_MISSING" || r.Reason == "" {
+		t.Errorf("Unexpected rejected record: %+v", r)
+	}
+	if r := records[2]; r.Decision != audit.DecisionError || r.Status != http.StatusBadRequest {
+		t.Errorf("Unexpected error record: %+v", r)
+	}
+
+	if v, err := verifyAuditLog(t, path); err != nil || v.Records != 3 {
+		t.Errorf("Expected a valid chain of 3 records, got %d: %v", v.Records, err)
+	}
+}
+
+func TestAuditLog_VerifyDetectsEditsAndGaps(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	server, auditLog := newAuditedServer(t, path, 0)
+	for _, id := range []string{"REQ-AUDIT-A", "REQ-AUDIT-B", "REQ-AUDIT-C"} {
+		postAuditedConsent(t, server.URL, id, models.DetailedConsentResourceData{Type: "accounts", Status: "AwaitingAuthorisation"})
+	}
+	server.Close()
+	auditLog.Close()
+
+	original, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read audit log: %v", err)
+	}
+	lines := strings.SplitAfter(string(original), "\n")
+
+	tests := []struct {
+		name   string
+		log    string
+		reason string
+	}{
+		{"edited decision", strings.Replace(string(original), `"decision":"ACCEPTED"`, `"decision":"REJECTED"`, 1), "hash mismatch"},
+		{"removed record", lines[0] + lines[2], "expected seq 1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := os.WriteFile(path, []byte(tt.log), 0o600); err != nil {
+				t.Fatalf("Failed to write audit log: %v", err)
+			}
+			_, err := verifyAuditLog(t, path)
+			var chainErr *audit.ChainError
+			if !errors.As(err, &chainErr) || !strings.Contains(chainErr.Reason, tt.reason) {
+				t.Errorf("Expected a chain error containing %q, got %v", tt.reason, err)
+			}
+		})
+	}
+}
+
+func TestAuditLog_ChainContinuesAcrossRotationAndRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+
+	for run := 0; run < 2; run++ {
+		server, auditLog := newAuditedServer(t, path, 600)
+		for i := 0; i < 3; i++ {
+			postAuditedConsent(t, server.URL, "REQ-AUDIT-ROTATE", models.DetailedConsentResourceData{Type: "accounts", Status: "AwaitingAuthorisation"})
+		}
+		server.Close()
+		auditLog.Close()
+	}
+
+	if files := audit.Files(path); len(files) < 2 {
+		t.Fatalf("Expected the audit log to rotate, got %v", files)
+	}
+	v, err := verifyAuditLog(t, path)
+	if err != nil {
+		t.Fatalf("Expected a valid chain, got %v", err)
+	}
+	if v.First != 0 || v.Records != 6 {
+		t.Errorf("Expected 6 records from seq 0, got %d from %d", v.Records, v.First)
+	}
+}
@@ -0,0 +1,141 @@
+package integration
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"consent-service-extensions/internal/auth"
+	"consent-service-extensions/internal/models"
+	"consent-service-extensions/pkg/api"
+)
+
+// newBasicAuthServer starts a server that accepts the given username with either of two rotated passwords
+func newBasicAuthServer(t *testing.T, username string, passwords ...string) *httptest.Server {
+	t.Helper()
+
+	var entries []string
+	for _, password := range passwords {
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+		if err != nil {
+			t.Fatalf("Failed to hash password: %v", err)
+		}
+		entries = append(entries, username+":"+string(hash))
+	}
+
+	basicAuth, err := auth.NewBasicAuthenticator(entries)
+	if err != nil {
+		t.Fatalf("Failed to create basic authenticator: %v", err)
+	}
+
+	return httptest.NewServer(api.NewRouter(api.WithAuthenticators(basicAuth)))
+}
+
+// newConsentCreationRequest builds a minimal consent creation request
+func newConsentCreationRequest(t *testing.T, serverURL string) *http.Request {
+	t.Helper()
+
+	requestBody := models.PreProcessConsentCreationRequest{
+		RequestID: "REQ-AUTH",
+		Data: models.Request{
+			ConsentInitiationData: models.DetailedConsentResourceData{
+				Type:           "accounts",
+				Status:         "AwaitingAuthorisation",
+				RequestPayload: map[string]interface{}{},
+			},
+		},
+	}
+
+	body, _ := json.Marshal(requestBody)
+	req, _ := http.NewRequest(http.MethodPost, serverURL+"/api/services/pre-process-consent-creation", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	return req
+}
+
+// postConsentCreation sends a minimal consent creation request with optional basic credentials
+func postConsentCreation(t *testing.T, serverURL, username, password string) *http.Response {
+	t.Helper()
+
+	req := newConsentCreationRequest(t, serverURL)
+	if username != "" {
+		req.SetBasicAuth(username, password)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Failed to send request: %v", err)
+	}
+	return resp
+}
+
+func TestBasicAuth_RotatedCredentials(t *testing.T) {
+	server := newBasicAuthServer(t, "accelerator", "old-secret", "new-secret")
+	defer server.Close()
+
+	for _, password := range []string{"old-secret", "new-secret"} {
+		resp := postConsentCreation(t, server.URL, "accelerator", password)
+		resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("Expected status 200 with password %q, got %d", password, resp.StatusCode)
+		}
+	}
+}
+
+func TestBasicAuth_Rejected(t *testing.T) {
+	server := newBasicAuthServer(t, "accelerator", "secret")
+	defer server.Close()
+
+	tests := []struct {
+		name     string
+		username string
+		password string
+	}{
+		{"missing credentials", "", ""},
+		{"wrong password", "accelerator", "wrong"},
+		{"unknown user", "intruder", "secret"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := postConsentCreation(t, server.URL, tt.username, tt.password)
+			defer resp.Body.Close()
+
+			if resp.StatusCode != http.StatusUnauthorized {
+				t.Errorf("Expected status 401, got %d", resp.StatusCode)
+			}
+
+			if resp.Header.Get("WWW-Authenticate") == "" {
+				t.Errorf("Expected WWW-Authenticate header")
+			}
+
+			var errorResponse models.ErrorResponse
+			if err := json.NewDecoder(resp.Body).Decode(&errorResponse); err != nil {
+				t.Fatalf("Failed to decode error response: %v", err)
+			}
+
+			if errorResponse.Data.Code != "unauthorized" {
+				t.Errorf("Expected code unauthorized, got %s", errorResponse.Data.Code)
+			}
+		})
+	}
+}
+
+func TestBasicAuth_HealthIsOpen(t *testing.T) {
+	server := newBasicAuthServer(t, "accelerator", "secret")
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/health")
+	if err != nil {
+		t.Fatalf("Failed to send request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", resp.StatusCode)
+	}
+}
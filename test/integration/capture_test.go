@@ -0,0 +1,117 @@
+package integration
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"consent-service-extensions/internal/capture"
+	"consent-service-extensions/internal/models"
+	"consent-service-extensions/internal/redact"
+	"consent-service-extensions/pkg/api"
+)
+
+// newCaptureServer starts a router capturing its traffic to a temporary directory
+func newCaptureServer(t *testing.T) (*httptest.Server, string) {
+	t.Helper()
+
+	redactor, err := redact.New(redact.Policy{Paths: redact.DefaultPaths, Patterns: redact.DefaultPatterns})
+	if err != nil {
+		t.Fatalf("Failed to create redactor: %v", err)
+	}
+	dir := filepath.Join(t.TempDir(), "captures")
+	recorder, err := capture.New(dir, redactor)
+	if err != nil {
+		t.Fatalf("Failed to create recorder: %v", err)
+	}
+	return httptest.NewServer(api.NewRouter(api.WithCapture(recorder))), dir
+}
+
+func TestCapture_WritesRedactedExchangePerRequestID(t *testing.T) {
+	server, dir := newCaptureServer(t)
+
+	postAuditedConsent(t, server.URL, "REQ-CAPTURE-1", models.DetailedConsentResourceData{
+		Type:   "accounts",
+		Status: "AwaitingAuthorisation",
+		RequestPayload: map[string]interface{}{
+			"Data": map[string]interface{}{
+				"Permissions": []interface{}{"ReadAccountsBasic"},
+				"Contact":     "jane.doe@example.com",
+			},
+		},
+	})
+	server.Close()
+
+	path := filepath.Join(dir, "REQ-CAPTURE-1.json")
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Expected a capture file per requestId: %v", err)
+	}
+	if strings.Contains(string(raw), "jane.doe@example.com") {
+		t.Errorf("Expected the capture to be redacted, got %s", raw)
+	}
+
+	x, err := capture.Load(path)
+	if err != nil {
+		t.Fatalf("Failed to load capture: %v", err)
+	}
+	if x.Path != "/api/services/pre-process-consent-creation" || x.Response.Status != 200 {
+		t.Errorf("Unexpected capture: %+v", x)
+	}
+	var resp map[string]interface{}
+	if err := json.Unmarshal(x.Response.Body, &resp); err != nil || resp["status"] != "SUCCESS" {
+		t.Errorf("Expected the captured response body, got %s", x.Response.Body)
+	}
+}
+
+func TestReplay_MatchesAndDiffsResponses(t *testing.T) {
+	server, dir := newCaptureServer(t)
+	postAuditedConsent(t, server.URL, "REQ-CAPTURE-2", models.DetailedConsentResourceData{
+		Type:   "accounts",
+		Status: "AwaitingAuthorisation",
+		RequestPayload: map[string]interface{}{
+			"Data": map[string]interface{}{"Contact": "jane.doe@example.com"},
+		},
+	})
+	postAuditedConsent(t, server.URL, "REQ-CAPTURE-3", models.DetailedConsentResourceData{Status: "AwaitingAuthorisation"})
+	server.Close()
+
+	exchanges, err := capture.LoadDir(dir)
+	if err != nil || len(exchanges) != 2 {
+		t.Fatalf("Expected 2 captures, got %d: %v", len(exchanges), err)
+	}
+
+	target := httptest.NewServer(api.NewRouter())
+	defer target.Close()
+	replayer := &capture.Replayer{Target: target.URL}
+
+	for _, x := range exchanges {
+		result, err := replayer.Replay(context.Background(), x)
+		if err != nil {
+			t.Fatalf("Failed to replay %s: %v", x.RequestID, err)
+		}
+		if len(result.Diffs) != 0 {
+			t.Errorf("Expected %s to replay identically, got %v", x.RequestID, result.Diffs)
+		}
+	}
+
+	// A changed decision shows up as a diff
+	changed := *exchanges[0]
+	changed.Response.Body = []byte(strings.Replace(string(changed.Response.Body), `"SUCCESS"`, `"ERROR"`, 1))
+	result, err := replayer.Replay(context.Background(), &changed)
+	if err != nil {
+		t.Fatalf("Failed to replay: %v", err)
+	}
+	if len(result.Diffs) != 1 || !strings.HasPrefix(result.Diffs[0], `status: expected "ERROR"`) {
+		t.Errorf("Expected a diff of the status field, got %v", result.Diffs)
+	}
+
+	replayer.Ignore = []string{"status"}
+	if result, _ := replayer.Replay(context.Background(), &changed); len(result.Diffs) != 0 {
+		t.Errorf("Expected ignored paths to be skipped, got %v", result.Diffs)
+	}
+}
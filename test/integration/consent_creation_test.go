@@ -3,11 +3,17 @@ package integration
 import (
 	"bytes"
 	"encoding/json"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"consent-service-extensions/internal/models"
+	"consent-service-extensions/internal/ratelimit"
+	"consent-service-extensions/internal/replay"
+	"consent-service-extensions/internal/requestbody"
 	"consent-service-extensions/pkg/api"
 )
 
@@ -90,3 +96,93 @@ func TestPreProcessConsentCreation_InvalidJSON(t *testing.T) {
 		t.Errorf("Expected status ERROR, got %s", errorResponse.Status)
 	}
 }
+
+func TestPreProcessConsentCreation_OversizedBody(t *testing.T) {
+	guard := replay.NewGuard(time.Minute)
+	limiter, err := ratelimit.New(ratelimit.Options{
+		Limits: map[string]ratelimit.Limit{ratelimit.DefaultEndpoint: {Rate: 100, Burst: 100}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create limiter: %v", err)
+	}
+	server := httptest.NewServer(api.NewRouter(api.WithReplayGuard(guard), api.WithRateLimiter(limiter)))
+	defer server.Close()
+
+	post := func(body []byte) *http.Response {
+		t.Helper()
+		resp, err := http.Post(server.URL+"/api/services/pre-process-consent-creation", "application/json", bytes.NewReader(body))
+		if err != nil {
+			t.Fatalf("Failed to send request: %v", err)
+		}
+		return resp
+	}
+
+	// A payload close to the limit reaches the handler whole
+	padding := strings.Repeat("x", requestbody.MaxSize-1024)
+	large := []byte(`{"requestId":"REQ-LARGE","data":{"consentInitiationData":{"type":"accounts","status":"AwaitingAuthorisation","requestPayload":{"Padding":"` +
+		padding + `"}},"requestHeaders":{}}}`)
+	resp := post(large)
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected status 200 for a body under the limit, got %d", resp.StatusCode)
+	}
+
+	// A payload over the limit is rejected rather than passed on truncated
+	resp = post(append(large, bytes.Repeat([]byte(" "), 2048)...))
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected status 413, got %d", resp.StatusCode)
+	}
+	var errorResponse models.ErrorResponse
+	if err := json.NewDecoder(resp.Body).Decode(&errorResponse); err != nil {
+		t.Fatalf("Failed to decode error response: %v", err)
+	}
+	if errorResponse.Status != "ERROR" || errorResponse.Data.Code != "request_too_large" {
+		t.Errorf("Expected a request_too_large error, got %+v", errorResponse)
+	}
+}
+
+func TestPreProcessConsentCreation_MissingType(t *testing.T) {
+	router := api.NewRouter()
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	requestBody := models.PreProcessConsentCreationRequest{
+		RequestID: "REQ-NO-TYPE",
+		Data: models.Request{
+			ConsentInitiationData: models.DetailedConsentResourceData{
+				Status:         "AwaitingAuthorisation",
+				RequestPayload: map[string]interface{}{},
+			},
+		},
+	}
+
+	body, _ := json.Marshal(requestBody)
+	resp, err := http.Post(server.URL+"/api/services/pre-process-consent-creation", "application/json", bytes.NewBuffer(body))
+	if err != nil {
+		t.Fatalf("Failed to send request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", resp.StatusCode)
+	}
+
+	var failedResponse models.FailedResponse
+	if err := json.NewDecoder(resp.Body).Decode(&failedResponse); err != nil {
+		t.Fatalf("Failed to decode failed response: %v", err)
+	}
+
+	if failedResponse.Status != "ERROR" {
+		t.Errorf("Expected status ERROR, got %s", failedResponse.Status)
+	}
+
+	if failedResponse.ErrorCode != http.StatusBadRequest {
+		t.Errorf("Expected errorCode 400, got %d", failedResponse.ErrorCode)
+	}
+
+	if failedResponse.Data["errorDescription"] != "Consent type is missing" {
+		t.Errorf("Unexpected failed response data: %v", failedResponse.Data)
+	}
+}
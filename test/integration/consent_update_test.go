@@ -188,3 +188,45 @@ func TestPreProcessConsentUpdate_NoPermissionsField(t *testing.T) {
 		t.Errorf("Expected nil or empty purposes, got %v", response.Data.ResolvedConsentPurposes)
 	}
 }
+
+func TestPreProcessConsentUpdate_NegativeFrequency(t *testing.T) {
+	router := api.NewRouter()
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	requestBody := models.PreProcessConsentUpdateRequest{
+		RequestID: "UPD-NEG-FREQ",
+		Data: models.UpdateRequest{
+			ConsentInitiationData: models.DetailedConsentResourceData{
+				Type:           "accounts",
+				Status:         "AwaitingAuthorisation",
+				Frequency:      -1,
+				RequestPayload: map[string]interface{}{},
+			},
+		},
+	}
+
+	body, _ := json.Marshal(requestBody)
+	resp, err := http.Post(server.URL+"/api/services/pre-process-consent-update", "application/json", bytes.NewBuffer(body))
+	if err != nil {
+		t.Fatalf("Failed to send request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", resp.StatusCode)
+	}
+
+	var failedResponse models.FailedResponse
+	if err := json.NewDecoder(resp.Body).Decode(&failedResponse); err != nil {
+		t.Fatalf("Failed to decode failed response: %v", err)
+	}
+
+	if failedResponse.Status != "ERROR" {
+		t.Errorf("Expected status ERROR, got %s", failedResponse.Status)
+	}
+
+	if failedResponse.Data["errorDescription"] != "Invalid frequency -1, must not be negative" {
+		t.Errorf("Unexpected failed response data: %v", failedResponse.Data)
+	}
+}
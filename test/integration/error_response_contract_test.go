@@ -0,0 +1,240 @@
+package integration
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+	"gopkg.in/yaml.v3"
+
+	"consent-service-extensions/internal/auth"
+	"consent-service-extensions/internal/config"
+	"consent-service-extensions/pkg/api"
+)
+
+const (
+	contractFile          = "../../docs/swagger/consent-management-service-extensions.yaml"
+	contractInteractionID = "93bac548-d2de-4546-b106-880a5018460d"
+)
+
+// legacyErrorSchema is the flat ErrorResponse of the baseline service, which
+// the legacy format must keep emitting
+const legacyErrorSchema = `
+type: object
+required:
+  - status
+  - errorMessage
+  - errorDescription
+additionalProperties: false
+properties:
+  responseId:
+    type: string
+  status:
+    type: string
+    enum:
+      - ERROR
+  errorMessage:
+    type: string
+  errorDescription:
+    type: string
+`
+
+// contractBody is valid JSON that fails to decode into the creation request,
+// after its forwarded headers are read
+var contractBody = `{"requestId":"Ec1wMjmiG8","data":{"requestHeaders":{"x-fapi-interaction-id":"` +
+	contractInteractionID + `"},"consentInitiationData":"not an object"}}`
+
+// loadContractSchema returns a schema of components.schemas in the OpenAPI contract
+func loadContractSchema(t *testing.T, name string) map[string]interface{} {
+	t.Helper()
+
+	data, err := os.ReadFile(contractFile)
+	if err != nil {
+		t.Fatalf("Failed to read contract: %v", err)
+	}
+	var contract struct {
+		Components struct {
+			Schemas map[string]map[string]interface{} `yaml:"schemas"`
+		} `yaml:"components"`
+	}
+	if err := yaml.Unmarshal(data, &contract); err != nil {
+		t.Fatalf("Failed to parse contract: %v", err)
+	}
+	schema, ok := contract.Components.Schemas[name]
+	if !ok {
+		t.Fatalf("Contract has no schema %s", name)
+	}
+	return schema
+}
+
+// validateSchema checks value against the type, enum, required, properties and
+// additionalProperties keywords of an OpenAPI schema, the subset the contract
+// and the legacy schema use for responses
+func validateSchema(path string, schema map[string]interface{}, value interface{}) []string {
+	var problems []string
+
+	switch schema["type"] {
+	case "object":
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return []string{fmt.Sprintf("%s: expected object, got %T", path, value)}
+		}
+		required, _ := schema["required"].([]interface{})
+		for _, name := range required {
+			if _, ok := object[name.(string)]; !ok {
+				problems = append(problems, fmt.Sprintf("%s.%s: required property missing", path, name))
+			}
+		}
+		properties, _ := schema["properties"].(map[string]interface{})
+		for name, property := range properties {
+			if v, ok := object[name]; ok {
+				problems = append(problems, validateSchema(path+"."+name, property.(map[string]interface{}), v)...)
+			}
+		}
+		if schema["additionalProperties"] == false {
+			for name := range object {
+				if _, ok := properties[name]; !ok {
+					problems = append(problems, fmt.Sprintf("%s.%s: unexpected property", path, name))
+				}
+			}
+		}
+	case "string":
+		if _, ok := value.(string); !ok {
+			problems = append(problems, fmt.Sprintf("%s: expected string, got %T", path, value))
+		}
+	case "integer":
+		if n, ok := value.(float64); !ok || n != float64(int64(n)) {
+			problems = append(problems, fmt.Sprintf("%s: expected integer, got %v", path, value))
+		}
+	}
+
+	if enum, ok := schema["enum"].([]interface{}); ok {
+		allowed := false
+		for _, e := range enum {
+			allowed = allowed || e == value
+		}
+		if !allowed {
+			problems = append(problems, fmt.Sprintf("%s: %v is not one of %v", path, value, enum))
+		}
+	}
+	return problems
+}
+
+// postContractRequest sends body to the consent creation endpoint and decodes the raw response
+func postContractRequest(t *testing.T, router http.Handler, body string, wantStatus int) map[string]interface{} {
+	t.Helper()
+
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	req, _ := http.NewRequest(http.MethodPost, server.URL+"/api/services/pre-process-consent-creation", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Failed to send request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != wantStatus {
+		t.Errorf("Expected status %d, got %d", wantStatus, resp.StatusCode)
+	}
+
+	var decoded map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&decoded); err != nil {
+		t.Fatalf("Failed to decode error response: %v", err)
+	}
+	return decoded
+}
+
+// assertErrorResponse checks a spec error response against the contract and its data object
+func assertErrorResponse(t *testing.T, body map[string]interface{}, code string) {
+	t.Helper()
+
+	if problems := validateSchema("ErrorResponse", loadContractSchema(t, "ErrorResponse"), body); len(problems) > 0 {
+		t.Errorf("Response does not match the contract:\n%s", strings.Join(problems, "\n"))
+	}
+
+	data, _ := body["data"].(map[string]interface{})
+	if data["code"] != code {
+		t.Errorf("Expected code %s, got %v", code, data["code"])
+	}
+	if data["path"] != "/api/services/pre-process-consent-creation" {
+		t.Errorf("Expected the endpoint path, got %v", data["path"])
+	}
+	if data["interactionId"] != contractInteractionID {
+		t.Errorf("Expected the forwarded interaction ID, got %v", data["interactionId"])
+	}
+}
+
+func TestErrorResponseContract_SpecFormat(t *testing.T) {
+	body := postContractRequest(t, api.NewRouter(api.WithConfig(&config.Config{ErrorResponseFormat: config.ErrorFormatSpec})), contractBody, http.StatusBadRequest)
+	assertErrorResponse(t, body, "invalid_request")
+
+	for _, field := range []string{"errorMessage", "errorDescription"} {
+		if _, ok := body[field]; ok {
+			t.Errorf("Unexpected legacy field %s in spec response", field)
+		}
+	}
+}
+
+func TestErrorResponseContract_MiddlewareRejection(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("Failed to hash password: %v", err)
+	}
+	users, err := auth.NewBasicAuthenticator([]string{"tpp:" + string(hash)})
+	if err != nil {
+		t.Fatalf("Failed to create authenticator: %v", err)
+	}
+	router := api.NewRouter(
+		api.WithConfig(&config.Config{ErrorResponseFormat: config.ErrorFormatSpec}),
+		api.WithAuthenticators(users),
+	)
+
+	assertErrorResponse(t, postContractRequest(t, router, contractBody, http.StatusUnauthorized), "unauthorized")
+}
+
+func TestErrorResponseContract_LegacyFormat(t *testing.T) {
+	var schema map[string]interface{}
+	if err := yaml.Unmarshal([]byte(legacyErrorSchema), &schema); err != nil {
+		t.Fatalf("Failed to parse legacy schema: %v", err)
+	}
+
+	body := postContractRequest(t, api.NewRouter(api.WithConfig(&config.Config{ErrorResponseFormat: config.ErrorFormatLegacy})), "invalid json", http.StatusBadRequest)
+
+	for _, problem := range validateSchema("response", schema, body) {
+		t.Errorf("Legacy response does not match its schema: %s", problem)
+	}
+
+	if body["errorMessage"] != "invalid_request" {
+		t.Errorf("Expected errorMessage invalid_request, got %v", body["errorMessage"])
+	}
+
+	if body["errorDescription"] != "Invalid request body" {
+		t.Errorf("Expected errorDescription 'Invalid request body', got %v", body["errorDescription"])
+	}
+
+	// Middleware rejections use the same legacy shape
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("Failed to hash password: %v", err)
+	}
+	users, err := auth.NewBasicAuthenticator([]string{"tpp:" + string(hash)})
+	if err != nil {
+		t.Fatalf("Failed to create authenticator: %v", err)
+	}
+	router := api.NewRouter(
+		api.WithConfig(&config.Config{ErrorResponseFormat: config.ErrorFormatLegacy}),
+		api.WithAuthenticators(users),
+	)
+	for _, problem := range validateSchema("response", schema, postContractRequest(t, router, contractBody, http.StatusUnauthorized)) {
+		t.Errorf("Legacy middleware rejection does not match its schema: %s", problem)
+	}
+}
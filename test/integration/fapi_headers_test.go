@@ -0,0 +1,152 @@
+package integration
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"consent-service-extensions/internal/fapi"
+	"consent-service-extensions/internal/models"
+	"consent-service-extensions/pkg/api"
+)
+
+// postConsentWithHeaders sends a consent creation request with the given forwarded headers
+func postConsentWithHeaders(t *testing.T, serverURL, consentType string, headers map[string]interface{}) map[string]interface{} {
+	t.Helper()
+
+	requestBody := models.PreProcessConsentCreationRequest{
+		RequestID: "REQ-FAPI",
+		Data: models.Request{
+			ConsentInitiationData: models.DetailedConsentResourceData{
+				Type:           consentType,
+				Status:         "AwaitingAuthorisation",
+				RequestPayload: map[string]interface{}{},
+			},
+			RequestHeaders: headers,
+		},
+	}
+
+	body, _ := json.Marshal(requestBody)
+	resp, err := http.Post(serverURL+"/api/services/pre-process-consent-creation", "application/json", bytes.NewBuffer(body))
+	if err != nil {
+		t.Fatalf("Failed to send request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var result map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	return result
+}
+
+func TestFAPIHeaders_Formats(t *testing.T) {
+	server := httptest.NewServer(api.NewRouter())
+	defer server.Close()
+
+	tests := []struct {
+		name    string
+		headers map[string]interface{}
+		code    string
+	}{
+		{"valid headers in mixed case", map[string]interface{}{
+			"X-FAPI-Interaction-ID":      "93bac548-d2de-4546-b106-880a5018460d",
+			"X-Fapi-Auth-Date":           "Sun, 10 Sep 2017 19:43:31 GMT",
+			"x-fapi-customer-ip-address": "2001:db8::1",
+		}, ""},
+		{"interaction id not a UUID", map[string]interface{}{"x-fapi-interaction-id": "interaction-1"}, "UK.OBIE.Header.Invalid"},
+		{"auth date not an HTTP date", map[string]interface{}{"X-FAPI-AUTH-DATE": "2017-09-10T19:43:31Z"}, "UK.OBIE.Header.Invalid"},
+		{"customer IP malformed", map[string]interface{}{"x-fapi-customer-ip-address": "300.1.1.1"}, "UK.OBIE.Header.Invalid"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := postConsentWithHeaders(t, server.URL, "accounts", tt.headers)
+			if tt.code == "" {
+				if result["status"] != "SUCCESS" {
+					t.Errorf("Expected SUCCESS, got %v", result)
+				}
+				return
+			}
+			if code := obieErrorCode(result); code != tt.code {
+				t.Errorf("Expected %s, got %v", tt.code, result)
+			}
+		})
+	}
+}
+
+func TestFAPIHeaders_OBIEProfileMandatoryHeaders(t *testing.T) {
+	validator, err := fapi.NewValidator("obie")
+	if err != nil {
+		t.Fatalf("Failed to create validator: %v", err)
+	}
+	server := httptest.NewServer(api.NewRouter(api.WithHeaderValidator(validator)))
+	defer server.Close()
+
+	interactionID := map[string]interface{}{"x-fapi-interaction-id": "93bac548-d2de-4546-b106-880a5018460d"}
+
+	if result := postConsentWithHeaders(t, server.URL, "accounts", map[string]interface{}{}); obieErrorCode(result) != "UK.OBIE.Header.Missing" {
+		t.Errorf("Expected UK.OBIE.Header.Missing without interaction id, got %v", result)
+	}
+
+	if result := postConsentWithHeaders(t, server.URL, "accounts", interactionID); result["status"] != "SUCCESS" {
+		t.Errorf("Expected SUCCESS for accounts, got %v", result)
+	}
+
+	if result := postConsentWithHeaders(t, server.URL, "payments", interactionID); obieErrorCode(result) != "UK.OBIE.Header.Missing" {
+		t.Errorf("Expected UK.OBIE.Header.Missing without idempotency key, got %v", result)
+	}
+}
+
+func TestEnrichConsentCreationResponse_EchoesInteractionID(t *testing.T) {
+	server := httptest.NewServer(api.NewRouter())
+	defer server.Close()
+
+	uuidPattern := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+	tests := []struct {
+		name     string
+		endpoint string
+		headers  map[string]interface{}
+		expected string
+	}{
+		{"creation echoes interaction id", "/api/services/enrich-consent-creation-response", map[string]interface{}{"X-Fapi-Interaction-Id": "93bac548-d2de-4546-b106-880a5018460d"}, "93bac548-d2de-4546-b106-880a5018460d"},
+		{"update echoes interaction id", "/api/services/enrich-consent-update-response", map[string]interface{}{"x-fapi-interaction-id": "6d9b3a1e-0f4c-4b8a-a2e5-7c1d9e3f5b20"}, "6d9b3a1e-0f4c-4b8a-a2e5-7c1d9e3f5b20"},
+		{"creation generates interaction id", "/api/services/enrich-consent-creation-response", map[string]interface{}{}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requestBody := models.EnrichConsentCreationRequest{
+				RequestID: "REQ-ENRICH",
+				Data: models.RequestForEnrichResponse{
+					ConsentResource: models.StoredDetailedConsentResourceData{ID: "consent-1", Type: "accounts", Status: "AwaitingAuthorisation"},
+					RequestHeaders:  tt.headers,
+				},
+			}
+
+			body, _ := json.Marshal(requestBody)
+			resp, err := http.Post(server.URL+tt.endpoint, "application/json", bytes.NewBuffer(body))
+			if err != nil {
+				t.Fatalf("Failed to send request: %v", err)
+			}
+			defer resp.Body.Close()
+
+			var response models.SuccessResponseForResponseAlternation
+			if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+
+			got := response.Data.ResponseHeaders["x-fapi-interaction-id"]
+			if tt.expected != "" && got != tt.expected {
+				t.Errorf("Expected interaction id %s, got %s", tt.expected, got)
+			}
+			if tt.expected == "" && !uuidPattern.MatchString(got) {
+				t.Errorf("Expected a generated UUID, got %q", got)
+			}
+		})
+	}
+}
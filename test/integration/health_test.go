@@ -1,11 +1,16 @@
 package integration
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
+	"consent-service-extensions/internal/apperrors"
+	"consent-service-extensions/internal/health"
 	"consent-service-extensions/pkg/api"
 )
 
@@ -38,3 +43,134 @@ func TestHealthEndpoint(t *testing.T) {
 		t.Errorf("Expected status 'healthy', got %v", response)
 	}
 }
+
+// getReadiness fetches /health/ready and decodes its report
+func getReadiness(t *testing.T, serverURL string) (int, health.Report) {
+	t.Helper()
+
+	resp, err := http.Get(serverURL + "/health/ready")
+	if err != nil {
+		t.Fatalf("Failed to send request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var report health.Report
+	if err := json.NewDecoder(resp.Body).Decode(&report); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	return resp.StatusCode, report
+}
+
+func TestLivenessEndpoint(t *testing.T) {
+	server := httptest.NewServer(api.NewRouter())
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/health/live")
+	if err != nil {
+		t.Fatalf("Failed to send request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", resp.StatusCode)
+	}
+}
+
+func TestReadinessEndpoint(t *testing.T) {
+	failing := func(context.Context) error { return errors.New("connection refused") }
+	passing := func(context.Context) error { return nil }
+
+	tests := []struct {
+		name       string
+		register   func(r *health.Registry)
+		drain      bool
+		wantStatus int
+		wantReport string
+	}{
+		{
+			name:       "all checks pass",
+			register:   func(r *health.Registry) { r.Register("rule-catalogue", true, apperrors.Check) },
+			wantStatus: http.StatusOK,
+			wantReport: health.StatusReady,
+		},
+		{
+			name: "optional check fails",
+			register: func(r *health.Registry) {
+				r.Register("rule-catalogue", true, passing)
+				r.Register("quota-store", false, failing)
+			},
+			wantStatus: http.StatusOK,
+			wantReport: health.StatusDegraded,
+		},
+		{
+			name: "critical check fails",
+			register: func(r *health.Registry) {
+				r.Register("rule-catalogue", true, passing)
+				r.Register("enrichment-service", true, failing)
+			},
+			wantStatus: http.StatusServiceUnavailable,
+			wantReport: health.StatusNotReady,
+		},
+		{
+			name: "critical check times out",
+			register: func(r *health.Registry) {
+				r.Register("jwks", true, func(ctx context.Context) error {
+					<-ctx.Done()
+					return ctx.Err()
+				})
+			},
+			wantStatus: http.StatusServiceUnavailable,
+			wantReport: health.StatusNotReady,
+		},
+		{
+			name:       "draining",
+			register:   func(r *health.Registry) { r.Register("rule-catalogue", true, passing) },
+			drain:      true,
+			wantStatus: http.StatusServiceUnavailable,
+			wantReport: health.StatusDraining,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			registry := health.NewRegistry(100 * time.Millisecond)
+			tt.register(registry)
+			state := &health.State{}
+			if tt.drain {
+				state.SetDraining()
+			}
+			server := httptest.NewServer(api.NewRouter(api.WithReadinessChecks(registry), api.WithHealthState(state)))
+			defer server.Close()
+
+			status, report := getReadiness(t, server.URL)
+			if status != tt.wantStatus || report.Status != tt.wantReport {
+				t.Errorf("Expected %d %s, got %d %s", tt.wantStatus, tt.wantReport, status, report.Status)
+			}
+			for name, result := range report.Checks {
+				if result.LatencyMs < 0 || (result.Status == health.ResultFail) != (result.Error != "") {
+					t.Errorf("Unexpected result for %s: %+v", name, result)
+				}
+			}
+		})
+	}
+}
+
+func TestReadinessEndpoint_HTTPCheck(t *testing.T) {
+	downstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer downstream.Close()
+
+	registry := health.NewRegistry(time.Second)
+	registry.Register("enrichment-service", true, health.HTTPCheck(nil, downstream.URL))
+	server := httptest.NewServer(api.NewRouter(api.WithReadinessChecks(registry)))
+	defer server.Close()
+
+	status, report := getReadiness(t, server.URL)
+	if status != http.StatusServiceUnavailable {
+		t.Errorf("Expected status 503, got %d", status)
+	}
+	if result := report.Checks["enrichment-service"]; result.Status != health.ResultFail || !result.Critical || result.Error != "503 Service Unavailable" {
+		t.Errorf("Unexpected enrichment-service result: %+v", result)
+	}
+}
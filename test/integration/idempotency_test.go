@@ -0,0 +1,213 @@
+package integration
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"consent-service-extensions/internal/idempotency"
+	"consent-service-extensions/internal/models"
+	"consent-service-extensions/pkg/api"
+)
+
+// postIdempotentConsent sends a consent creation request carrying an x-idempotency-key
+func postIdempotentConsent(t *testing.T, serverURL, key string, payload map[string]interface{}) map[string]interface{} {
+	t.Helper()
+	return postIdempotentConsentAs(t, serverURL, map[string]interface{}{"clientId": "client-1"}, key, payload)
+}
+
+// postIdempotentConsentAs sends an idempotent consent creation request with the given consent attributes
+func postIdempotentConsentAs(t *testing.T, serverURL string, attributes map[string]interface{}, key string, payload map[string]interface{}) map[string]interface{} {
+	t.Helper()
+
+	requestBody := models.PreProcessConsentCreationRequest{
+		RequestID: "REQ-IDEMPOTENT",
+		Data: models.Request{
+			ConsentInitiationData: models.DetailedConsentResourceData{
+				Type:           "accounts",
+				Status:         "AwaitingAuthorisation",
+				RequestPayload: payload,
+				Attributes:     attributes,
+			},
+			RequestHeaders: map[string]interface{}{"x-idempotency-key": key},
+		},
+	}
+
+	body, _ := json.Marshal(requestBody)
+	resp, err := http.Post(serverURL+"/api/services/pre-process-consent-creation", "application/json", bytes.NewBuffer(body))
+	if err != nil {
+		t.Fatalf("Failed to send request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", resp.StatusCode)
+	}
+
+	var result map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	return result
+}
+
+func TestIdempotency_ReplaysOriginalResponse(t *testing.T) {
+	store := idempotency.NewMemoryStore()
+	server := httptest.NewServer(api.NewRouter(api.WithIdempotencyStore(store, time.Hour)))
+	defer server.Close()
+
+	payload := map[string]interface{}{"Data": map[string]interface{}{"Permissions": []string{"ReadAccountsBasic"}}}
+	first := postIdempotentConsent(t, server.URL, "key-1", payload)
+	if first["status"] != "SUCCESS" {
+		t.Fatalf("Expected SUCCESS, got %v", first)
+	}
+
+	rec, err := store.Get(context.Background(), "client-1", "key-1")
+	if err != nil {
+		t.Fatalf("Expected a stored record, got %v", err)
+	}
+	if rec.ExpiresAt.Sub(rec.CreatedAt) != time.Hour {
+		t.Errorf("Expected a 1h TTL, got %s", rec.ExpiresAt.Sub(rec.CreatedAt))
+	}
+
+	second := postIdempotentConsent(t, server.URL, "key-1", payload)
+	if !reflect.DeepEqual(first, second) {
+		t.Errorf("Expected the original response to be replayed, got %v and %v", first, second)
+	}
+}
+
+func TestIdempotency_RejectsDifferentPayload(t *testing.T) {
+	server := httptest.NewServer(api.NewRouter(api.WithIdempotencyStore(idempotency.NewMemoryStore(), time.Hour)))
+	defer server.Close()
+
+	postIdempotentConsent(t, server.URL, "key-1", map[string]interface{}{"amount": "10.00"})
+	result := postIdempotentConsent(t, server.URL, "key-1", map[string]interface{}{"amount": "99.00"})
+
+	if result["status"] != "ERROR" {
+		t.Fatalf("Expected status ERROR, got %v", result)
+	}
+	data, _ := result["data"].(map[string]interface{})
+	if data["errorDescription"] != "The x-idempotency-key key-1 was already used with a different payload" {
+		t.Errorf("Unexpected failed response data: %v", data)
+	}
+
+	// A different key is independent
+	if result := postIdempotentConsent(t, server.URL, "key-2", map[string]interface{}{"amount": "99.00"}); result["status"] != "SUCCESS" {
+		t.Errorf("Expected SUCCESS for a new key, got %v", result)
+	}
+}
+
+func TestIdempotency_ConcurrentRequestsWithOneKey(t *testing.T) {
+	store := idempotency.NewMemoryStore()
+	server := httptest.NewServer(api.NewRouter(api.WithIdempotencyStore(store, time.Hour)))
+	defer server.Close()
+
+	results := make([]map[string]interface{}, 8)
+	var wg sync.WaitGroup
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i] = postIdempotentConsent(t, server.URL, "key-1", map[string]interface{}{"attempt": i})
+		}(i)
+	}
+	wg.Wait()
+
+	var succeeded []int
+	for i, result := range results {
+		if result["status"] == "SUCCESS" {
+			succeeded = append(succeeded, i)
+		}
+	}
+	if len(succeeded) != 1 {
+		t.Fatalf("Expected exactly one request to be processed, got %d", len(succeeded))
+	}
+
+	// The stored response is the one of the processed payload
+	rec, err := store.Get(context.Background(), "client-1", "key-1")
+	if err != nil {
+		t.Fatalf("Expected a stored record, got %v", err)
+	}
+	want, _ := idempotency.HashPayload(map[string]interface{}{"attempt": succeeded[0]})
+	if rec.Pending() || rec.PayloadHash != want {
+		t.Errorf("Expected the record of attempt %d, got %+v", succeeded[0], rec)
+	}
+
+	// A reservation blocks the key until its response is stored
+	hash, _ := idempotency.HashPayload(map[string]interface{}(nil))
+	pending := idempotency.Record{ClientID: "client-1", Key: "key-2", PayloadHash: hash, ExpiresAt: time.Now().Add(time.Minute)}
+	if existing, err := store.Reserve(context.Background(), pending); existing != nil || err != nil {
+		t.Fatalf("Expected the reservation to succeed, got %v, %v", existing, err)
+	}
+	result := postIdempotentConsent(t, server.URL, "key-2", nil)
+	if data, _ := result["data"].(map[string]interface{}); data["errorDescription"] != "A request with x-idempotency-key key-2 is still being processed" {
+		t.Errorf("Expected the key to be in progress, got %v", result)
+	}
+}
+
+func TestIdempotency_RequiresClientIdentity(t *testing.T) {
+	store := idempotency.NewMemoryStore()
+	server := httptest.NewServer(api.NewRouter(api.WithIdempotencyStore(store, time.Hour)))
+	defer server.Close()
+
+	// Without a client identity a key is not shared between TPPs
+	postIdempotentConsentAs(t, server.URL, nil, "key-1", map[string]interface{}{"amount": "10.00"})
+	result := postIdempotentConsentAs(t, server.URL, nil, "key-1", map[string]interface{}{"amount": "99.00"})
+	if result["status"] != "SUCCESS" {
+		t.Errorf("Expected SUCCESS, got %v", result)
+	}
+	if _, err := store.Get(context.Background(), "", "key-1"); err == nil {
+		t.Error("Expected no record for a request without client identity")
+	}
+}
+
+func TestIdempotency_ExpiredRecordsAreIgnored(t *testing.T) {
+	store := idempotency.NewMemoryStore()
+	server := httptest.NewServer(api.NewRouter(api.WithIdempotencyStore(store, time.Nanosecond)))
+	defer server.Close()
+
+	postIdempotentConsent(t, server.URL, "key-1", map[string]interface{}{"amount": "10.00"})
+	time.Sleep(time.Millisecond)
+
+	result := postIdempotentConsent(t, server.URL, "key-1", map[string]interface{}{"amount": "99.00"})
+	if result["status"] != "SUCCESS" {
+		t.Errorf("Expected an expired key to be reusable, got %v", result)
+	}
+}
+
+func TestIdempotency_FileStoreSurvivesReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "idempotency.json")
+
+	store, err := idempotency.NewFileStore(path)
+	if err != nil {
+		t.Fatalf("Failed to open file store: %v", err)
+	}
+	server := httptest.NewServer(api.NewRouter(api.WithIdempotencyStore(store, time.Hour)))
+	first := postIdempotentConsent(t, server.URL, "key-1", map[string]interface{}{"amount": "10.00"})
+	server.Close()
+	if err := store.Close(); err != nil {
+		t.Fatalf("Failed to close file store: %v", err)
+	}
+
+	reopened, err := idempotency.NewFileStore(path)
+	if err != nil {
+		t.Fatalf("Failed to reopen file store: %v", err)
+	}
+	defer reopened.Close()
+	server = httptest.NewServer(api.NewRouter(api.WithIdempotencyStore(reopened, time.Hour)))
+	defer server.Close()
+
+	if second := postIdempotentConsent(t, server.URL, "key-1", map[string]interface{}{"amount": "10.00"}); !reflect.DeepEqual(first, second) {
+		t.Errorf("Expected the original response after reopening, got %v and %v", first, second)
+	}
+	if result := postIdempotentConsent(t, server.URL, "key-1", map[string]interface{}{"amount": "99.00"}); result["status"] != "ERROR" {
+		t.Errorf("Expected a different payload to be rejected after reopening, got %v", result)
+	}
+}
@@ -0,0 +1,126 @@
+package integration
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"consent-service-extensions/internal/logging"
+	"consent-service-extensions/internal/models"
+	"consent-service-extensions/pkg/api"
+)
+
+// logLines decodes JSON log output into one map per line
+func logLines(t *testing.T, output string) []map[string]interface{} {
+	t.Helper()
+	var lines []map[string]interface{}
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		var line map[string]interface{}
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			t.Fatalf("Log line is not JSON: %q", scanner.Text())
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// postLoggedConsent serves a consent creation request carrying an interaction id.
+// The router is called directly so every log line is written when it returns.
+func postLoggedConsent(t *testing.T, router http.Handler, frequency int32) {
+	t.Helper()
+
+	requestBody := models.PreProcessConsentCreationRequest{
+		RequestID: "REQ-LOG",
+		Data: models.Request{
+			ConsentInitiationData: models.DetailedConsentResourceData{
+				Type:           "accounts",
+				Status:         "AwaitingAuthorisation",
+				Frequency:      frequency,
+				RequestPayload: map[string]interface{}{},
+			},
+			RequestHeaders: map[string]interface{}{
+				"X-FAPI-Interaction-ID": "93bac548-d2de-4546-b106-880a5018460d",
+			},
+		},
+	}
+
+	body, _ := json.Marshal(requestBody)
+	req := httptest.NewRequest(http.MethodPost, "/api/services/pre-process-consent-creation", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+}
+
+func TestLogging_JSONLinesCarryRequestAttributes(t *testing.T) {
+	var out bytes.Buffer
+	logger, err := logging.New(&out, "info", logging.FormatJSON)
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+	router := api.NewRouter(api.WithLogger(logger))
+
+	postLoggedConsent(t, router, 0)
+
+	lines := logLines(t, out.String())
+	if len(lines) < 2 {
+		t.Fatalf("Expected handler and completion log lines, got %v", lines)
+	}
+	for _, line := range lines {
+		if line["requestId"] != "REQ-LOG" ||
+			line["x-fapi-interaction-id"] != "93bac548-d2de-4546-b106-880a5018460d" ||
+			line["consentType"] != "accounts" ||
+			line["endpoint"] != "pre-process-consent-creation" {
+			t.Errorf("Log line is missing request attributes: %v", line)
+		}
+	}
+
+	last := lines[len(lines)-1]
+	if last["msg"] != "Request completed" || last["status"] != float64(http.StatusOK) {
+		t.Errorf("Expected a completion line with the status, got %v", last)
+	}
+}
+
+func TestLogging_LevelFiltering(t *testing.T) {
+	var out bytes.Buffer
+	logger, err := logging.New(&out, "warn", logging.FormatJSON)
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+	router := api.NewRouter(api.WithLogger(logger))
+
+	postLoggedConsent(t, router, 0)
+	if output := out.String(); output != "" {
+		t.Errorf("Expected info lines to be filtered at warn, got %s", output)
+	}
+}
+
+func TestLogging_TextFormat(t *testing.T) {
+	var out bytes.Buffer
+	logger, err := logging.New(&out, "debug", logging.FormatText)
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+	router := api.NewRouter(api.WithLogger(logger))
+
+	postLoggedConsent(t, router, -1)
+
+	output := out.String()
+	for _, want := range []string{"requestId=REQ-LOG", "consentType=accounts", "endpoint=pre-process-consent-creation", "code=INVALID_FREQUENCY"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected %q in text output:\n%s", want, output)
+		}
+	}
+}
+
+func TestLogging_RejectsInvalidSettings(t *testing.T) {
+	if _, err := logging.New(&bytes.Buffer{}, "verbose", logging.FormatJSON); err == nil {
+		t.Error("Expected an unknown level to be rejected")
+	}
+	if _, err := logging.New(&bytes.Buffer{}, "info", "xml"); err == nil {
+		t.Error("Expected an unknown format to be rejected")
+	}
+}
@@ -0,0 +1,122 @@
+package integration
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"consent-service-extensions/internal/metrics"
+	"consent-service-extensions/internal/models"
+	"consent-service-extensions/pkg/api"
+)
+
+// postMetricsConsent sends a consent creation request for the given consent data
+func postMetricsConsent(t *testing.T, serverURL string, data models.DetailedConsentResourceData) {
+	t.Helper()
+
+	body, _ := json.Marshal(models.PreProcessConsentCreationRequest{
+		RequestID: "REQ-METRICS",
+		Data:      models.Request{ConsentInitiationData: data},
+	})
+	resp, err := http.Post(serverURL+"/api/services/pre-process-consent-creation", "application/json", bytes.NewBuffer(body))
+	if err != nil {
+		t.Fatalf("Failed to send request: %v", err)
+	}
+	resp.Body.Close()
+}
+
+// scrapeMetrics fetches the /metrics exposition
+func scrapeMetrics(t *testing.T, serverURL string) string {
+	t.Helper()
+
+	resp, err := http.Get(serverURL + "/metrics")
+	if err != nil {
+		t.Fatalf("Failed to scrape metrics: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", resp.StatusCode)
+	}
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+		t.Errorf("Expected a text/plain content type, got %q", ct)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	return string(body)
+}
+
+func TestMetrics_CountsOutcomesRejectionsPurposesAndDecodeFailures(t *testing.T) {
+	server := httptest.NewServer(api.NewRouter(api.WithMetrics(metrics.New())))
+	defer server.Close()
+
+	// SUCCESS with two resolved purposes
+	postMetricsConsent(t, server.URL, models.DetailedConsentResourceData{
+		Type:   "accounts",
+		Status: "AwaitingAuthorisation",
+		RequestPayload: map[string]interface{}{
+			"Data": map[string]interface{}{"Permissions": []interface{}{"ReadAccountsBasic", "ReadBalances"}},
+		},
+	})
+
+	// Unknown consent types share one series
+	for _, consentType := range []string{"custom-1", "custom-2"} {
+		postMetricsConsent(t, server.URL, models.DetailedConsentResourceData{
+			Type:   consentType,
+			Status: "AwaitingAuthorisation",
+			RequestPayload: map[string]interface{}{
+				"Data": map[string]interface{}{"Permissions": []interface{}{"ReadAccountsBasic"}},
+			},
+		})
+	}
+
+	// FAILED by the missing consent type rule
+	postMetricsConsent(t, server.URL, models.DetailedConsentResourceData{Status: "AwaitingAuthorisation"})
+
+	// ERROR on an undecodable body
+	resp, err := http.Post(server.URL+"/api/services/pre-process-consent-creation", "application/json", strings.NewReader("{"))
+	if err != nil {
+		t.Fatalf("Failed to send request: %v", err)
+	}
+	resp.Body.Close()
+
+	exposition := scrapeMetrics(t, server.URL)
+	route := `route="/api/services/pre-process-consent-creation"`
+	for _, want := range []string{
+		`consent_extensions_requests_total{` + route + `,status="200",outcome="SUCCESS"} 3`,
+		`consent_extensions_requests_total{` + route + `,status="200",outcome="FAILED"} 1`,
+		`consent_extensions_requests_total{` + route + `,status="400",outcome="ERROR"} 1`,
+		`consent_extensions_request_duration_seconds_count{` + route + `} 5`,
+		`consent_extensions_request_duration_seconds_bucket{` + route + `,le="+Inf"} 5`,
+		`consent_extensions_business_rejections_total{code="CONSENT_TYPE_MISSING"} 1`,
+		`consent_extensions_purposes_resolved_total{consent_type="accounts"} 2`,
+		`consent_extensions_purposes_resolved_total{consent_type="other"} 2`,
+		`consent_extensions_decode_failures_total{route="/api/services/pre-process-consent-creation"} 1`,
+		`# TYPE consent_extensions_request_duration_seconds histogram`,
+	} {
+		if !strings.Contains(exposition, want) {
+			t.Errorf("Expected %q in metrics, got:\n%s", want, exposition)
+		}
+	}
+	if strings.Contains(exposition, "custom-1") {
+		t.Errorf("Expected unknown consent types not to become label values, got:\n%s", exposition)
+	}
+}
+
+func TestMetrics_NotServedWhenDisabled(t *testing.T) {
+	server := httptest.NewServer(api.NewRouter())
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/metrics")
+	if err != nil {
+		t.Fatalf("Failed to send request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected status 404, got %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,261 @@
+package integration
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"consent-service-extensions/internal/tlsserver"
+	"consent-service-extensions/pkg/api"
+)
+
+// testCA issues certificates for the mTLS tests
+type testCA struct {
+	cert   *x509.Certificate
+	key    *ecdsa.PrivateKey
+	serial int64
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create CA: %v", err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return &testCA{cert: cert, key: key, serial: 1}
+}
+
+// issue creates a leaf certificate signed by the CA
+func (ca *testCA) issue(t *testing.T, cn string, usage x509.ExtKeyUsage) tls.Certificate {
+	t.Helper()
+	return ca.issueSubject(t, pkix.Name{CommonName: cn, Organization: []string{"Test Bank"}}, usage)
+}
+
+// issueSubject creates a leaf certificate for the given subject signed by the CA
+func (ca *testCA) issueSubject(t *testing.T, subject pkix.Name, usage x509.ExtKeyUsage) tls.Certificate {
+	t.Helper()
+
+	ca.serial++
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(ca.serial),
+		Subject:      subject,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatalf("Failed to issue certificate: %v", err)
+	}
+	leaf, _ := x509.ParseCertificate(der)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}
+}
+
+// writePEM writes a certificate and its key to the given files
+func writePEM(t *testing.T, cert tls.Certificate, certFile, keyFile string) {
+	t.Helper()
+
+	keyDER, _ := x509.MarshalECPrivateKey(cert.PrivateKey.(*ecdsa.PrivateKey))
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Certificate[0]}), 0o600); err != nil {
+		t.Fatalf("Failed to write certificate: %v", err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatalf("Failed to write key: %v", err)
+	}
+}
+
+// mtlsClient returns an HTTP client trusting the CA and presenting the given client certificates
+func mtlsClient(ca *testCA, certs ...tls.Certificate) *http.Client {
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	return &http.Client{Transport: &http.Transport{
+		TLSClientConfig:   &tls.Config{RootCAs: roots, Certificates: certs},
+		DisableKeepAlives: true,
+	}}
+}
+
+func TestMutualTLS_ClientCertificatePinning(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t)
+	certFile, keyFile, caFile := filepath.Join(dir, "server.crt"), filepath.Join(dir, "server.key"), filepath.Join(dir, "ca.crt")
+
+	writePEM(t, ca.issue(t, "localhost", x509.ExtKeyUsageServerAuth), certFile, keyFile)
+	os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.cert.Raw}), 0o600)
+
+	bySubject := ca.issue(t, "accelerator", x509.ExtKeyUsageClientAuth)
+	bySPKI := ca.issue(t, "accelerator-standby", x509.ExtKeyUsageClientAuth)
+	unpinned := ca.issue(t, "someone-else", x509.ExtKeyUsageClientAuth)
+	spkiHash := sha256.Sum256(bySPKI.Leaf.RawSubjectPublicKeyInfo)
+
+	reloader, err := tlsserver.NewReloader(tlsserver.Options{
+		CertFile:         certFile,
+		KeyFile:          keyFile,
+		ClientCAFile:     caFile,
+		ClientAuth:       tlsserver.ClientAuthRequire,
+		PinnedSubjects:   []string{"CN=accelerator, O=Test Bank"},
+		PinnedSPKIHashes: []string{base64.StdEncoding.EncodeToString(spkiHash[:])},
+		ReloadInterval:   20 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create TLS reloader: %v", err)
+	}
+	defer reloader.Close()
+
+	server := httptest.NewUnstartedServer(api.NewRouter())
+	server.TLS = reloader.TLSConfig()
+	server.StartTLS()
+	defer server.Close()
+
+	// Pins without a required certificate would let certificate-less clients through
+	for _, mode := range []string{tlsserver.ClientAuthNone, tlsserver.ClientAuthRequest} {
+		_, err := tlsserver.NewReloader(tlsserver.Options{
+			CertFile:       certFile,
+			KeyFile:        keyFile,
+			ClientCAFile:   caFile,
+			ClientAuth:     mode,
+			PinnedSubjects: []string{"CN=accelerator, O=Test Bank"},
+		})
+		if err == nil {
+			t.Errorf("Expected pins with client auth mode %q to be rejected", mode)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		client  *http.Client
+		allowed bool
+	}{
+		{"pinned by subject", mtlsClient(ca, bySubject), true},
+		{"pinned by SPKI hash", mtlsClient(ca, bySPKI), true},
+		{"not pinned", mtlsClient(ca, unpinned), false},
+		{"no client certificate", mtlsClient(ca), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := tt.client.Get(server.URL + "/health")
+			if tt.allowed {
+				if err != nil {
+					t.Fatalf("Expected handshake to succeed: %v", err)
+				}
+				resp.Body.Close()
+				if resp.StatusCode != http.StatusOK {
+					t.Errorf("Expected status 200, got %d", resp.StatusCode)
+				}
+			} else if err == nil {
+				resp.Body.Close()
+				t.Errorf("Expected handshake to fail")
+			}
+		})
+	}
+
+	t.Run("hot reload", func(t *testing.T) {
+		rotated := ca.issue(t, "localhost", x509.ExtKeyUsageServerAuth)
+		// Ensure the modification time changes on filesystems with coarse timestamps
+		time.Sleep(10 * time.Millisecond)
+		writePEM(t, rotated, certFile, keyFile)
+
+		deadline := time.Now().Add(2 * time.Second)
+		for time.Now().Before(deadline) {
+			resp, err := mtlsClient(ca, bySubject).Get(server.URL + "/health")
+			if err == nil {
+				resp.Body.Close()
+				if resp.TLS.PeerCertificates[0].SerialNumber.Cmp(rotated.Leaf.SerialNumber) == 0 {
+					return
+				}
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+		t.Errorf("Server did not pick up the rotated certificate")
+	})
+}
+
+func TestMutualTLS_SubjectPinsMatchParsedDN(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t)
+	certFile, keyFile, caFile := filepath.Join(dir, "server.crt"), filepath.Join(dir, "server.key"), filepath.Join(dir, "ca.crt")
+
+	writePEM(t, ca.issue(t, "localhost", x509.ExtKeyUsageServerAuth), certFile, keyFile)
+	os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.cert.Raw}), 0o600)
+
+	options := func(subjects ...string) tlsserver.Options {
+		return tlsserver.Options{
+			CertFile:       certFile,
+			KeyFile:        keyFile,
+			ClientCAFile:   caFile,
+			ClientAuth:     tlsserver.ClientAuthRequire,
+			PinnedSubjects: subjects,
+		}
+	}
+
+	if _, err := tlsserver.NewReloader(options("CN accelerator")); err == nil {
+		t.Error("Expected a malformed subject pin to be rejected")
+	}
+
+	// The escaped comma belongs to the organization, attribute types and
+	// values compare case-insensitively and OIDs match their short names
+	reloader, err := tlsserver.NewReloader(options(`cn=Accelerator, 2.5.4.11=Payments, O=Bank\, Ltd`))
+	if err != nil {
+		t.Fatalf("Failed to create TLS reloader: %v", err)
+	}
+	defer reloader.Close()
+
+	server := httptest.NewUnstartedServer(api.NewRouter())
+	server.TLS = reloader.TLSConfig()
+	server.StartTLS()
+	defer server.Close()
+
+	tests := []struct {
+		name    string
+		subject pkix.Name
+		allowed bool
+	}{
+		{"escaped comma", pkix.Name{CommonName: "accelerator", OrganizationalUnit: []string{"Payments"}, Organization: []string{"Bank, Ltd"}}, true},
+		{"comma split across attributes", pkix.Name{CommonName: "accelerator", OrganizationalUnit: []string{"Payments"}, Organization: []string{"Bank", " Ltd"}}, false},
+		{"different organization", pkix.Name{CommonName: "accelerator", OrganizationalUnit: []string{"Payments"}, Organization: []string{"Bank"}}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := mtlsClient(ca, ca.issueSubject(t, tt.subject, x509.ExtKeyUsageClientAuth)).Get(server.URL + "/health")
+			if tt.allowed {
+				if err != nil {
+					t.Fatalf("Expected handshake to succeed: %v", err)
+				}
+				resp.Body.Close()
+			} else if err == nil {
+				resp.Body.Close()
+				t.Errorf("Expected handshake to fail")
+			}
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package integration
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"consent-service-extensions/internal/netacl"
+	"consent-service-extensions/pkg/api"
+)
+
+// newACL creates a network ACL or fails the test
+func newACL(t *testing.T, opts netacl.Options) *netacl.ACL {
+	t.Helper()
+	acl, err := netacl.New(opts)
+	if err != nil {
+		t.Fatalf("Failed to create ACL: %v", err)
+	}
+	return acl
+}
+
+func TestNetworkACL_Allowed(t *testing.T) {
+	acl := newACL(t, netacl.Options{
+		Allow: []string{"10.0.0.0/8", "2001:db8::/32", "192.0.2.10"},
+		Deny:  []string{"10.1.0.0/16"},
+	})
+
+	tests := []struct {
+		ip      string
+		allowed bool
+	}{
+		{"10.2.3.4", true},
+		{"10.1.2.3", false},
+		{"192.0.2.10", true},
+		{"192.0.2.11", false},
+		{"2001:db8::1", true},
+		{"203.0.113.5", false},
+	}
+
+	for _, tt := range tests {
+		if got := acl.Allowed(net.ParseIP(tt.ip)); got != tt.allowed {
+			t.Errorf("Allowed(%s) = %v, expected %v", tt.ip, got, tt.allowed)
+		}
+	}
+
+	denyOnly := newACL(t, netacl.Options{Deny: []string{"203.0.113.0/24"}})
+	if !denyOnly.Allowed(net.ParseIP("198.51.100.1")) || denyOnly.Allowed(net.ParseIP("203.0.113.9")) {
+		t.Error("Expected a denylist alone to allow every other address")
+	}
+}
+
+func TestNetworkACL_SourceIP(t *testing.T) {
+	acl := newACL(t, netacl.Options{TrustedProxies: []string{"10.0.0.0/8"}})
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		forwarded  string
+		expected   string
+	}{
+		{"direct client", "203.0.113.5:4000", "", "203.0.113.5"},
+		{"untrusted peer cannot spoof", "203.0.113.5:4000", "10.9.9.9", "203.0.113.5"},
+		{"trusted proxy", "10.0.0.1:4000", "198.51.100.7", "198.51.100.7"},
+		{"chain of trusted proxies", "10.0.0.1:4000", "198.51.100.7, 10.0.0.2", "198.51.100.7"},
+		{"spoofed leftmost hop ignored", "10.0.0.1:4000", "192.0.2.1, 198.51.100.7", "198.51.100.7"},
+		{"malformed hop", "10.0.0.1:4000", "not-an-ip", "10.0.0.1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/health", nil)
+			req.RemoteAddr = tt.remoteAddr
+			if tt.forwarded != "" {
+				req.Header.Set(netacl.HeaderForwardedFor, tt.forwarded)
+			}
+			if got := acl.SourceIP(req); !got.Equal(net.ParseIP(tt.expected)) {
+				t.Errorf("Expected %s, got %s", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestNetworkACL_RouteGroups(t *testing.T) {
+	router := api.NewRouter(
+		api.WithNetworkACL(netacl.GroupAPI, newACL(t, netacl.Options{Allow: []string{"10.0.0.0/8"}})),
+		api.WithNetworkACL(netacl.GroupOps, newACL(t, netacl.Options{Deny: []string{"198.51.100.0/24"}})),
+	)
+
+	serve := func(method, path, remoteAddr string) int {
+		req := httptest.NewRequest(method, path, nil)
+		req.RemoteAddr = remoteAddr
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	if code := serve(http.MethodPost, "/api/services/pre-process-consent-creation", "203.0.113.5:4000"); code != http.StatusForbidden {
+		t.Errorf("Expected the API to deny an address outside the allowlist, got %d", code)
+	}
+	if code := serve(http.MethodPost, "/api/services/pre-process-consent-creation", "10.0.0.5:4000"); code == http.StatusForbidden {
+		t.Errorf("Expected the API to allow an allowlisted address, got %d", code)
+	}
+	if code := serve(http.MethodGet, "/health", "203.0.113.5:4000"); code != http.StatusOK {
+		t.Errorf("Expected health to follow its own group ACL, got %d", code)
+	}
+	if code := serve(http.MethodGet, "/health", "198.51.100.1:4000"); code != http.StatusForbidden {
+		t.Errorf("Expected health to deny a denylisted address, got %d", code)
+	}
+}
+
+func TestNetworkACL_RejectsInvalidEntries(t *testing.T) {
+	if _, err := netacl.New(netacl.Options{Allow: []string{"10.0.0.0/33"}}); err == nil {
+		t.Error("Expected an invalid CIDR to be rejected")
+	}
+	if _, err := netacl.New(netacl.Options{TrustedProxies: []string{"proxy.local"}}); err == nil {
+		t.Error("Expected a host name to be rejected")
+	}
+}
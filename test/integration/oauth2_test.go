@@ -0,0 +1,275 @@
+package integration
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"consent-service-extensions/internal/auth"
+	"consent-service-extensions/internal/jose"
+	"consent-service-extensions/pkg/api"
+)
+
+const (
+	testIssuer   = "https://km.example.com/oauth2/token"
+	testAudience = "consent-service-extensions"
+)
+
+// jwksStandIn serves a JWK Set for a freshly generated RSA key
+type jwksStandIn struct {
+	server *httptest.Server
+	key    *rsa.PrivateKey
+	kid    string
+	// extra keys are published after the signing key
+	extra []map[string]string
+}
+
+func newJWKSStandIn(t *testing.T) *jwksStandIn {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	s := &jwksStandIn{key: key, kid: "test-key-1"}
+	s.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		keys := []map[string]string{{
+			"kty": "RSA",
+			"kid": s.kid,
+			"use": "sig",
+			"alg": "RS256",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": append(keys, s.extra...)})
+	}))
+	return s
+}
+
+// sign creates an RS256 JWT with the given claims
+func (s *jwksStandIn) sign(t *testing.T, claims map[string]interface{}) string {
+	t.Helper()
+	return s.signWithHeader(t, map[string]interface{}{"typ": "JWT"}, claims)
+}
+
+// signWithHeader creates an RS256 JWT with extra header parameters and the given claims
+func (s *jwksStandIn) signWithHeader(t *testing.T, params, claims map[string]interface{}) string {
+	t.Helper()
+
+	fields := map[string]interface{}{"alg": "RS256", "kid": s.kid}
+	for name, value := range params {
+		fields[name] = value
+	}
+	header, _ := json.Marshal(fields)
+	payload, _ := json.Marshal(claims)
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+
+	digest := sha256.Sum256([]byte(signingInput))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, s.key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatalf("Failed to sign token: %v", err)
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+// validClaims returns claims accepted by the test server
+func validClaims() map[string]interface{} {
+	now := time.Now().Unix()
+	return map[string]interface{}{
+		"iss":       testIssuer,
+		"aud":       []string{testAudience},
+		"sub":       "accelerator",
+		"client_id": "accelerator-client",
+		"scope":     "openid process",
+		"iat":       now,
+		"nbf":       now,
+		"exp":       now + 300,
+	}
+}
+
+// newBearerServer starts a server validating tokens against the JWKS stand-in and an optional introspection endpoint
+func newBearerServer(t *testing.T, jwks *jwksStandIn, introspectionURL string) *httptest.Server {
+	t.Helper()
+
+	keySet, err := auth.NewKeySet(jwks.server.URL, time.Minute)
+	if err != nil {
+		t.Fatalf("Failed to load JWKS: %v", err)
+	}
+	t.Cleanup(keySet.Close)
+
+	var introspector *auth.Introspector
+	if introspectionURL != "" {
+		introspector = &auth.Introspector{Endpoint: introspectionURL, ClientID: "extensions", ClientSecret: "secret"}
+	}
+
+	bearerAuth, err := auth.NewBearerAuthenticator(&auth.JWTValidator{
+		Keys:     keySet,
+		Issuer:   testIssuer,
+		Audience: testAudience,
+	}, introspector, "process")
+	if err != nil {
+		t.Fatalf("Failed to create bearer authenticator: %v", err)
+	}
+
+	return httptest.NewServer(api.NewRouter(api.WithAuthenticators(bearerAuth)))
+}
+
+// postWithBearer sends a minimal consent creation request with the given bearer token
+func postWithBearer(t *testing.T, serverURL, token string) int {
+	t.Helper()
+
+	req := newConsentCreationRequest(t, serverURL)
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Failed to send request: %v", err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+func TestBearerAuth_JWT(t *testing.T) {
+	jwks := newJWKSStandIn(t)
+	defer jwks.server.Close()
+	server := newBearerServer(t, jwks, "")
+	defer server.Close()
+
+	tests := []struct {
+		name     string
+		mutate   func(claims map[string]interface{})
+		expected int
+	}{
+		{"valid token", func(map[string]interface{}) {}, http.StatusOK},
+		{"wrong issuer", func(c map[string]interface{}) { c["iss"] = "https://evil.example.com" }, http.StatusUnauthorized},
+		{"wrong audience", func(c map[string]interface{}) { c["aud"] = "someone-else" }, http.StatusUnauthorized},
+		{"expired", func(c map[string]interface{}) { c["exp"] = time.Now().Add(-time.Hour).Unix() }, http.StatusUnauthorized},
+		{"not yet valid", func(c map[string]interface{}) { c["nbf"] = time.Now().Add(time.Hour).Unix() }, http.StatusUnauthorized},
+		{"missing exp", func(c map[string]interface{}) { delete(c, "exp") }, http.StatusUnauthorized},
+		{"missing process scope", func(c map[string]interface{}) { c["scope"] = "openid accounts" }, http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := validClaims()
+			tt.mutate(claims)
+
+			if status := postWithBearer(t, server.URL, jwks.sign(t, claims)); status != tt.expected {
+				t.Errorf("Expected status %d, got %d", tt.expected, status)
+			}
+		})
+	}
+}
+
+func TestBearerAuth_JWTHeader(t *testing.T) {
+	jwks := newJWKSStandIn(t)
+	defer jwks.server.Close()
+	server := newBearerServer(t, jwks, "")
+	defer server.Close()
+
+	tests := []struct {
+		name     string
+		header   map[string]interface{}
+		expected int
+	}{
+		{"no typ", map[string]interface{}{}, http.StatusOK},
+		{"access token typ", map[string]interface{}{"typ": "at+JWT"}, http.StatusOK},
+		{"other typ", map[string]interface{}{"typ": "JOSE"}, http.StatusUnauthorized},
+		{"unknown crit", map[string]interface{}{"typ": "JWT", "crit": []string{"exp"}, "exp": 1}, http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if status := postWithBearer(t, server.URL, jwks.signWithHeader(t, tt.header, validClaims())); status != tt.expected {
+				t.Errorf("Expected status %d, got %d", tt.expected, status)
+			}
+		})
+	}
+}
+
+func TestBearerAuth_JWKSWithUnsupportedKeys(t *testing.T) {
+	unsupported := []map[string]string{
+		{"kty": "oct", "kid": "shared", "k": "c2VjcmV0"},
+		{"kty": "EC", "kid": "new-curve", "crv": "P-192", "x": "AQ", "y": "AQ"},
+	}
+	jwks := newJWKSStandIn(t)
+	jwks.extra = unsupported
+	defer jwks.server.Close()
+	server := newBearerServer(t, jwks, "")
+	defer server.Close()
+
+	if status := postWithBearer(t, server.URL, jwks.sign(t, validClaims())); status != http.StatusOK {
+		t.Errorf("Expected the supported key to validate tokens, got status %d", status)
+	}
+
+	data, _ := json.Marshal(map[string]interface{}{"keys": unsupported})
+	if _, err := jose.ParseKeySet(data); err == nil {
+		t.Error("Expected a JWK set without a usable signing key to be rejected")
+	}
+}
+
+func TestBearerAuth_TamperedJWT(t *testing.T) {
+	jwks := newJWKSStandIn(t)
+	defer jwks.server.Close()
+	server := newBearerServer(t, jwks, "")
+	defer server.Close()
+
+	other := newJWKSStandIn(t)
+	defer other.server.Close()
+
+	if status := postWithBearer(t, server.URL, other.sign(t, validClaims())); status != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 for token signed by an unknown key, got %d", status)
+	}
+}
+
+func TestBearerAuth_OpaqueTokenIntrospection(t *testing.T) {
+	jwks := newJWKSStandIn(t)
+	defer jwks.server.Close()
+
+	introspection := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "extensions" || pass != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		r.ParseForm()
+
+		w.Header().Set("Content-Type", "application/json")
+		switch r.PostForm.Get("token") {
+		case "opaque-active":
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"active":    true,
+				"scope":     "process",
+				"client_id": "accelerator-client",
+				"exp":       time.Now().Add(time.Minute).Unix(),
+			})
+		case "opaque-no-scope":
+			json.NewEncoder(w).Encode(map[string]interface{}{"active": true, "scope": "accounts"})
+		default:
+			json.NewEncoder(w).Encode(map[string]interface{}{"active": false})
+		}
+	}))
+	defer introspection.Close()
+
+	server := newBearerServer(t, jwks, introspection.URL)
+	defer server.Close()
+
+	if status := postWithBearer(t, server.URL, "opaque-active"); status != http.StatusOK {
+		t.Errorf("Expected status 200 for active token, got %d", status)
+	}
+	if status := postWithBearer(t, server.URL, "opaque-no-scope"); status != http.StatusForbidden {
+		t.Errorf("Expected status 403 for token without scope, got %d", status)
+	}
+	if status := postWithBearer(t, server.URL, "opaque-revoked"); status != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 for inactive token, got %d", status)
+	}
+}
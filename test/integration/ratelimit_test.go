@@ -0,0 +1,314 @@
+package integration
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"consent-service-extensions/internal/counter"
+	"consent-service-extensions/internal/models"
+	"consent-service-extensions/internal/ratelimit"
+	"consent-service-extensions/pkg/api"
+)
+
+// postConsentForTPP sends a consent creation request for a TPP and returns the response
+func postConsentForTPP(t *testing.T, serverURL, thirdPartyID string) *http.Response {
+	t.Helper()
+
+	requestBody := models.PreProcessConsentCreationRequest{
+		RequestID: "REQ-RATE",
+		Data: models.Request{
+			ConsentInitiationData: models.DetailedConsentResourceData{
+				Type:           "accounts",
+				Status:         "AwaitingAuthorisation",
+				RequestPayload: map[string]interface{}{},
+				Attributes:     map[string]interface{}{"thirdPartyId": thirdPartyID},
+			},
+		},
+	}
+
+	body, _ := json.Marshal(requestBody)
+	resp, err := http.Post(serverURL+"/api/services/pre-process-consent-creation", "application/json", bytes.NewBuffer(body))
+	if err != nil {
+		t.Fatalf("Failed to send request: %v", err)
+	}
+	resp.Body.Close()
+	return resp
+}
+
+func TestParseLimit(t *testing.T) {
+	tests := []struct {
+		spec    string
+		rate    float64
+		burst   int
+		wantErr bool
+	}{
+		{"10/s", 10, 10, false},
+		{"60/m:5", 1, 5, false},
+		{"3600/h", 1, 3600, false},
+		{"10", 0, 0, true},
+		{"10/d", 0, 0, true},
+		{"0/s", 0, 0, true},
+		{"10/s:x", 0, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			limit, err := ratelimit.ParseLimit(tt.spec)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Expected an error for %q", tt.spec)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if limit.Rate != tt.rate || limit.Burst != tt.burst {
+				t.Errorf("Expected %v/%d, got %v/%d", tt.rate, tt.burst, limit.Rate, limit.Burst)
+			}
+		})
+	}
+}
+
+func TestRateLimit_ThrottlesPerThirdParty(t *testing.T) {
+	limiter, err := ratelimit.New(ratelimit.Options{
+		Key: ratelimit.KeyThirdPartyID,
+		Limits: map[string]ratelimit.Limit{
+			"pre-process-consent-creation": {Rate: 1.0 / 60, Burst: 2},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create limiter: %v", err)
+	}
+	server := httptest.NewServer(api.NewRouter(api.WithRateLimiter(limiter)))
+	defer server.Close()
+
+	for i := 0; i < 2; i++ {
+		if resp := postConsentForTPP(t, server.URL, "tpp-1"); resp.StatusCode != http.StatusOK {
+			t.Fatalf("Expected request %d to pass, got %d", i+1, resp.StatusCode)
+		}
+	}
+
+	resp := postConsentForTPP(t, server.URL, "tpp-1")
+	if resp.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("Expected status 429, got %d", resp.StatusCode)
+	}
+	retryAfter, err := strconv.Atoi(resp.Header.Get("Retry-After"))
+	if err != nil || retryAfter < 1 || retryAfter > 60 {
+		t.Errorf("Expected Retry-After within 60 seconds, got %q", resp.Header.Get("Retry-After"))
+	}
+
+	// Another TPP has its own bucket
+	if resp := postConsentForTPP(t, server.URL, "tpp-2"); resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected another TPP to pass, got %d", resp.StatusCode)
+	}
+}
+
+func TestRateLimit_EndpointsWithoutLimitPass(t *testing.T) {
+	limiter, err := ratelimit.New(ratelimit.Options{
+		Limits: map[string]ratelimit.Limit{
+			"pre-process-consent-update": {Rate: 1.0 / 60, Burst: 1},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create limiter: %v", err)
+	}
+	server := httptest.NewServer(api.NewRouter(api.WithRateLimiter(limiter)))
+	defer server.Close()
+
+	for i := 0; i < 3; i++ {
+		if resp := postConsentForTPP(t, server.URL, "tpp-1"); resp.StatusCode != http.StatusOK {
+			t.Fatalf("Expected unlimited endpoint to pass, got %d", resp.StatusCode)
+		}
+	}
+}
+
+func TestRateLimit_DailyQuotaSurvivesRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "quota.json")
+
+	newServer := func(store counter.Store) *httptest.Server {
+		limiter, err := ratelimit.New(ratelimit.Options{
+			DailyCreationQuota: 2,
+			QuotaStore:         store,
+		})
+		if err != nil {
+			t.Fatalf("Failed to create limiter: %v", err)
+		}
+		return httptest.NewServer(api.NewRouter(api.WithRateLimiter(limiter)))
+	}
+
+	store, err := counter.NewFileStore(path)
+	if err != nil {
+		t.Fatalf("Failed to open quota store: %v", err)
+	}
+	server := newServer(store)
+	for i := 0; i < 2; i++ {
+		if resp := postConsentForTPP(t, server.URL, "tpp-1"); resp.StatusCode != http.StatusOK {
+			t.Fatalf("Expected creation %d to pass, got %d", i+1, resp.StatusCode)
+		}
+	}
+	server.Close()
+	store.Close()
+
+	reopened, err := counter.NewFileStore(path)
+	if err != nil {
+		t.Fatalf("Failed to reopen quota store: %v", err)
+	}
+	defer reopened.Close()
+	server = newServer(reopened)
+	defer server.Close()
+
+	resp := postConsentForTPP(t, server.URL, "tpp-1")
+	if resp.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("Expected the quota to be exhausted after restart, got %d", resp.StatusCode)
+	}
+	untilMidnight := time.Until(counter.NextDay(time.Now(), time.UTC))
+	if retryAfter, _ := strconv.Atoi(resp.Header.Get("Retry-After")); retryAfter < 1 || time.Duration(retryAfter)*time.Second > untilMidnight+time.Second {
+		t.Errorf("Expected Retry-After until midnight UTC, got %q", resp.Header.Get("Retry-After"))
+	}
+
+	if resp := postConsentForTPP(t, server.URL, "tpp-2"); resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected another TPP to have its own quota, got %d", resp.StatusCode)
+	}
+}
+
+func TestRateLimit_DailyQuotaCountsOnlyCreatedConsents(t *testing.T) {
+	limiter, err := ratelimit.New(ratelimit.Options{
+		DailyCreationQuota: 1,
+		QuotaStore:         counter.NewMemoryStore(),
+	})
+	if err != nil {
+		t.Fatalf("Failed to create limiter: %v", err)
+	}
+	server := httptest.NewServer(api.NewRouter(api.WithRateLimiter(limiter)))
+	defer server.Close()
+
+	// A consent without a type is rejected with a FailedResponse
+	rejected, _ := json.Marshal(models.PreProcessConsentCreationRequest{
+		RequestID: "REQ-RATE",
+		Data: models.Request{
+			ConsentInitiationData: models.DetailedConsentResourceData{
+				Status:     "AwaitingAuthorisation",
+				Attributes: map[string]interface{}{"thirdPartyId": "tpp-1"},
+			},
+		},
+	})
+	for i := 0; i < 3; i++ {
+		resp, err := http.Post(server.URL+"/api/services/pre-process-consent-creation", "application/json", bytes.NewBuffer(rejected))
+		if err != nil {
+			t.Fatalf("Failed to send request: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("Expected a FailedResponse, got status %d", resp.StatusCode)
+		}
+	}
+
+	if resp := postConsentForTPP(t, server.URL, "tpp-1"); resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected rejected requests not to use up the quota, got %d", resp.StatusCode)
+	}
+	if resp := postConsentForTPP(t, server.URL, "tpp-1"); resp.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("Expected the created consent to use up the quota, got %d", resp.StatusCode)
+	}
+}
+
+// slowStore widens the window between reading and updating a counter, so
+// concurrent requests overlap
+type slowStore struct {
+	counter.Store
+}
+
+func (s slowStore) Increment(ctx context.Context, key, day string, limit int64) (int64, error) {
+	time.Sleep(20 * time.Millisecond)
+	return s.Store.Increment(ctx, key, day, limit)
+}
+
+func (s slowStore) Count(ctx context.Context, key, day string) (int64, error) {
+	time.Sleep(20 * time.Millisecond)
+	return s.Store.Count(ctx, key, day)
+}
+
+func TestRateLimit_DailyQuotaHoldsUnderConcurrentCreations(t *testing.T) {
+	const quota, requests = 3, 20
+
+	store := counter.NewMemoryStore()
+	limiter, err := ratelimit.New(ratelimit.Options{
+		DailyCreationQuota: quota,
+		QuotaStore:         slowStore{store},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create limiter: %v", err)
+	}
+	server := httptest.NewServer(api.NewRouter(api.WithRateLimiter(limiter)))
+	defer server.Close()
+
+	body, _ := json.Marshal(models.PreProcessConsentCreationRequest{
+		RequestID: "REQ-RATE",
+		Data: models.Request{
+			ConsentInitiationData: models.DetailedConsentResourceData{
+				Type:           "accounts",
+				Status:         "AwaitingAuthorisation",
+				RequestPayload: map[string]interface{}{},
+				Attributes:     map[string]interface{}{"thirdPartyId": "tpp-1"},
+			},
+		},
+	})
+
+	statuses := make(chan int, requests)
+	var wg sync.WaitGroup
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := http.Post(server.URL+"/api/services/pre-process-consent-creation", "application/json", bytes.NewReader(body))
+			if err != nil {
+				t.Errorf("Failed to send request: %v", err)
+				return
+			}
+			resp.Body.Close()
+			statuses <- resp.StatusCode
+		}()
+	}
+	wg.Wait()
+	close(statuses)
+
+	created, limited := 0, 0
+	for status := range statuses {
+		switch status {
+		case http.StatusOK:
+			created++
+		case http.StatusTooManyRequests:
+			limited++
+		default:
+			t.Errorf("Expected status 200 or 429, got %d", status)
+		}
+	}
+	if created != quota || limited != requests-quota {
+		t.Errorf("Expected %d creations and %d rejections, got %d and %d", quota, requests-quota, created, limited)
+	}
+
+	count, _ := store.Count(context.Background(), "tpp-1", counter.Day(time.Now(), time.UTC))
+	if count != quota {
+		t.Errorf("Expected the quota counter at %d, got %d", quota, count)
+	}
+}
+
+func TestRateLimit_RejectsInvalidOptions(t *testing.T) {
+	if _, err := ratelimit.New(ratelimit.Options{Key: "ip"}); err == nil {
+		t.Error("Expected an unknown key dimension to be rejected")
+	}
+	if _, err := ratelimit.New(ratelimit.Options{DailyCreationQuota: 1}); err == nil {
+		t.Error("Expected a quota without a store to be rejected")
+	}
+	if _, err := ratelimit.ParseLimits([]string{"pre-process-consent-creation"}); err == nil {
+		t.Error("Expected an entry without a limit to be rejected")
+	}
+}
@@ -0,0 +1,180 @@
+package integration
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"consent-service-extensions/internal/logging"
+	"consent-service-extensions/internal/redact"
+	"consent-service-extensions/pkg/api"
+)
+
+// newRedactor creates a redactor or fails the test
+func newRedactor(t *testing.T, p redact.Policy) *redact.Redactor {
+	t.Helper()
+	r, err := redact.New(p)
+	if err != nil {
+		t.Fatalf("Failed to create redactor: %v", err)
+	}
+	return r
+}
+
+func TestRedact_Patterns(t *testing.T) {
+	r := newRedactor(t, redact.Policy{Patterns: redact.DefaultPatterns})
+
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"email", "user001@example.com signed in", "[REDACTED] signed in"},
+		{"phone", "call +44-7700-900000 now", "call [REDACTED] now"},
+		{"iban", "IBAN GB82 WEST 1234 5698 7654 32", "IBAN [REDACTED]"},
+		{"card number", "card 4111 1111 1111 1111", "card [REDACTED]"},
+		{"digits failing Luhn are kept", "order 1234567890123", "order 1234567890123"},
+		{"timestamps are kept", "2025-10-23T10:30:00Z", "2025-10-23T10:30:00Z"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := r.String(tt.input); got != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestRedact_JSONPaths(t *testing.T) {
+	r := newRedactor(t, redact.Policy{
+		Paths:    []string{"**.ipAddress", "data.consentInitiationData.authorizations.*.resource"},
+		Patterns: []string{"email"},
+	})
+
+	body, err := os.ReadFile("../../docs/examples/full-params-consent.json")
+	if err != nil {
+		t.Fatalf("Failed to read example: %v", err)
+	}
+	redacted := string(r.JSON(body))
+
+	if !strings.Contains(redacted, `"ipAddress":"[REDACTED]"`) {
+		t.Errorf("Expected the ipAddress attribute to be redacted from %s", redacted)
+	}
+	for _, leaked := range []string{"user001@example.com", "+44-7700-900000"} {
+		if strings.Contains(redacted, leaked) {
+			t.Errorf("Expected %q to be redacted from %s", leaked, redacted)
+		}
+	}
+	for _, kept := range []string{"ReadAccountsBasic", "TPP-001", "device-12345"} {
+		if !strings.Contains(redacted, kept) {
+			t.Errorf("Expected %q to be kept in %s", kept, redacted)
+		}
+	}
+}
+
+func TestRedact_HashIsKeyedAndStable(t *testing.T) {
+	a := newRedactor(t, redact.Policy{Patterns: []string{"email"}, Action: redact.ActionHash, HashKey: "k1"})
+	b := newRedactor(t, redact.Policy{Patterns: []string{"email"}, Action: redact.ActionHash, HashKey: "k2"})
+
+	first := a.String("user@example.com")
+	if !strings.HasPrefix(first, "sha256:") || strings.Contains(first, "example.com") {
+		t.Fatalf("Expected a hashed value, got %q", first)
+	}
+	if a.String("user@example.com") != first {
+		t.Error("Expected hashing to be stable so values stay correlatable")
+	}
+	if b.String("user@example.com") == first {
+		t.Error("Expected different hash keys to produce different hashes")
+	}
+}
+
+func TestRedact_HashWithoutKeyUsesProcessKey(t *testing.T) {
+	a := newRedactor(t, redact.Policy{Patterns: []string{"email"}, Action: redact.ActionHash})
+	b := newRedactor(t, redact.Policy{Patterns: []string{"email"}, Action: redact.ActionHash})
+
+	first := a.String("user@example.com")
+	if b.String("user@example.com") != first {
+		t.Error("Expected redactors without a key to share the process key")
+	}
+
+	// An empty HMAC key would let low-entropy values be brute forced
+	mac := hmac.New(sha256.New, nil)
+	mac.Write([]byte("user@example.com"))
+	if first == "sha256:"+hex.EncodeToString(mac.Sum(nil))[:16] {
+		t.Error("Expected the hash not to be keyed with an empty key")
+	}
+}
+
+func TestRedact_CustomPatternAndInvalidPolicies(t *testing.T) {
+	r := newRedactor(t, redact.Policy{Patterns: []string{`session=session-[0-9]+`}})
+	if got := r.String("id session-67890"); got != "id [REDACTED]" {
+		t.Errorf("Expected the custom pattern to apply, got %q", got)
+	}
+
+	if _, err := redact.New(redact.Policy{Patterns: []string{"passport"}}); err == nil {
+		t.Error("Expected an unknown builtin pattern to be rejected")
+	}
+	if _, err := redact.New(redact.Policy{Patterns: []string{"bad=("}}); err == nil {
+		t.Error("Expected an invalid regex to be rejected")
+	}
+	if _, err := redact.New(redact.Policy{Action: "drop"}); err == nil {
+		t.Error("Expected an unknown action to be rejected")
+	}
+}
+
+func TestRedact_PoliciesPerSink(t *testing.T) {
+	sinks := redact.Sinks{
+		redact.SinkLog:   newRedactor(t, redact.Policy{Patterns: []string{"email"}, Action: redact.ActionMask}),
+		redact.SinkAudit: newRedactor(t, redact.Policy{Patterns: []string{"email"}, Action: redact.ActionHash}),
+	}
+
+	if got := sinks.For(redact.SinkLog).String("a@b.io"); got != redact.Masked {
+		t.Errorf("Expected the log sink to mask, got %q", got)
+	}
+	if got := sinks.For(redact.SinkAudit).String("a@b.io"); !strings.HasPrefix(got, "sha256:") {
+		t.Errorf("Expected the audit sink to hash, got %q", got)
+	}
+	if got := sinks.For(redact.SinkCapture).String("a@b.io"); got != "a@b.io" {
+		t.Errorf("Expected a sink without a policy to leave values unchanged, got %q", got)
+	}
+}
+
+func TestRedact_LoggedPayloads(t *testing.T) {
+	var out bytes.Buffer
+	base, err := logging.New(&out, "debug", logging.FormatJSON)
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+	logger := slog.New(redact.NewHandler(base.Handler(), newRedactor(t, redact.Policy{
+		Paths:    redact.DefaultPaths,
+		Patterns: redact.DefaultPatterns,
+	})))
+	router := api.NewRouter(api.WithLogger(logger))
+
+	body, err := os.ReadFile("../../docs/examples/full-params-consent.json")
+	if err != nil {
+		t.Fatalf("Failed to read example: %v", err)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/api/services/pre-process-consent-creation", bytes.NewReader(body))
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	output := out.String()
+	if strings.Contains(output, "Request payload") {
+		t.Errorf("Expected the payload not to be logged, got %s", output)
+	}
+	for _, leaked := range []string{"192.168.1.100", "user001@example.com", "+44-7700-900000", "device-12345", "Mozilla/5.0", "Bearer"} {
+		if strings.Contains(output, leaked) {
+			t.Errorf("Expected %q to be redacted from the logs", leaked)
+		}
+	}
+	if !strings.Contains(output, "full-params-test-001") {
+		t.Error("Expected the requestId to be kept")
+	}
+}
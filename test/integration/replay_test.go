@@ -0,0 +1,123 @@
+package integration
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"consent-service-extensions/internal/models"
+	"consent-service-extensions/internal/replay"
+	"consent-service-extensions/pkg/api"
+)
+
+// postConsentWithRequestID sends a consent creation request and returns the response status
+func postConsentWithRequestID(t *testing.T, serverURL, requestID string) (int, map[string]interface{}) {
+	t.Helper()
+
+	requestBody := models.PreProcessConsentCreationRequest{
+		RequestID: requestID,
+		Data: models.Request{
+			ConsentInitiationData: models.DetailedConsentResourceData{
+				Type:           "accounts",
+				Status:         "AwaitingAuthorisation",
+				RequestPayload: map[string]interface{}{},
+			},
+		},
+	}
+
+	body, _ := json.Marshal(requestBody)
+	resp, err := http.Post(serverURL+"/api/services/pre-process-consent-creation", "application/json", bytes.NewBuffer(body))
+	if err != nil {
+		t.Fatalf("Failed to send request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var result map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	return resp.StatusCode, result
+}
+
+func TestReplayGuard_RejectsDuplicateRequestID(t *testing.T) {
+	guard := replay.NewGuard(time.Minute)
+	server := httptest.NewServer(api.NewRouter(api.WithReplayGuard(guard)))
+	defer server.Close()
+
+	if status, result := postConsentWithRequestID(t, server.URL, "REQ-REPLAY-1"); status != http.StatusOK || result["status"] != "SUCCESS" {
+		t.Fatalf("Expected the first request to succeed, got %d %v", status, result)
+	}
+
+	status, result := postConsentWithRequestID(t, server.URL, "REQ-REPLAY-1")
+	if status != http.StatusConflict {
+		t.Fatalf("Expected status 409, got %d", status)
+	}
+	data, _ := result["data"].(map[string]interface{})
+	if data["code"] != "duplicate_request" {
+		t.Errorf("Expected duplicate_request, got %v", result)
+	}
+	if result["responseId"] != "REQ-REPLAY-1" {
+		t.Errorf("Expected the replayed requestId as responseId, got %v", result["responseId"])
+	}
+
+	if status, _ := postConsentWithRequestID(t, server.URL, "REQ-REPLAY-2"); status != http.StatusOK {
+		t.Errorf("Expected a new requestId to be accepted, got %d", status)
+	}
+
+	if guard.Rejected() != 1 {
+		t.Errorf("Expected 1 rejected replay, got %d", guard.Rejected())
+	}
+
+	resp, err := http.Get(server.URL + "/stats/replay")
+	if err != nil {
+		t.Fatalf("Failed to get replay stats: %v", err)
+	}
+	defer resp.Body.Close()
+	var stats map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&stats); err != nil {
+		t.Fatalf("Failed to decode replay stats: %v", err)
+	}
+	if stats["rejectedReplays"] != float64(1) || stats["trackedRequestIds"] != float64(2) {
+		t.Errorf("Unexpected replay stats: %v", stats)
+	}
+}
+
+func TestReplayGuard_ForgetsRequestIDsAfterWindow(t *testing.T) {
+	guard := replay.NewGuard(50 * time.Millisecond)
+	server := httptest.NewServer(api.NewRouter(api.WithReplayGuard(guard)))
+	defer server.Close()
+
+	postConsentWithRequestID(t, server.URL, "REQ-WINDOW")
+	time.Sleep(100 * time.Millisecond)
+
+	if status, _ := postConsentWithRequestID(t, server.URL, "REQ-WINDOW"); status != http.StatusOK {
+		t.Errorf("Expected the requestId to be accepted after the window, got %d", status)
+	}
+}
+
+func TestReplayGuard_TracksRequestIDsPerEndpoint(t *testing.T) {
+	guard := replay.NewGuard(time.Minute)
+
+	if err := guard.Check("/api/services/pre-process-consent-creation", "REQ-FLOW"); err != nil {
+		t.Fatalf("Expected the first check to pass, got %v", err)
+	}
+	if err := guard.Check("/api/services/enrich-consent-creation-response", "REQ-FLOW"); err != nil {
+		t.Errorf("Expected the same requestId on another endpoint to pass, got %v", err)
+	}
+	if err := guard.Check("/api/services/pre-process-consent-creation", "REQ-FLOW"); err != replay.ErrReplayedRequest {
+		t.Errorf("Expected ErrReplayedRequest, got %v", err)
+	}
+}
+
+func TestReplayGuard_DisabledByDefault(t *testing.T) {
+	server := httptest.NewServer(api.NewRouter())
+	defer server.Close()
+
+	postConsentWithRequestID(t, server.URL, "REQ-NO-GUARD")
+	if status, _ := postConsentWithRequestID(t, server.URL, "REQ-NO-GUARD"); status != http.StatusOK {
+		t.Errorf("Expected duplicates to be accepted without a guard, got %d", status)
+	}
+}
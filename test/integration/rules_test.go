@@ -0,0 +1,194 @@
+package integration
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"consent-service-extensions/internal/models"
+	"consent-service-extensions/internal/rules"
+	"consent-service-extensions/pkg/api"
+)
+
+const rulesV1 = `
+version: "2026.10.1"
+permissions:
+  accounts: [ReadAccountsBasic, ReadBalances]
+purposes:
+  ReadAccountsBasic: [accounts]
+  ReadBalances: [accounts, balances]
+errors:
+  PERMISSION_NOT_ALLOWED:
+    data:
+      errorMessage: invalid_permission
+      errorDescription: "{permission} cannot be requested"
+`
+
+// postRuledConsent posts a consent creation requesting permissions and decodes the response
+func postRuledConsent(t *testing.T, serverURL string, permissions ...interface{}) map[string]interface{} {
+	t.Helper()
+
+	requestBody := models.PreProcessConsentCreationRequest{
+		RequestID: "REQ-RULES",
+		Data: models.Request{
+			ConsentInitiationData: models.DetailedConsentResourceData{
+				Type:   "accounts",
+				Status: "AwaitingAuthorisation",
+				RequestPayload: map[string]interface{}{
+					"Data": map[string]interface{}{"Permissions": permissions},
+				},
+			},
+		},
+	}
+	body, _ := json.Marshal(requestBody)
+	resp, err := http.Post(serverURL+"/api/services/pre-process-consent-creation", "application/json", bytes.NewBuffer(body))
+	if err != nil {
+		t.Fatalf("Failed to send request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var response map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	return response
+}
+
+// getRulesStatus fetches /admin/rules
+func getRulesStatus(t *testing.T, serverURL string) rules.Status {
+	t.Helper()
+
+	resp, err := http.Get(serverURL + "/admin/rules")
+	if err != nil {
+		t.Fatalf("Failed to send request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var status rules.Status
+	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	return status
+}
+
+// writeRules writes a rule file
+func writeRules(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("Failed to write rule file: %v", err)
+	}
+}
+
+func TestRules_AppliesPermissionsPurposesAndErrors(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.yaml")
+	writeRules(t, path, rulesV1)
+	store, err := rules.NewStore(path, 0)
+	if err != nil {
+		t.Fatalf("Failed to load rules: %v", err)
+	}
+	server := httptest.NewServer(api.NewRouter(api.WithRules(store)))
+	defer server.Close()
+
+	response := postRuledConsent(t, server.URL, "ReadAccountsBasic", "ReadBalances")
+	data, _ := response["data"].(map[string]interface{})
+	purposes, _ := json.Marshal(data["resolvedConsentPurposes"])
+	if response["status"] != "SUCCESS" || string(purposes) != `["accounts","balances"]` {
+		t.Errorf("Expected SUCCESS with mapped purposes, got %v", response)
+	}
+
+	response = postRuledConsent(t, server.URL, "ReadAccountsBasic", "ReadTransactionsDetail")
+	data, _ = response["data"].(map[string]interface{})
+	if response["status"] != "ERROR" || data["errorMessage"] != "invalid_permission" ||
+		data["errorDescription"] != "ReadTransactionsDetail cannot be requested" {
+		t.Errorf("Expected the mapped PERMISSION_NOT_ALLOWED error, got %v", response)
+	}
+
+	status := getRulesStatus(t, server.URL)
+	if status.Version != "2026.10.1" || len(status.Hash) != 64 || status.Source != path {
+		t.Errorf("Unexpected rules status: %+v", status)
+	}
+}
+
+func TestRules_ReloadKeepsActiveSetOnInvalidFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.yaml")
+	writeRules(t, path, rulesV1)
+	store, err := rules.NewStore(path, 0)
+	if err != nil {
+		t.Fatalf("Failed to load rules: %v", err)
+	}
+	server := httptest.NewServer(api.NewRouter(api.WithRules(store)))
+	defer server.Close()
+	loaded := getRulesStatus(t, server.URL)
+
+	writeRules(t, path, `
+version: broken
+permissions:
+  accounts: []
+errors:
+  NO_SUCH_CODE:
+    errorCode: 418
+  INVALID_FREQUENCY: {}
+`)
+	err = store.Reload()
+	var validationErr *rules.ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("Expected a ValidationError, got %v", err)
+	}
+	want := []string{
+		"errors.INVALID_FREQUENCY: needs an errorCode or data",
+		"errors.NO_SUCH_CODE: unknown catalogue code",
+		"permissions.accounts: empty permission list",
+	}
+	if !reflect.DeepEqual(validationErr.Problems, want) {
+		t.Errorf("Expected problems %q, got %q", want, validationErr.Problems)
+	}
+
+	status := getRulesStatus(t, server.URL)
+	if status.Version != loaded.Version || status.Hash != loaded.Hash || !strings.Contains(status.LastError, "NO_SUCH_CODE") {
+		t.Errorf("Expected the active rules to be kept with the reload error, got %+v", status)
+	}
+	if response := postRuledConsent(t, server.URL, "ReadBalances"); response["status"] != "SUCCESS" {
+		t.Errorf("Expected the active rules to keep serving, got %v", response)
+	}
+}
+
+func TestRules_WatchReloadsChangedFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.json")
+	writeRules(t, path, `{"version": "1", "permissions": {"accounts": ["ReadAccountsBasic"]}}`)
+	store, err := rules.NewStore(path, 10*time.Millisecond)
+	if err != nil {
+		t.Fatalf("Failed to load rules: %v", err)
+	}
+	defer store.Close()
+	server := httptest.NewServer(api.NewRouter(api.WithRules(store)))
+	defer server.Close()
+
+	if response := postRuledConsent(t, server.URL, "ReadTransactionsBasic"); response["status"] != "ERROR" {
+		t.Fatalf("Expected ReadTransactionsBasic to be rejected, got %v", response)
+	}
+
+	v2, _ := json.Marshal(map[string]interface{}{
+		"version":     "2",
+		"permissions": map[string][]string{"accounts": {"ReadAccountsBasic", "ReadTransactionsBasic"}},
+	})
+	writeRules(t, path, string(v2))
+
+	deadline := time.Now().Add(2 * time.Second)
+	for store.Status().Version != "2" {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected the changed rule file to be reloaded, got %+v", store.Status())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if response := postRuledConsent(t, server.URL, "ReadTransactionsBasic"); response["status"] != "SUCCESS" {
+		t.Errorf("Expected ReadTransactionsBasic to be accepted after reload, got %v", response)
+	}
+}
@@ -0,0 +1,271 @@
+package integration
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"consent-service-extensions/internal/auth"
+	"consent-service-extensions/internal/config"
+	"consent-service-extensions/internal/secrets"
+)
+
+// vaultStandIn serves KV version 2 secrets the way Vault does
+type vaultStandIn struct {
+	token    string
+	mu       sync.Mutex
+	secrets  map[string]map[string]interface{}
+	requests atomic.Int64
+	down     atomic.Bool
+}
+
+func (v *vaultStandIn) set(path string, data map[string]interface{}) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.secrets[path] = data
+}
+
+func (v *vaultStandIn) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	v.requests.Add(1)
+	if v.down.Load() {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	if r.Header.Get("X-Vault-Token") != v.token {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	path, ok := strings.CutPrefix(r.URL.Path, "/v1/secret/data/")
+	if !ok || r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	v.mu.Lock()
+	data, ok := v.secrets[path]
+	v.mu.Unlock()
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]interface{}{"errors": []string{}})
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"data": map[string]interface{}{
+			"data":     data,
+			"metadata": map[string]interface{}{"version": 1},
+		},
+	})
+}
+
+// newVaultStandIn starts a Vault stand-in holding one secret
+func newVaultStandIn(t *testing.T) (*vaultStandIn, *httptest.Server) {
+	t.Helper()
+
+	vault := &vaultStandIn{token: "test-token", secrets: map[string]map[string]interface{}{}}
+	vault.set("consent/oauth", map[string]interface{}{"clientSecret": "v1", "value": "default-field"})
+	server := httptest.NewServer(vault)
+	t.Cleanup(server.Close)
+	return vault, server
+}
+
+func TestSecrets_VaultBackend(t *testing.T) {
+	vault, server := newVaultStandIn(t)
+	provider, err := secrets.New(secrets.Options{
+		Backend: secrets.BackendVault,
+		Vault:   secrets.VaultOptions{Addr: server.URL, Token: "test-token"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+	ctx := context.Background()
+
+	tests := []struct {
+		name    string
+		want    string
+		wantErr error
+	}{
+		{name: "consent/oauth#clientSecret", want: "v1"},
+		{name: "consent/oauth", want: "default-field"},
+		{name: "consent/oauth#missing", wantErr: secrets.ErrNotFound},
+		{name: "consent/unknown", wantErr: secrets.ErrNotFound},
+	}
+	for _, tt := range tests {
+		value, err := provider.Get(ctx, tt.name)
+		if value != tt.want || !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: expected %q, %v, got %q, %v", tt.name, tt.want, tt.wantErr, value, err)
+		}
+	}
+
+	vault.token = "rotated"
+	if _, err := provider.Get(ctx, "consent/oauth"); err == nil || errors.Is(err, secrets.ErrNotFound) {
+		t.Errorf("Expected a rejected token to fail, got %v", err)
+	}
+}
+
+func TestSecrets_CacheRefreshesAfterTTL(t *testing.T) {
+	vault, server := newVaultStandIn(t)
+	provider, err := secrets.New(secrets.Options{
+		Backend:  secrets.BackendVault,
+		Vault:    secrets.VaultOptions{Addr: server.URL, Token: "test-token"},
+		CacheTTL: 50 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+	ctx := context.Background()
+	get := func() string {
+		t.Helper()
+		value, err := provider.Get(ctx, "consent/oauth#clientSecret")
+		if err != nil {
+			t.Fatalf("Failed to get secret: %v", err)
+		}
+		return value
+	}
+
+	if get() != "v1" || get() != "v1" || vault.requests.Load() != 1 {
+		t.Errorf("Expected one backend request for cached reads, got %d", vault.requests.Load())
+	}
+
+	vault.set("consent/oauth", map[string]interface{}{"clientSecret": "v2"})
+	if value := get(); value != "v1" {
+		t.Errorf("Expected the cached value before the TTL, got %q", value)
+	}
+	time.Sleep(60 * time.Millisecond)
+	if value := get(); value != "v2" {
+		t.Errorf("Expected the rotated value after the TTL, got %q", value)
+	}
+
+	vault.down.Store(true)
+	time.Sleep(60 * time.Millisecond)
+	if value := get(); value != "v2" {
+		t.Errorf("Expected the last value while the backend is down, got %q", value)
+	}
+}
+
+func TestSecrets_FileBackend(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "signing-key"), []byte("c2VjcmV0\n"), 0o600); err != nil {
+		t.Fatalf("Failed to write secret: %v", err)
+	}
+	provider := &secrets.FileProvider{Dir: dir}
+	ctx := context.Background()
+
+	if value, err := provider.Get(ctx, "signing-key"); err != nil || value != "c2VjcmV0" {
+		t.Errorf("Expected the secret without its trailing newline, got %q, %v", value, err)
+	}
+	if _, err := provider.Get(ctx, "missing"); !errors.Is(err, secrets.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+	if _, err := provider.Get(ctx, "../"+filepath.Base(dir)+"/signing-key"); err == nil {
+		t.Error("Expected a name outside the directory to be rejected")
+	}
+}
+
+func TestSecrets_ConfigReferences(t *testing.T) {
+	_, server := newVaultStandIn(t)
+	t.Setenv("CONFIG_FILE", "")
+	t.Setenv("SECRETS_BACKEND", secrets.BackendVault)
+	t.Setenv("VAULT_ADDR", server.URL)
+	t.Setenv("VAULT_TOKEN", "test-token")
+	t.Setenv("OAUTH2_INTROSPECTION_CLIENT_SECRET", "secret://consent/oauth#clientSecret")
+	t.Setenv("REDACT_HASH_KEY", "")
+
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if secret, err := cfg.Secrets.Value(cfg.OAuth2.IntrospectionSecret)(context.Background()); secret != "v1" || err != nil {
+		t.Errorf("Expected the secret from vault, got %q, %v", secret, err)
+	}
+
+	t.Setenv("SIGNING_RESPONSE_KEY", "secret://consent/unknown")
+	t.Setenv("REDACT_HASH_KEY", "secret://consent/oauth#missing")
+	_, err = config.Load()
+	if err == nil || !strings.Contains(err.Error(), "REDACT_HASH_KEY: secret not found") ||
+		!strings.Contains(err.Error(), "SIGNING_RESPONSE_KEY: secret not found") {
+		t.Errorf("Expected every unresolved reference to be reported, got %v", err)
+	}
+}
+
+func TestSecrets_EnvBackendFromConfigFile(t *testing.T) {
+	t.Setenv("SECRETS_BACKEND", "")
+	t.Setenv("SIGNING_RESPONSE_KEY", "")
+	t.Setenv("APP_SIGNING_RESPONSE_KEY", "from-env")
+	writeConfigFile(t, "config.yaml", `
+secrets:
+  backend: env
+  envPrefix: APP_
+auth:
+  signing:
+    responseKey: secret://signing-response-key
+`)
+
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if key, err := cfg.Secrets.Value(cfg.Signing.ResponseKey)(context.Background()); key != "from-env" || err != nil {
+		t.Errorf("Expected the secret from APP_SIGNING_RESPONSE_KEY, got %q, %v", key, err)
+	}
+}
+
+func TestSecrets_RotatedCredentialsApplyWithoutRestart(t *testing.T) {
+	hash := func(password string) string {
+		h, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+		if err != nil {
+			t.Fatalf("Failed to hash password: %v", err)
+		}
+		return string(h)
+	}
+	vault, server := newVaultStandIn(t)
+	vault.set("consent/users", map[string]interface{}{"value": "tpp:" + hash("old")})
+	t.Setenv("CONFIG_FILE", "")
+	t.Setenv("REDACT_HASH_KEY", "")
+	t.Setenv("SECRETS_BACKEND", secrets.BackendVault)
+	t.Setenv("SECRETS_CACHE_TTL", "50ms")
+	t.Setenv("VAULT_ADDR", server.URL)
+	t.Setenv("VAULT_TOKEN", "test-token")
+	t.Setenv("BASIC_AUTH_CREDENTIALS", "secret://consent/users")
+
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if !reflect.DeepEqual(cfg.BasicAuthCredentials, []string{"secret://consent/users"}) {
+		t.Errorf("Expected the reference to be kept for resolving on use, got %q", cfg.BasicAuthCredentials)
+	}
+	users, err := auth.NewBasicAuthenticatorSource(context.Background(), cfg.Secrets.List(cfg.BasicAuthCredentials))
+	if err != nil {
+		t.Fatalf("Failed to create authenticator: %v", err)
+	}
+	authenticate := func(password string) error {
+		r := httptest.NewRequest(http.MethodPost, "/", nil)
+		_, err := users.Authenticate(r, base64.StdEncoding.EncodeToString([]byte("tpp:"+password)))
+		return err
+	}
+
+	if err := authenticate("old"); err != nil {
+		t.Fatalf("Expected the initial password to authenticate, got %v", err)
+	}
+	vault.set("consent/users", map[string]interface{}{"value": "tpp:" + hash("new")})
+	time.Sleep(60 * time.Millisecond)
+	if err := authenticate("new"); err != nil {
+		t.Errorf("Expected the rotated password to authenticate, got %v", err)
+	}
+	if err := authenticate("old"); err == nil {
+		t.Error("Expected the replaced password to be rejected")
+	}
+}
@@ -0,0 +1,118 @@
+package integration
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"consent-service-extensions/internal/config"
+	"consent-service-extensions/internal/health"
+	"consent-service-extensions/internal/httpserver"
+	"consent-service-extensions/pkg/api"
+)
+
+// serverConfig is a ServerConfig with short limits for tests
+var serverConfig = config.ServerConfig{
+	ReadTimeout:       time.Second,
+	ReadHeaderTimeout: time.Second,
+	WriteTimeout:      5 * time.Second,
+	IdleTimeout:       time.Second,
+	MaxHeaderBytes:    4 << 10,
+}
+
+func TestShutdown_DrainsInFlightRequestsAndReportsNotReady(t *testing.T) {
+	state := &health.State{}
+	router := api.NewRouter(api.WithHealthState(state))
+
+	started := make(chan struct{})
+	mux := http.NewServeMux()
+	mux.Handle("/", router)
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		time.Sleep(300 * time.Millisecond)
+		io.WriteString(w, "done")
+	})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	baseURL := "http://" + ln.Addr().String()
+	srv := httpserver.New("", mux, serverConfig)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() {
+		served <- httpserver.Serve(ctx, srv, func() error { return srv.Serve(ln) }, state, 200*time.Millisecond, 5*time.Second)
+	}()
+
+	slow := make(chan string, 1)
+	go func() {
+		resp, err := http.Get(baseURL + "/slow")
+		if err != nil {
+			slow <- err.Error()
+			return
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		slow <- string(body)
+	}()
+	<-started
+
+	// SIGTERM arrives while the slow request is in flight
+	cancel()
+	time.Sleep(50 * time.Millisecond)
+
+	// New connections are still accepted during the drain delay, but not ready
+	client := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+	resp, err := client.Get(baseURL + "/health")
+	if err != nil {
+		t.Fatalf("Failed to query health while draining: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("Expected status 503 while draining, got %d", resp.StatusCode)
+	}
+
+	if got := <-slow; got != "done" {
+		t.Errorf("Expected the in-flight request to complete, got %q", got)
+	}
+	select {
+	case err := <-served:
+		if err != nil {
+			t.Errorf("Expected a clean shutdown, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Server did not shut down")
+	}
+
+	if _, err := client.Get(baseURL + "/health"); err == nil {
+		t.Error("Expected connections to be refused after shutdown")
+	}
+}
+
+func TestServer_RejectsOversizedHeaders(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	srv := httpserver.New("", api.NewRouter(), serverConfig)
+	go srv.Serve(ln)
+	defer srv.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, "http://"+ln.Addr().String()+"/health", nil)
+	req.Header.Set("X-Padding", strings.Repeat("a", 16<<10))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Failed to send request: %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusRequestHeaderFieldsTooLarge {
+		t.Errorf("Expected status 431, got %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,171 @@
+package integration
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"consent-service-extensions/internal/models"
+	"consent-service-extensions/internal/signing"
+	"consent-service-extensions/pkg/api"
+)
+
+var testHMACSecret = bytes.Repeat([]byte("k"), 32)
+
+// signedConsentCreation builds a consent creation request signed with sign at the given time
+func signedConsentCreation(t *testing.T, serverURL string, signedAt time.Time, sign func([]byte) []byte) (*http.Request, []byte) {
+	t.Helper()
+
+	body, _ := json.Marshal(models.PreProcessConsentCreationRequest{
+		RequestID: "REQ-SIGNED",
+		Data: models.Request{
+			ConsentInitiationData: models.DetailedConsentResourceData{
+				Type:           "accounts",
+				Status:         "AwaitingAuthorisation",
+				RequestPayload: map[string]interface{}{},
+			},
+		},
+	})
+
+	path := "/api/services/pre-process-consent-creation"
+	signature := sign(signing.RequestSigningInput(signedAt.Unix(), http.MethodPost, path, body))
+
+	req, _ := http.NewRequest(http.MethodPost, serverURL+path, bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(signing.HeaderTimestamp, strconv.FormatInt(signedAt.Unix(), 10))
+	req.Header.Set(signing.HeaderSignature, base64.StdEncoding.EncodeToString(signature))
+	return req, body
+}
+
+// hmacSign signs data with the test HMAC secret
+func hmacSign(data []byte) []byte {
+	mac := hmac.New(sha256.New, testHMACSecret)
+	mac.Write(data)
+	return mac.Sum(nil)
+}
+
+func newHMACSigningServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	keys, err := signing.NewKeys(signing.AlgorithmHMACSHA256, []string{base64.StdEncoding.EncodeToString(testHMACSecret)}, "")
+	if err != nil {
+		t.Fatalf("Failed to create signing keys: %v", err)
+	}
+	return httptest.NewServer(api.NewRouter(api.WithSigningKeys(keys)))
+}
+
+func TestSigning_HMACRequestAndResponse(t *testing.T) {
+	server := newHMACSigningServer(t)
+	defer server.Close()
+
+	req, _ := signedConsentCreation(t, server.URL, time.Now(), hmacSign)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Failed to send request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", resp.StatusCode)
+	}
+
+	respBody, _ := io.ReadAll(resp.Body)
+	timestamp, err := strconv.ParseInt(resp.Header.Get(signing.HeaderTimestamp), 10, 64)
+	if err != nil {
+		t.Fatalf("Missing response signature timestamp: %v", err)
+	}
+	signature, _ := base64.StdEncoding.DecodeString(resp.Header.Get(signing.HeaderSignature))
+
+	expected := hmacSign(signing.ResponseSigningInput(timestamp, resp.StatusCode, respBody))
+	if !hmac.Equal(signature, expected) {
+		t.Errorf("Response signature does not verify")
+	}
+}
+
+func TestSigning_RejectedRequests(t *testing.T) {
+	server := newHMACSigningServer(t)
+	defer server.Close()
+
+	t.Run("tampered body", func(t *testing.T) {
+		req, body := signedConsentCreation(t, server.URL, time.Now(), hmacSign)
+		req.Body = io.NopCloser(bytes.NewReader(bytes.Replace(body, []byte("accounts"), []byte("payments"), 1)))
+		expectStatus(t, req, http.StatusUnauthorized)
+	})
+
+	t.Run("stale timestamp", func(t *testing.T) {
+		req, _ := signedConsentCreation(t, server.URL, time.Now().Add(-10*time.Minute), hmacSign)
+		expectStatus(t, req, http.StatusUnauthorized)
+	})
+
+	t.Run("missing signature", func(t *testing.T) {
+		req, _ := signedConsentCreation(t, server.URL, time.Now(), hmacSign)
+		req.Header.Del(signing.HeaderSignature)
+		expectStatus(t, req, http.StatusUnauthorized)
+	})
+
+	t.Run("replayed signature", func(t *testing.T) {
+		signedAt := time.Now()
+		req, _ := signedConsentCreation(t, server.URL, signedAt, hmacSign)
+		expectStatus(t, req, http.StatusOK)
+
+		replay, _ := signedConsentCreation(t, server.URL, signedAt, hmacSign)
+		expectStatus(t, replay, http.StatusUnauthorized)
+	})
+
+	t.Run("replayed signature in another encoding", func(t *testing.T) {
+		signedAt := time.Now().Add(-2 * time.Minute)
+		req, _ := signedConsentCreation(t, server.URL, signedAt, hmacSign)
+		expectStatus(t, req, http.StatusOK)
+
+		// Setting the unused padding bits of the last character decodes to the same bytes
+		replay, _ := signedConsentCreation(t, server.URL, signedAt, hmacSign)
+		encoded := []byte(replay.Header.Get(signing.HeaderSignature))
+		last := bytes.IndexByte(encoded, '=') - 1
+		alphabet := "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/"
+		encoded[last] = alphabet[strings.IndexByte(alphabet, encoded[last])|1]
+		replay.Header.Set(signing.HeaderSignature, string(encoded))
+		expectStatus(t, replay, http.StatusUnauthorized)
+	})
+}
+
+func TestSigning_Ed25519Request(t *testing.T) {
+	public, private, _ := ed25519.GenerateKey(rand.Reader)
+	keys, err := signing.NewKeys(signing.AlgorithmEd25519, []string{base64.StdEncoding.EncodeToString(public)}, "")
+	if err != nil {
+		t.Fatalf("Failed to create signing keys: %v", err)
+	}
+
+	server := httptest.NewServer(api.NewRouter(api.WithSigningKeys(keys)))
+	defer server.Close()
+
+	req, _ := signedConsentCreation(t, server.URL, time.Now(), func(data []byte) []byte {
+		return ed25519.Sign(private, data)
+	})
+	expectStatus(t, req, http.StatusOK)
+}
+
+// expectStatus sends a request and checks the response status
+func expectStatus(t *testing.T, req *http.Request, expected int) {
+	t.Helper()
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Failed to send request: %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != expected {
+		t.Errorf("Expected status %d, got %d", expected, resp.StatusCode)
+	}
+}
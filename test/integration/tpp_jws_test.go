@@ -0,0 +1,284 @@
+package integration
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"consent-service-extensions/internal/models"
+	"consent-service-extensions/internal/tppjws"
+	"consent-service-extensions/pkg/api"
+)
+
+// tppSigner signs payloads as a TPP whose keys are registered in a JWKS directory
+type tppSigner struct {
+	key *rsa.PrivateKey
+	kid string
+}
+
+// newTPPJWSServer registers a TPP key for TPP-001 and starts a server verifying its signatures
+func newTPPJWSServer(t *testing.T) (*httptest.Server, *tppSigner) {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	signer := &tppSigner{key: key, kid: "tpp-signing-key"}
+
+	dir := t.TempDir()
+	jwks, _ := json.Marshal(map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": signer.kid,
+			"use": "sig",
+			"alg": "PS256",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}},
+	})
+	if err := os.WriteFile(filepath.Join(dir, "TPP-001.json"), jwks, 0o600); err != nil {
+		t.Fatalf("Failed to write JWKS: %v", err)
+	}
+
+	// RS256 is accepted so that the key's own alg restriction is what rejects it
+	verifier, err := tppjws.NewVerifier(tppjws.Options{
+		KeysDir:              dir,
+		Algorithms:           []string{"PS256", "RS256"},
+		RequiredConsentTypes: []string{"payments"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create verifier: %v", err)
+	}
+
+	return httptest.NewServer(api.NewRouter(api.WithTPPSignatureVerifier(verifier))), signer
+}
+
+// sign creates an OBIE detached JWS with b64=false over payload
+func (s *tppSigner) sign(t *testing.T, payload []byte, tan string) string {
+	t.Helper()
+	return s.signAs(t, payload, tan, "0015800001041RHAAY/TPP-001")
+}
+
+// signAs creates an OBIE detached JWS over payload with the given iss claim
+func (s *tppSigner) signAs(t *testing.T, payload []byte, tan, iss string) string {
+	t.Helper()
+	return s.signWith(t, payload, map[string]interface{}{
+		"http://openbanking.org.uk/iss": iss,
+		"http://openbanking.org.uk/tan": tan,
+	})
+}
+
+// signWith creates an OBIE detached JWS over payload, overriding header parameters
+func (s *tppSigner) signWith(t *testing.T, payload []byte, overrides map[string]interface{}) string {
+	t.Helper()
+
+	params := map[string]interface{}{
+		"alg":                           "PS256",
+		"kid":                           s.kid,
+		"typ":                           "JOSE",
+		"cty":                           "application/json",
+		"b64":                           false,
+		"http://openbanking.org.uk/iat": time.Now().Unix(),
+		"http://openbanking.org.uk/iss": "0015800001041RHAAY/TPP-001",
+		"http://openbanking.org.uk/tan": "openbanking.org.uk",
+		"crit":                          []string{"b64", "http://openbanking.org.uk/iat", "http://openbanking.org.uk/iss", "http://openbanking.org.uk/tan"},
+	}
+	for name, value := range overrides {
+		params[name] = value
+	}
+	header, _ := json.Marshal(params)
+	protected := base64.RawURLEncoding.EncodeToString(header)
+
+	digest := sha256.Sum256([]byte(protected + "." + string(payload)))
+	var sig []byte
+	var err error
+	if params["alg"] == "RS256" {
+		sig, err = rsa.SignPKCS1v15(rand.Reader, s.key, crypto.SHA256, digest[:])
+	} else {
+		sig, err = rsa.SignPSS(rand.Reader, s.key, crypto.SHA256, digest[:], &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+	}
+	if err != nil {
+		t.Fatalf("Failed to sign payload: %v", err)
+	}
+	return protected + ".." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+// postSignedConsent sends a consent creation request whose requestPayload is forwarded verbatim
+func postSignedConsent(t *testing.T, serverURL, consentType string, payload []byte, signature string) map[string]interface{} {
+	t.Helper()
+
+	headers := map[string]interface{}{}
+	if signature != "" {
+		headers["X-JWS-Signature"] = signature
+	}
+	headersJSON, _ := json.Marshal(headers)
+
+	var body bytes.Buffer
+	body.WriteString(`{"requestId":"REQ-JWS","data":{"consentInitiationData":{"type":"` + consentType + `","status":"AwaitingAuthorisation","requestPayload":`)
+	body.Write(payload)
+	body.WriteString(`,"attributes":{"thirdPartyId":"TPP-001"}},"requestHeaders":`)
+	body.Write(headersJSON)
+	body.WriteString(`}}`)
+
+	resp, err := http.Post(serverURL+"/api/services/pre-process-consent-creation", "application/json", &body)
+	if err != nil {
+		t.Fatalf("Failed to send request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", resp.StatusCode)
+	}
+
+	var result map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	return result
+}
+
+// obieErrorCode extracts the first OBIE ErrorCode from a FailedResponse
+func obieErrorCode(result map[string]interface{}) string {
+	data, _ := result["data"].(map[string]interface{})
+	errs, _ := data["Errors"].([]interface{})
+	if len(errs) == 0 {
+		return ""
+	}
+	first, _ := errs[0].(map[string]interface{})
+	code, _ := first["ErrorCode"].(string)
+	return code
+}
+
+func TestTPPSignature_ConsentCreation(t *testing.T) {
+	server, signer := newTPPJWSServer(t)
+	defer server.Close()
+
+	payload := []byte(`{"Data": {"Initiation": {"InstructedAmount": {"Amount": "10.00", "Currency": "GBP"}}}, "Risk": {}}`)
+
+	t.Run("valid signature", func(t *testing.T) {
+		result := postSignedConsent(t, server.URL, "payments", payload, signer.sign(t, payload, "openbanking.org.uk"))
+		if result["status"] != "SUCCESS" {
+			t.Errorf("Expected SUCCESS, got %v", result)
+		}
+	})
+
+	t.Run("tampered payload", func(t *testing.T) {
+		tampered := bytes.Replace(payload, []byte("10.00"), []byte("99.00"), 1)
+		result := postSignedConsent(t, server.URL, "payments", tampered, signer.sign(t, payload, "openbanking.org.uk"))
+		if code := obieErrorCode(result); code != "UK.OBIE.Signature.Invalid" {
+			t.Errorf("Expected UK.OBIE.Signature.Invalid, got %v", result)
+		}
+	})
+
+	t.Run("untrusted anchor", func(t *testing.T) {
+		result := postSignedConsent(t, server.URL, "payments", payload, signer.sign(t, payload, "example.com"))
+		if code := obieErrorCode(result); code != "UK.OBIE.Signature.InvalidClaim" {
+			t.Errorf("Expected UK.OBIE.Signature.InvalidClaim, got %v", result)
+		}
+	})
+
+	t.Run("issued by another TPP", func(t *testing.T) {
+		result := postSignedConsent(t, server.URL, "payments", payload, signer.signAs(t, payload, "openbanking.org.uk", "0015800001041RHAAY/TPP-002"))
+		if code := obieErrorCode(result); code != "SIGNATURE_ISSUER_MISMATCH" {
+			t.Errorf("Expected SIGNATURE_ISSUER_MISMATCH, got %v", result)
+		}
+	})
+
+	t.Run("expired signature", func(t *testing.T) {
+		old := signer.signWith(t, payload, map[string]interface{}{"http://openbanking.org.uk/iat": time.Now().Add(-time.Hour).Unix()})
+		result := postSignedConsent(t, server.URL, "payments", payload, old)
+		if code := obieErrorCode(result); code != "UK.OBIE.Signature.InvalidClaim" {
+			t.Errorf("Expected UK.OBIE.Signature.InvalidClaim, got %v", result)
+		}
+	})
+
+	t.Run("algorithm other than the key's", func(t *testing.T) {
+		result := postSignedConsent(t, server.URL, "payments", payload, signer.signWith(t, payload, map[string]interface{}{"alg": "RS256"}))
+		if code := obieErrorCode(result); code != "UK.OBIE.Signature.Invalid" {
+			t.Errorf("Expected UK.OBIE.Signature.Invalid, got %v", result)
+		}
+	})
+
+	t.Run("malformed signature", func(t *testing.T) {
+		result := postSignedConsent(t, server.URL, "payments", payload, "not-a-jws")
+		if code := obieErrorCode(result); code != "UK.OBIE.Signature.Malformed" {
+			t.Errorf("Expected UK.OBIE.Signature.Malformed, got %v", result)
+		}
+	})
+
+	t.Run("missing signature on payments", func(t *testing.T) {
+		result := postSignedConsent(t, server.URL, "payments", payload, "")
+		if code := obieErrorCode(result); code != "UK.OBIE.Signature.Missing" {
+			t.Errorf("Expected UK.OBIE.Signature.Missing, got %v", result)
+		}
+	})
+
+	t.Run("missing signature on accounts", func(t *testing.T) {
+		result := postSignedConsent(t, server.URL, "accounts", payload, "")
+		if result["status"] != "SUCCESS" {
+			t.Errorf("Expected SUCCESS, got %v", result)
+		}
+	})
+}
+
+func TestTPPSignature_FileUpload(t *testing.T) {
+	server, signer := newTPPJWSServer(t)
+	defer server.Close()
+
+	fileContent := `<Document><CstmrCdtTrfInitn><GrpHdr><NbOfTxs>1</NbOfTxs></GrpHdr></CstmrCdtTrfInitn></Document>`
+
+	tests := []struct {
+		name      string
+		signature string
+		status    string
+	}{
+		{"valid signature", signer.sign(t, []byte(fileContent), "openbanking.org.uk"), "SUCCESS"},
+		{"signature over another file", signer.sign(t, []byte("<Document/>"), "openbanking.org.uk"), "ERROR"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requestBody := models.PreProcessFileUploadRequest{
+				RequestID: "REQ-FILE",
+				Data: models.RequestForPreProcessFileUpload{
+					ConsentResource: models.StoredDetailedConsentResourceData{
+						ID:         "consent-1",
+						Type:       "payments",
+						Status:     "AwaitingUpload",
+						Attributes: map[string]interface{}{"thirdPartyId": "TPP-001"},
+					},
+					FileContent:    fileContent,
+					RequestHeaders: map[string]interface{}{"x-jws-signature": tt.signature},
+				},
+			}
+
+			body, _ := json.Marshal(requestBody)
+			resp, err := http.Post(server.URL+"/api/services/pre-process-consent-file-upload", "application/json", bytes.NewBuffer(body))
+			if err != nil {
+				t.Fatalf("Failed to send request: %v", err)
+			}
+			defer resp.Body.Close()
+
+			var result map[string]interface{}
+			if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+
+			if result["status"] != tt.status {
+				t.Errorf("Expected status %s, got %v", tt.status, result)
+			}
+		})
+	}
+}
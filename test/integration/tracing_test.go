@@ -0,0 +1,210 @@
+package integration
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"consent-service-extensions/internal/models"
+	"consent-service-extensions/internal/tracing"
+	"consent-service-extensions/pkg/api"
+)
+
+// exportedSpan is the part of an OTLP JSON span the tests check
+type exportedSpan struct {
+	TraceID      string `json:"traceId"`
+	SpanID       string `json:"spanId"`
+	ParentSpanID string `json:"parentSpanId"`
+	Name         string `json:"name"`
+	Attributes   []struct {
+		Key   string                 `json:"key"`
+		Value map[string]interface{} `json:"value"`
+	} `json:"attributes"`
+}
+
+// attribute returns the string form of a span attribute
+func (s exportedSpan) attribute(key string) interface{} {
+	for _, a := range s.Attributes {
+		if a.Key == key {
+			for _, v := range a.Value {
+				return v
+			}
+		}
+	}
+	return nil
+}
+
+// decodeSpans extracts the spans of an OTLP JSON ExportTraceServiceRequest
+func decodeSpans(t *testing.T, body []byte) []exportedSpan {
+	t.Helper()
+
+	var req struct {
+		ResourceSpans []struct {
+			ScopeSpans []struct {
+				Spans []exportedSpan `json:"spans"`
+			} `json:"scopeSpans"`
+		} `json:"resourceSpans"`
+	}
+	if err := json.Unmarshal(body, &req); err != nil {
+		t.Fatalf("Failed to decode OTLP request: %v", err)
+	}
+	var spans []exportedSpan
+	for _, rs := range req.ResourceSpans {
+		for _, ss := range rs.ScopeSpans {
+			spans = append(spans, ss.Spans...)
+		}
+	}
+	return spans
+}
+
+// postTraced posts body to endpoint with a traceparent header
+func postTraced(t *testing.T, url, traceparent string, body interface{}) {
+	t.Helper()
+
+	payload, _ := json.Marshal(body)
+	req, _ := http.NewRequest(http.MethodPost, url, bytes.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	if traceparent != "" {
+		req.Header.Set(tracing.HeaderTraceparent, traceparent)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Failed to send request: %v", err)
+	}
+	resp.Body.Close()
+}
+
+func TestTracing_FileExporterRecordsHandlerAndChildSpans(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "traces.jsonl")
+	exporter, err := tracing.NewFileExporter(path, "consent-service-extensions")
+	if err != nil {
+		t.Fatalf("Failed to create exporter: %v", err)
+	}
+	tracer := tracing.NewTracer(exporter)
+	server := httptest.NewServer(api.NewRouter(api.WithTracer(tracer)))
+	defer server.Close()
+
+	traceID, parentID := "4bf92f3577b34da6a3ce929d0e0e4736", "00f067aa0ba902b7"
+	postTraced(t, server.URL+"/api/services/pre-process-consent-creation", "00-"+traceID+"-"+parentID+"-01",
+		models.PreProcessConsentCreationRequest{
+			RequestID: "REQ-TRACE-1",
+			Data: models.Request{
+				ConsentInitiationData: models.DetailedConsentResourceData{
+					Type:   "accounts",
+					Status: "AwaitingAuthorisation",
+					RequestPayload: map[string]interface{}{
+						"Data": map[string]interface{}{"Permissions": []interface{}{"ReadAccountsBasic"}},
+					},
+				},
+				RequestHeaders: map[string]interface{}{
+					"x-fapi-interaction-id": "93bac548-d2de-4546-b106-880a5018460d",
+				},
+			},
+		})
+
+	// Close waits for in-flight handlers, so every span has ended
+	server.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := tracer.Shutdown(ctx); err != nil {
+		t.Fatalf("Failed to shut down tracer: %v", err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Failed to open trace file: %v", err)
+	}
+	defer f.Close()
+	spans := make(map[string]exportedSpan)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		for _, s := range decodeSpans(t, scanner.Bytes()) {
+			spans[s.Name] = s
+		}
+	}
+
+	root, ok := spans["POST /api/services/pre-process-consent-creation"]
+	if !ok {
+		t.Fatalf("Expected a handler span, got %v", spans)
+	}
+	if root.TraceID != traceID || root.ParentSpanID != parentID {
+		t.Errorf("Expected the handler span to continue %s/%s, got %s/%s", traceID, parentID, root.TraceID, root.ParentSpanID)
+	}
+	if got := root.attribute("requestId"); got != "REQ-TRACE-1" {
+		t.Errorf("Expected requestId attribute, got %v", got)
+	}
+	if got := root.attribute("x-fapi-interaction-id"); got != "93bac548-d2de-4546-b106-880a5018460d" {
+		t.Errorf("Expected x-fapi-interaction-id attribute, got %v", got)
+	}
+
+	for _, name := range []string{"validation", "purpose-resolution"} {
+		child, ok := spans[name]
+		if !ok {
+			t.Errorf("Expected a %s span", name)
+			continue
+		}
+		if child.TraceID != traceID || child.ParentSpanID != root.SpanID {
+			t.Errorf("Expected %s to be a child of the handler span, got parent %s", name, child.ParentSpanID)
+		}
+	}
+}
+
+func TestTracing_OTLPExporterPostsToCollector(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		spans   []exportedSpan
+		headers http.Header
+	)
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/traces" {
+			http.NotFound(w, r)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		spans = append(spans, decodeSpans(t, body)...)
+		headers = r.Header.Clone()
+		mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer collector.Close()
+
+	exporter := tracing.NewOTLPExporter(collector.URL, "consent-service-extensions", map[string]string{"Authorization": "Bearer collector-token"}, time.Second)
+	tracer := tracing.NewTracer(exporter)
+	defer tracer.Shutdown(context.Background())
+	server := httptest.NewServer(api.NewRouter(api.WithTracer(tracer)))
+	defer server.Close()
+
+	postTraced(t, server.URL+"/api/services/enrich-consent-creation-response", "", models.EnrichConsentCreationRequest{RequestID: "REQ-TRACE-2"})
+
+	server.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	tracer.Flush(ctx)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if headers.Get("Authorization") != "Bearer collector-token" {
+		t.Errorf("Expected the configured collector header, got %v", headers)
+	}
+	names := make(map[string]exportedSpan)
+	for _, s := range spans {
+		names[s.Name] = s
+	}
+	root, ok := names["POST /api/services/enrich-consent-creation-response"]
+	if !ok || root.ParentSpanID != "" {
+		t.Fatalf("Expected a root handler span, got %v", spans)
+	}
+	if enrich, ok := names["enrichment"]; !ok || enrich.ParentSpanID != root.SpanID {
+		t.Errorf("Expected an enrichment child span, got %v", spans)
+	}
+}